@@ -84,7 +84,7 @@ func TestStation12(t *testing.T) {
 		tc := tc
 		t.Run(name, func(t *testing.T) {
 			svc := service.NewTODOService(d)
-			got, err := svc.UpdateTODO(context.Background(), tc.ID, tc.Subject, tc.Description)
+			got, err := svc.UpdateTODO(context.Background(), &model.UpdateTODORequest{ID: tc.ID, Subject: tc.Subject, Description: tc.Description})
 			switch tc.WantError {
 			case nil:
 				if err != nil {
@@ -115,7 +115,7 @@ func TestStation15(t *testing.T) {
 		tc := tc
 		t.Run(name, func(t *testing.T) {
 			svc := service.NewTODOService(d)
-			ret, err := svc.ReadTODO(context.Background(), tc.PrevID, tc.Size)
+			ret, err := svc.ReadTODO(context.Background(), &model.ReadTODORequest{PrevID: tc.PrevID, Size: tc.Size})
 			if err != nil {
 				t.Errorf("ReadTODOに失敗しました: %v", err)
 				return
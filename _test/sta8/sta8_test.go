@@ -60,7 +60,7 @@ func TestStation8(t *testing.T) {
 			t.Parallel()
 
 			svc := service.NewTODOService(d)
-			got, err := svc.CreateTODO(context.Background(), tc.Subject, tc.Description)
+			got, err := svc.CreateTODO(context.Background(), &model.CreateTODORequest{Subject: tc.Subject, Description: tc.Description})
 			if err != nil {
 				if !errors.As(err, &sqlite3Err) {
 					t.Errorf("期待していないエラーの Type です, got = %t, want = %+v", err, sqlite3Err)
@@ -0,0 +1,55 @@
+package common
+
+import "context"
+
+type ActorKeyType struct{}
+
+type UserIDKeyType struct{}
+
+// 変更を行ったユーザーの名前をcontextに格納する
+func SetActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, ActorKeyType{}, actor)
+}
+
+// contextから変更を行ったユーザーの名前を取得する. 格納されていない場合は空文字を返す
+func GetActor(ctx context.Context) string {
+	actor, _ := ctx.Value(ActorKeyType{}).(string)
+	return actor
+}
+
+// 認証したユーザーのIDをcontextに格納する. TODOは所有者のIDで絞り込む
+func SetUserID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, UserIDKeyType{}, id)
+}
+
+// contextから認証したユーザーのIDを取得する. 格納されていない場合は0を返す
+func GetUserID(ctx context.Context) int64 {
+	id, _ := ctx.Value(UserIDKeyType{}).(int64)
+	return id
+}
+
+type AdminKeyType struct{}
+
+// 認証したユーザーが管理者かどうかをcontextに格納する
+func SetAdmin(ctx context.Context, admin bool) context.Context {
+	return context.WithValue(ctx, AdminKeyType{}, admin)
+}
+
+// contextから認証したユーザーが管理者かどうかを取得する. 格納されていない場合はfalseを返す
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(AdminKeyType{}).(bool)
+	return admin
+}
+
+type ScopesKeyType struct{}
+
+// APIトークンで認証した場合に、トークンのスコープをcontextに格納する
+func SetScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, ScopesKeyType{}, scopes)
+}
+
+// contextからAPIトークンのスコープを取得する. パスワードで認証した場合など、スコープで制限されない場合はnilを返す
+func GetScopes(ctx context.Context) []string {
+	scopes, _ := ctx.Value(ScopesKeyType{}).([]string)
+	return scopes
+}
@@ -1,8 +1,11 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	_ "embed"
+	"fmt"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -10,16 +13,283 @@ import (
 //go:embed schema.sql
 var schema string
 
+//go:embed fts5.sql
+var ftsSchema string
+
 // NewDB returns go-sqlite3 driver based *sql.DB.
+// 外部キー制約はコネクションごとに有効にする必要があるのでDSNで指定する.
+// 既存のDBはschema.sqlを実行する前にmigrationsで現在のテーブル定義に合わせる.
 func NewDB(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", path)
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite3", path+sep+"_foreign_keys=on")
 	if err != nil {
 		return nil, err
 	}
 
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+
 	if _, err := db.Exec(schema); err != nil {
 		return nil, err
 	}
 
+	if err := setupFTS(db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
+
+// setupFTS creates the FTS5 index of todos if the driver supports FTS5.
+// go-sqlite3は-tags sqlite_fts5でビルドした場合のみFTS5が使えるので、
+// 使えない場合は全文検索なしで起動する.
+func setupFTS(db *sql.DB) error {
+	const exists = `SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'todos_fts')`
+
+	var found bool
+	if err := db.QueryRow(exists).Scan(&found); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(ftsSchema); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return nil
+		}
+		return err
+	}
+
+	// 新しく作成した場合は既存のtodosから索引を作り直す
+	if !found {
+		if _, err := db.Exec(`INSERT INTO todos_fts(todos_fts) VALUES('rebuild')`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A migration upgrades the tables created by an older schema.sql using tx.
+// 途中のバージョンのDBにも適用できるように、既に行われている変更は無視する.
+type migration func(tx *sql.Tx) error
+
+// migrations lists the upgrades in the order they were introduced.
+// PRAGMA user_versionに適用済みの数を記録するので、要素は末尾に追加するだけで並べ替えや削除はしない.
+var migrations = []migration{
+	// TODOの所有者、完了状態、楽観的ロック、プロジェクト、サブタスク、期限、繰り返し、優先度、並び順、ゴミ箱の列を追加する.
+	// 外部キーを参照する列はNOT NULLにできないので、owner_idは所有者のいないTODOをNULLにする
+	func(tx *sql.Tx) error {
+		for _, c := range []struct{ name, definition string }{
+			{"owner_id", "INTEGER REFERENCES users(id) ON DELETE CASCADE"},
+			{"completed", "BOOLEAN NOT NULL DEFAULT FALSE"},
+			{"completed_at", "DATETIME"},
+			{"version", "INTEGER NOT NULL DEFAULT 1"},
+			{"project_id", "INTEGER NOT NULL DEFAULT 1 REFERENCES projects(id)"},
+			{"parent_id", "INTEGER REFERENCES todos(id) ON DELETE CASCADE"},
+			{"due_at", "DATETIME"},
+			{"remind_at", "DATETIME"},
+			{"rrule", "TEXT NOT NULL DEFAULT ''"},
+			{"rrule_start", "DATETIME"},
+			{"recurs_from", "INTEGER REFERENCES todos(id) ON DELETE SET NULL"},
+			{"priority", "INTEGER NOT NULL DEFAULT 0"},
+			{"position", "REAL NOT NULL DEFAULT 0"},
+			{"deleted_at", "DATETIME"},
+		} {
+			if err := addColumn(tx, "todos", c.name, c.definition); err != nil {
+				return err
+			}
+		}
+		// 古いトリガーはversionを更新しないので、schema.sqlで作り直す
+		_, err := tx.Exec(`DROP TRIGGER IF EXISTS trigger_todos_updated_at`)
+		return err
+	},
+	// プロジェクト、タグとカスタムフィールドの所有者の列を追加する. 既存の行はTODOと同じく起動時に最初のユーザーに引き継ぐ.
+	// タグとカスタムフィールドの名前の重複はユーザーごとに判定するので、UNIQUE制約を外すためにテーブルを作り直す
+	func(tx *sql.Tx) error {
+		if err := addColumn(tx, "projects", "owner_id", "INTEGER REFERENCES users(id) ON DELETE CASCADE"); err != nil {
+			return err
+		}
+		if err := rebuildTable(tx, "tags", "owner_id", `CREATE TABLE tags_new (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  owner_id   INTEGER  REFERENCES users(id) ON DELETE CASCADE,
+  name       TEXT     NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+)`, "id, name, created_at, updated_at"); err != nil {
+			return err
+		}
+		return rebuildTable(tx, "custom_fields", "owner_id", `CREATE TABLE custom_fields_new (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  owner_id   INTEGER  REFERENCES users(id) ON DELETE CASCADE,
+  project_id INTEGER  NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  name       TEXT     NOT NULL,
+  type       TEXT     NOT NULL,
+  options    TEXT     NOT NULL DEFAULT '[]',
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> ''),
+  CHECK(type IN ('text', 'number', 'date', 'enum'))
+)`, "id, project_id, name, type, options, created_at, updated_at")
+	},
+	// ユーザーに管理者の列を追加する. それまでユーザーを登録できた最初のユーザーを管理者にする
+	func(tx *sql.Tx) error {
+		columns, err := tableColumns(tx, "users")
+		if err != nil || len(columns) == 0 || columns["is_admin"] {
+			return err
+		}
+		if err := addColumn(tx, "users", "is_admin", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE users SET is_admin = TRUE WHERE id = (SELECT MIN(id) FROM users)`)
+		return err
+	},
+	// 作業時間に記録したユーザーのIDの列を追加し、既存の記録は同じ名前のユーザーに結び付ける.
+	// 計測中のタイマーは表示名ではなくユーザーのIDごとに一つまでにするので、一意なインデックスはschema.sqlで作り直す
+	func(tx *sql.Tx) error {
+		columns, err := tableColumns(tx, "time_entries")
+		if err != nil || len(columns) == 0 || columns["owner_id"] {
+			return err
+		}
+		if err := addColumn(tx, "time_entries", "owner_id", "INTEGER REFERENCES users(id) ON DELETE CASCADE"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE time_entries SET owner_id = (SELECT id FROM users WHERE users.name = time_entries.owner)`); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`DROP INDEX IF EXISTS index_time_entries_running`)
+		return err
+	},
+	// 添付ファイルに添付したユーザーのIDの列を追加し、既存のファイルは同じ名前のユーザーに結び付ける.
+	// 容量の制限は表示名ではなくユーザーのIDごとに数えるので、表示名のインデックスはowner_idのインデックスに置き換える
+	func(tx *sql.Tx) error {
+		columns, err := tableColumns(tx, "attachments")
+		if err != nil || len(columns) == 0 || columns["owner_id"] {
+			return err
+		}
+		if err := addColumn(tx, "attachments", "owner_id", "INTEGER REFERENCES users(id) ON DELETE CASCADE"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE attachments SET owner_id = (SELECT id FROM users WHERE users.name = attachments.owner)`); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`DROP INDEX IF EXISTS index_attachments_owner`)
+		return err
+	},
+}
+
+// migrate applies the migrations that are not recorded in PRAGMA user_version yet.
+// 新しく作成するDBにはテーブルがないので何も変更せず、schema.sqlが現在の定義で作成する.
+func migrate(db *sql.DB) error {
+	ctx := context.Background()
+
+	// PRAGMA foreign_keysはコネクションごとの設定なので、同じコネクションでマイグレーションを行う
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var version int
+	if err := conn.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version >= len(migrations) {
+		return nil
+	}
+
+	// 外部キーを参照する列はデフォルト値があると追加できないので、マイグレーションの間は外部キー制約を無効にする.
+	// PRAGMA foreign_keysはトランザクションの中では変更できない
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return err
+	}
+
+	if err := applyMigrations(ctx, conn, version); err != nil {
+		// 失敗してもコネクションはプールに戻るので、外部キー制約を有効に戻しておく
+		conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+	return err
+}
+
+// applyMigrations applies the migrations after version in a transaction and records the new version.
+func applyMigrations(ctx context.Context, conn *sql.Conn, version int) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for i := version; i < len(migrations); i++ {
+		if err := migrations[i](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("db: migration %d failed: %w", i+1, err)
+		}
+	}
+	// PRAGMAの値にはプレースホルダーを使えない
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, len(migrations))); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// addColumn adds the column to the table with definition.
+// テーブルがまだない場合と、既に列がある場合は何もしない.
+func addColumn(tx *sql.Tx, table, column, definition string) error {
+	columns, err := tableColumns(tx, table)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 || columns[column] {
+		return nil
+	}
+	_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
+
+// tableColumns returns the set of the column names of the table. テーブルがない場合は空になる.
+func tableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// rebuildTable recreates the table with create, a CREATE TABLE statement of <table>_new, and copies the columns.
+// SQLiteのALTER TABLEでは制約を変更できないので、新しいテーブルに移してから名前を戻す.
+// テーブルがまだない場合と、既にcolumnがある場合は何もしない. トリガーと索引はschema.sqlで作り直す.
+func rebuildTable(tx *sql.Tx, table, column, create, columns string) error {
+	existing, err := tableColumns(tx, table)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 || existing[column] {
+		return nil
+	}
+	for _, query := range []string{
+		create,
+		fmt.Sprintf(`INSERT INTO %[1]s_new(%[2]s) SELECT %[2]s FROM %[1]s`, table, columns),
+		fmt.Sprintf(`DROP TABLE %s`, table),
+		fmt.Sprintf(`ALTER TABLE %[1]s_new RENAME TO %[1]s`, table),
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
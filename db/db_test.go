@@ -1,8 +1,10 @@
 package db_test
 
 import (
+	"database/sql"
 	"errors"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/TechBowl-japan/go-stations/db"
@@ -44,3 +46,321 @@ func TestNewDB(t *testing.T) {
 		})
 	}
 }
+
+// baselineSchema is the schema.sql of the first release, before any column was added to todos.
+const baselineSchema = `
+CREATE TABLE IF NOT EXISTS todos (
+  id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  subject     TEXT     NOT NULL,
+  description TEXT     NOT NULL DEFAULT '',
+  created_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(subject <> '')
+);
+
+CREATE TRIGGER IF NOT EXISTS trigger_todos_updated_at AFTER UPDATE ON todos
+BEGIN
+  UPDATE todos SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+INSERT INTO todos(subject) VALUES('legacy');
+`
+
+func TestNewDBMigratesBaseline(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "baseline.db")
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal("failed to open baseline db, err =", err)
+	}
+	if _, err := old.Exec(baselineSchema); err != nil {
+		t.Fatal("failed to create baseline db, err =", err)
+	}
+	if err := old.Close(); err != nil {
+		t.Fatal("failed to close baseline db, err =", err)
+	}
+
+	// 2回目のNewDBは適用済みのマイグレーションを繰り返さない
+	for i := 0; i < 2; i++ {
+		d, err := db.NewDB(path)
+		if err != nil {
+			t.Fatal("failed to migrate baseline db, err =", err)
+		}
+
+		const update = `UPDATE todos SET completed = TRUE, completed_at = DATETIME('now'), priority = 3 WHERE subject = 'legacy'`
+		if _, err := d.Exec(update); err != nil {
+			t.Fatal("failed to update migrated todo, err =", err)
+		}
+
+		var (
+			ownerID   sql.NullInt64
+			projectID int64
+			version   int64
+			deletedAt sql.NullTime
+		)
+		const read = `SELECT owner_id, project_id, version, deleted_at FROM todos WHERE subject = 'legacy'`
+		if err := d.QueryRow(read).Scan(&ownerID, &projectID, &version, &deletedAt); err != nil {
+			t.Fatal("failed to read migrated todo, err =", err)
+		}
+		// 所有者は起動時に最初のユーザーが引き継ぐまでNULLのまま
+		if ownerID.Valid || projectID != 1 || version != int64(i+2) || deletedAt.Valid {
+			t.Errorf("unexpected migrated todo, owner_id = %v, project_id = %d, version = %d, deleted_at = %v", ownerID, projectID, version, deletedAt)
+		}
+
+		var userVersion int
+		if err := d.QueryRow(`PRAGMA user_version`).Scan(&userVersion); err != nil {
+			t.Fatal("failed to read user_version, err =", err)
+		}
+		if userVersion == 0 {
+			t.Error("user_version is not recorded")
+		}
+
+		if err := d.Close(); err != nil {
+			t.Fatal("failed to close db, err =", err)
+		}
+	}
+}
+
+// sharedSchema is the tags and custom_fields of the schema.sql before they had an owner.
+const sharedSchema = `
+CREATE TABLE tags (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name       TEXT     NOT NULL UNIQUE,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TABLE custom_fields (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  project_id INTEGER  NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  name       TEXT     NOT NULL,
+  type       TEXT     NOT NULL,
+  options    TEXT     NOT NULL DEFAULT '[]',
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  UNIQUE(project_id, name),
+  CHECK(name <> ''),
+  CHECK(type IN ('text', 'number', 'date', 'enum'))
+);
+
+INSERT INTO tags(name) VALUES('work');
+INSERT INTO custom_fields(project_id, name, type) VALUES(1, 'estimate', 'number');
+PRAGMA user_version = 1;
+`
+
+func TestNewDBMigratesSharedTables(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "shared.db")
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal("failed to open shared db, err =", err)
+	}
+	if _, err := old.Exec(sharedSchema); err != nil {
+		t.Fatal("failed to create shared db, err =", err)
+	}
+	if err := old.Close(); err != nil {
+		t.Fatal("failed to close shared db, err =", err)
+	}
+
+	d, err := db.NewDB(path)
+	if err != nil {
+		t.Fatal("failed to migrate shared db, err =", err)
+	}
+	defer d.Close()
+
+	// 既存の行は所有者がいないまま残り、別のユーザーは同じ名前で作成できる
+	if _, err := d.Exec(`INSERT INTO users(name, password_hash) VALUES('alice', 'hash')`); err != nil {
+		t.Fatal("failed to create user, err =", err)
+	}
+	for _, insert := range []string{
+		`INSERT INTO tags(owner_id, name) VALUES(1, 'work')`,
+		`INSERT INTO custom_fields(owner_id, project_id, name, type) VALUES(1, 1, 'estimate', 'number')`,
+	} {
+		if _, err := d.Exec(insert); err != nil {
+			t.Errorf("failed to %s, err = %v", insert, err)
+		}
+	}
+	for _, insert := range []string{
+		`INSERT INTO tags(owner_id, name) VALUES(1, 'work')`,
+		`INSERT INTO custom_fields(project_id, name, type) VALUES(1, 'estimate', 'text')`,
+	} {
+		var sqliteErr sqlite3.Error
+		if _, err := d.Exec(insert); !errors.As(err, &sqliteErr) || sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique {
+			t.Errorf("unexpected error of %s, err = %v", insert, err)
+		}
+	}
+
+	var unowned int
+	const count = `SELECT (SELECT COUNT(*) FROM tags WHERE owner_id IS NULL) + (SELECT COUNT(*) FROM custom_fields WHERE owner_id IS NULL)`
+	if err := d.QueryRow(count).Scan(&unowned); err != nil {
+		t.Fatal("failed to count unowned rows, err =", err)
+	}
+	if unowned != 2 {
+		t.Errorf("unexpected number of unowned rows %d, want 2", unowned)
+	}
+}
+
+// timeEntriesSchema is the users and time_entries of the schema.sql before time entries had the id of the user.
+const timeEntriesSchema = `
+CREATE TABLE users (
+  id            INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name          TEXT     NOT NULL UNIQUE,
+  password_hash TEXT     NOT NULL,
+  is_admin      BOOLEAN  NOT NULL DEFAULT FALSE,
+  created_at    DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at    DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TABLE time_entries (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id    INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  owner      TEXT     NOT NULL,
+  started_at DATETIME NOT NULL,
+  stopped_at DATETIME,
+  note       TEXT     NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(stopped_at IS NULL OR stopped_at >= started_at)
+);
+
+CREATE UNIQUE INDEX index_time_entries_running ON time_entries(owner) WHERE stopped_at IS NULL;
+
+INSERT INTO users(name, password_hash, is_admin) VALUES('alice', 'hash', TRUE), ('bob', 'hash', FALSE);
+INSERT INTO time_entries(todo_id, owner, started_at) VALUES(1, 'alice', DATETIME('now')), (1, 'removed', DATETIME('now'));
+PRAGMA user_version = 3;
+`
+
+func TestNewDBMigratesTimeEntries(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "time_entries.db")
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal("failed to open time_entries db, err =", err)
+	}
+	if _, err := old.Exec(timeEntriesSchema); err != nil {
+		t.Fatal("failed to create time_entries db, err =", err)
+	}
+	if err := old.Close(); err != nil {
+		t.Fatal("failed to close time_entries db, err =", err)
+	}
+
+	d, err := db.NewDB(path)
+	if err != nil {
+		t.Fatal("failed to migrate time_entries db, err =", err)
+	}
+	defer d.Close()
+
+	// 既存の記録は同じ名前のユーザーに結び付け、ユーザーがいない記録はNULLのまま残す
+	var alice, removed sql.NullInt64
+	const read = `SELECT (SELECT owner_id FROM time_entries WHERE owner = 'alice'), (SELECT owner_id FROM time_entries WHERE owner = 'removed')`
+	if err := d.QueryRow(read).Scan(&alice, &removed); err != nil {
+		t.Fatal("failed to read migrated time entries, err =", err)
+	}
+	if alice.Int64 != 1 || removed.Valid {
+		t.Errorf("unexpected owner_id, alice = %v, removed = %v", alice, removed)
+	}
+
+	// 計測中のタイマーは名前ではなくユーザーのIDごとに一つまでになる
+	if _, err := d.Exec(`INSERT INTO todos(subject) VALUES('a')`); err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+	const insert = `INSERT INTO time_entries(todo_id, owner_id, owner, started_at) VALUES(1, ?, 'alice', DATETIME('now'))`
+	if _, err := d.Exec(insert, 2); err != nil {
+		t.Errorf("failed to start the timer of another user with the same name, err = %v", err)
+	}
+	var sqliteErr sqlite3.Error
+	if _, err := d.Exec(insert, 1); !errors.As(err, &sqliteErr) || sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique {
+		t.Errorf("unexpected error of the second timer, err = %v", err)
+	}
+}
+
+// attachmentsSchema is the users and attachments of the schema.sql before attachments had the id of the user.
+const attachmentsSchema = `
+CREATE TABLE users (
+  id            INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name          TEXT     NOT NULL UNIQUE,
+  password_hash TEXT     NOT NULL,
+  is_admin      BOOLEAN  NOT NULL DEFAULT FALSE,
+  created_at    DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at    DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TABLE attachments (
+  id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id      INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  filename     TEXT     NOT NULL,
+  content_type TEXT     NOT NULL,
+  size         INTEGER  NOT NULL,
+  sha256       TEXT     NOT NULL,
+  owner        TEXT     NOT NULL,
+  created_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(filename <> '' AND size >= 0 AND LENGTH(sha256) = 64)
+);
+
+CREATE INDEX index_attachments_owner ON attachments(owner);
+
+INSERT INTO users(name, password_hash, is_admin) VALUES('alice', 'hash', TRUE);
+INSERT INTO attachments(todo_id, filename, content_type, size, sha256, owner) VALUES
+  (1, 'a.txt', 'text/plain', 1, printf('%064d', 0), 'alice'),
+  (1, 'b.txt', 'text/plain', 1, printf('%064d', 0), 'removed');
+PRAGMA user_version = 4;
+`
+
+func TestNewDBMigratesAttachments(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "attachments.db")
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal("failed to open attachments db, err =", err)
+	}
+	if _, err := old.Exec(attachmentsSchema); err != nil {
+		t.Fatal("failed to create attachments db, err =", err)
+	}
+	if err := old.Close(); err != nil {
+		t.Fatal("failed to close attachments db, err =", err)
+	}
+
+	d, err := db.NewDB(path)
+	if err != nil {
+		t.Fatal("failed to migrate attachments db, err =", err)
+	}
+	defer d.Close()
+
+	// 既存のファイルは同じ名前のユーザーに結び付け、ユーザーがいないファイルはNULLのまま残す
+	var alice, removed sql.NullInt64
+	const read = `SELECT (SELECT owner_id FROM attachments WHERE owner = 'alice'), (SELECT owner_id FROM attachments WHERE owner = 'removed')`
+	if err := d.QueryRow(read).Scan(&alice, &removed); err != nil {
+		t.Fatal("failed to read migrated attachments, err =", err)
+	}
+	if alice.Int64 != 1 || removed.Valid {
+		t.Errorf("unexpected owner_id, alice = %v, removed = %v", alice, removed)
+	}
+
+	// 表示名のインデックスはowner_idのインデックスに置き換わる
+	var indexes []string
+	rows, err := d.Query(`SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'attachments' AND name LIKE 'index_attachments_owner%'`)
+	if err != nil {
+		t.Fatal("failed to read indexes, err =", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal("failed to scan index, err =", err)
+		}
+		indexes = append(indexes, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal("failed to read indexes, err =", err)
+	}
+	if len(indexes) != 1 || indexes[0] != "index_attachments_owner_id" {
+		t.Errorf("unexpected indexes %v", indexes)
+	}
+}
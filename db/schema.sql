@@ -1,13 +1,333 @@
-CREATE TABLE IF NOT EXISTS todos (
+-- ログインできるユーザー. password_hashはbcryptのハッシュで、平文のパスワードは保存しない.
+-- is_adminのユーザーだけがユーザーの登録と署名鍵の管理を行える
+CREATE TABLE IF NOT EXISTS users (
+  id            INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name          TEXT     NOT NULL UNIQUE,
+  password_hash TEXT     NOT NULL,
+  is_admin      BOOLEAN  NOT NULL DEFAULT FALSE,
+  created_at    DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at    DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TRIGGER IF NOT EXISTS trigger_users_updated_at AFTER UPDATE ON users
+BEGIN
+  UPDATE users SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+-- ユーザーのAPIトークン. トークン自体は保存せず、sha256のハッシュで照合する.
+-- scopesは空白区切りのスコープで、revoked_atが設定されたトークンやexpires_atを過ぎたトークンは使えない
+CREATE TABLE IF NOT EXISTS api_tokens (
+  id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  user_id      INTEGER  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name         TEXT     NOT NULL,
+  prefix       TEXT     NOT NULL,
+  token_hash   TEXT     NOT NULL UNIQUE,
+  scopes       TEXT     NOT NULL,
+  expires_at   DATETIME,
+  last_used_at DATETIME,
+  revoked_at   DATETIME,
+  created_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '' AND scopes <> '' AND LENGTH(token_hash) = 64)
+);
+
+CREATE INDEX IF NOT EXISTS index_api_tokens_user_id ON api_tokens(user_id);
+
+-- アクセストークン(JWT)の署名鍵. private_keyはECDSA P-256の秘密鍵(SEC 1, DER)で、kidでJWTと対応付ける.
+-- retired_atが設定されていない最新の鍵で署名し、ローテーションで退役した鍵も発行済みのトークンが期限切れになるまではJWKSで公開する
+CREATE TABLE IF NOT EXISTS signing_keys (
   id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
-  subject     TEXT     NOT NULL,
-  description TEXT     NOT NULL DEFAULT '',
+  kid         TEXT     NOT NULL UNIQUE,
+  private_key BLOB     NOT NULL,
   created_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
-  updated_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
-  CHECK(subject <> '')
+  retired_at  DATETIME,
+  CHECK(kid <> '')
+);
+
+-- ログインで発行するリフレッシュトークン. APIトークンと同じくsha256のハッシュで照合する.
+-- 使うたびに同じfamily_idの新しいトークンに交換し、used_atが設定されたトークンが再び使われたらfamily全体を失効させる
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  user_id    INTEGER  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  family_id  TEXT     NOT NULL,
+  token_hash TEXT     NOT NULL UNIQUE,
+  expires_at DATETIME NOT NULL,
+  used_at    DATETIME,
+  revoked_at DATETIME,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(family_id <> '' AND LENGTH(token_hash) = 64)
+);
+
+CREATE INDEX IF NOT EXISTS index_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+-- OpenID Connectでログインしたユーザー. IdPのissuerとsubjectの組でローカルのユーザーに対応付ける
+CREATE TABLE IF NOT EXISTS user_identities (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  user_id    INTEGER  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  issuer     TEXT     NOT NULL,
+  subject    TEXT     NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  UNIQUE(issuer, subject),
+  CHECK(issuer <> '' AND subject <> '')
+);
+
+CREATE INDEX IF NOT EXISTS index_user_identities_user_id ON user_identities(user_id);
+
+-- IdPにリダイレクトしてから戻ってくるまでのOpenID Connectのログイン. stateはハッシュで照合し、一度しか使えない.
+-- nonceはIDトークンと、code_verifierはPKCEで認可コードと照合する
+CREATE TABLE IF NOT EXISTS oidc_states (
+  state_hash    TEXT     NOT NULL PRIMARY KEY,
+  nonce         TEXT     NOT NULL,
+  code_verifier TEXT     NOT NULL,
+  expires_at    DATETIME NOT NULL,
+  created_at    DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(LENGTH(state_hash) = 64)
 );
 
-CREATE TRIGGER IF NOT EXISTS trigger_todos_updated_at AFTER UPDATE ON todos
+CREATE TABLE IF NOT EXISTS projects (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  -- プロジェクトを作成したユーザー. プロジェクトは所有者からしか見えない.
+  -- InboxはNULLのまま全員で共有し、それ以外のNULLの行はTODOと同じく起動時に最初のユーザーに引き継ぐ
+  owner_id   INTEGER  REFERENCES users(id) ON DELETE CASCADE,
+  name       TEXT     NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+-- id = 1 はプロジェクトを指定せずに作成したTODOが入るInboxで、削除できない
+INSERT OR IGNORE INTO projects(id, name) VALUES (1, 'Inbox');
+
+CREATE INDEX IF NOT EXISTS index_projects_owner_id ON projects(owner_id);
+
+CREATE TRIGGER IF NOT EXISTS trigger_projects_updated_at AFTER UPDATE ON projects
+BEGIN
+  UPDATE projects SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+CREATE TABLE IF NOT EXISTS todos (
+  id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  subject      TEXT     NOT NULL,
+  -- TODOを作成したユーザー. TODOは所有者からしか見えない.
+  -- NULLはユーザーのいないcontextで作成したTODOと、所有者の列を追加する前のTODOで、起動時に最初のユーザーに引き継ぐ
+  owner_id     INTEGER  REFERENCES users(id) ON DELETE CASCADE,
+  description  TEXT     NOT NULL DEFAULT '',
+  project_id   INTEGER  NOT NULL DEFAULT 1 REFERENCES projects(id),
+  -- 親のTODOを完全に削除するとサブタスクも削除される
+  parent_id    INTEGER  REFERENCES todos(id) ON DELETE CASCADE,
+  due_at       DATETIME,
+  remind_at    DATETIME,
+  -- RFC 5545のRRULE. rrule_startは繰り返しの起点となる最初のdue_at
+  rrule        TEXT     NOT NULL DEFAULT '',
+  rrule_start  DATETIME,
+  -- 完了時に次の繰り返しを作成した元のTODO
+  recurs_from  INTEGER  REFERENCES todos(id) ON DELETE SET NULL,
+  -- 0: none, 1: low, 2: medium, 3: high
+  priority     INTEGER  NOT NULL DEFAULT 0,
+  -- 手動の並び順. 隣り合うTODOの間の値にすることで1行の更新で移動できる
+  position     REAL     NOT NULL DEFAULT 0,
+  completed    BOOLEAN  NOT NULL DEFAULT FALSE,
+  completed_at DATETIME,
+  -- ゴミ箱に移動した日時. NULLでないTODOはゴミ箱以外からは見えない
+  deleted_at   DATETIME,
+  version      INTEGER  NOT NULL DEFAULT 1,
+  created_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(subject <> ''),
+  CHECK(priority BETWEEN 0 AND 3),
+  CHECK(rrule = '' OR (due_at IS NOT NULL AND rrule_start IS NOT NULL)),
+  CHECK((completed = FALSE AND completed_at IS NULL) OR (completed = TRUE AND completed_at IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS index_todos_owner_id ON todos(owner_id);
+CREATE INDEX IF NOT EXISTS index_todos_project_id ON todos(project_id);
+CREATE INDEX IF NOT EXISTS index_todos_parent_id ON todos(parent_id);
+CREATE INDEX IF NOT EXISTS index_todos_due_at ON todos(due_at);
+CREATE INDEX IF NOT EXISTS index_todos_remind_at ON todos(remind_at);
+CREATE UNIQUE INDEX IF NOT EXISTS index_todos_recurs_from ON todos(recurs_from);
+CREATE INDEX IF NOT EXISTS index_todos_priority ON todos(priority);
+CREATE INDEX IF NOT EXISTS index_todos_position ON todos(position);
+CREATE INDEX IF NOT EXISTS index_todos_deleted_at ON todos(deleted_at);
+
+-- 並び替え(positionのみの更新)ではバージョンと更新日時を変えない
+CREATE TRIGGER IF NOT EXISTS trigger_todos_updated_at AFTER UPDATE ON todos WHEN NEW.position IS OLD.position
 BEGIN
-  UPDATE todos SET updated_at = DATETIME('now') WHERE id == NEW.id;
+  UPDATE todos SET updated_at = DATETIME('now'), version = OLD.version + 1 WHERE id == NEW.id;
 END;
+
+-- タグは所有者からしか見えず、名前はユーザーごとに重複できない. NULLの行は起動時に最初のユーザーに引き継ぐ
+CREATE TABLE IF NOT EXISTS tags (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  owner_id   INTEGER  REFERENCES users(id) ON DELETE CASCADE,
+  name       TEXT     NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE INDEX IF NOT EXISTS index_tags_owner_id ON tags(owner_id);
+CREATE UNIQUE INDEX IF NOT EXISTS index_tags_name ON tags(IFNULL(owner_id, 0), name);
+
+CREATE TRIGGER IF NOT EXISTS trigger_tags_updated_at AFTER UPDATE ON tags
+BEGIN
+  UPDATE tags SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+CREATE TABLE IF NOT EXISTS todo_tags (
+  todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  tag_id  INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+  PRIMARY KEY(todo_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS index_todo_tags_tag_id ON todo_tags(tag_id);
+
+-- プロジェクトごとのカスタムフィールド. optionsはenumの選択肢のJSON配列で、それ以外の型では空の配列.
+-- Inboxは共有するので、フィールドは所有者からしか見えず、名前はユーザーとプロジェクトごとに重複できない
+CREATE TABLE IF NOT EXISTS custom_fields (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  owner_id   INTEGER  REFERENCES users(id) ON DELETE CASCADE,
+  project_id INTEGER  NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  name       TEXT     NOT NULL,
+  type       TEXT     NOT NULL,
+  options    TEXT     NOT NULL DEFAULT '[]',
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> ''),
+  CHECK(type IN ('text', 'number', 'date', 'enum'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS index_custom_fields_name ON custom_fields(project_id, IFNULL(owner_id, 0), name);
+
+CREATE TRIGGER IF NOT EXISTS trigger_custom_fields_updated_at AFTER UPDATE ON custom_fields
+BEGIN
+  UPDATE custom_fields SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+-- TODOのカスタムフィールドの値. valueは型を宣言せず、numberはREAL、それ以外はTEXT(dateはYYYY-MM-DD)のまま保存して
+-- 型ごとに正しく比較や並び替えができるようにする
+CREATE TABLE IF NOT EXISTS todo_field_values (
+  todo_id  INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  field_id INTEGER NOT NULL REFERENCES custom_fields(id) ON DELETE CASCADE,
+  value    NOT NULL,
+  PRIMARY KEY(todo_id, field_id)
+);
+
+CREATE INDEX IF NOT EXISTS index_todo_field_values_field_id ON todo_field_values(field_id, value);
+
+-- TODOへのコメント. authorはコメントを書いた認証済みのユーザー
+CREATE TABLE IF NOT EXISTS comments (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id    INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  author     TEXT     NOT NULL,
+  body       TEXT     NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(body <> '')
+);
+
+CREATE INDEX IF NOT EXISTS index_comments_todo_id ON comments(todo_id);
+
+CREATE TRIGGER IF NOT EXISTS trigger_comments_updated_at AFTER UPDATE ON comments
+BEGIN
+  UPDATE comments SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+-- TODOのチェックリスト. サブタスクにするほどではない手順をpositionの順に並べる
+CREATE TABLE IF NOT EXISTS checklist_items (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id    INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  body       TEXT     NOT NULL,
+  done       BOOLEAN  NOT NULL DEFAULT FALSE,
+  position   INTEGER  NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(body <> '')
+);
+
+CREATE INDEX IF NOT EXISTS index_checklist_items_todo_id ON checklist_items(todo_id, position);
+
+CREATE TRIGGER IF NOT EXISTS trigger_checklist_items_updated_at AFTER UPDATE ON checklist_items
+BEGIN
+  UPDATE checklist_items SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+-- TODOの依存関係. todo_idのTODOはdepends_on_idのTODOが完了するまで始められない.
+-- 循環する依存関係は追加する前にアプリケーションで検出する
+CREATE TABLE IF NOT EXISTS todo_dependencies (
+  todo_id       INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  depends_on_id INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  created_at    DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  PRIMARY KEY(todo_id, depends_on_id),
+  CHECK(todo_id <> depends_on_id)
+);
+
+CREATE INDEX IF NOT EXISTS index_todo_dependencies_depends_on_id ON todo_dependencies(depends_on_id);
+
+-- TODOの作業時間. stopped_atがNULLの記録は計測中のタイマーで、ユーザーごとに一つまで.
+-- ownerは記録したユーザーの表示名で、タイマーはowner_idで区別する
+CREATE TABLE IF NOT EXISTS time_entries (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id    INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  owner_id   INTEGER  REFERENCES users(id) ON DELETE CASCADE,
+  owner      TEXT     NOT NULL,
+  started_at DATETIME NOT NULL,
+  stopped_at DATETIME,
+  note       TEXT     NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(stopped_at IS NULL OR stopped_at >= started_at)
+);
+
+CREATE INDEX IF NOT EXISTS index_time_entries_todo_id ON time_entries(todo_id);
+CREATE INDEX IF NOT EXISTS index_time_entries_started_at ON time_entries(started_at);
+CREATE UNIQUE INDEX IF NOT EXISTS index_time_entries_running ON time_entries(owner_id) WHERE stopped_at IS NULL;
+
+CREATE TRIGGER IF NOT EXISTS trigger_time_entries_updated_at AFTER UPDATE ON time_entries
+BEGIN
+  UPDATE time_entries SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+-- TODOの添付ファイル. 内容はsha256をキーにしてディスクに保存し、同じ内容のファイルは共有する.
+-- sizeは添付ファイルごとに数え、同じ内容を共有していてもユーザーごとの容量の制限に含める.
+-- ownerは添付したユーザーの表示名で、容量の制限はowner_idごとに数える
+CREATE TABLE IF NOT EXISTS attachments (
+  id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id      INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  owner_id     INTEGER  REFERENCES users(id) ON DELETE CASCADE,
+  filename     TEXT     NOT NULL,
+  content_type TEXT     NOT NULL,
+  size         INTEGER  NOT NULL,
+  sha256       TEXT     NOT NULL,
+  owner        TEXT     NOT NULL,
+  created_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(filename <> '' AND size >= 0 AND LENGTH(sha256) = 64)
+);
+
+CREATE INDEX IF NOT EXISTS index_attachments_todo_id ON attachments(todo_id);
+CREATE INDEX IF NOT EXISTS index_attachments_sha256 ON attachments(sha256);
+CREATE INDEX IF NOT EXISTS index_attachments_owner_id ON attachments(owner_id);
+
+-- TODOの変更履歴. before_jsonとafter_jsonは変更前後のTODOのJSONで、作成や復元の前と削除の後はNULL.
+-- 監査のため、TODOを完全に削除しても履歴は残す. 削除後も所有者だけが読めるようにowner_idを記録する
+CREATE TABLE IF NOT EXISTS todo_events (
+  id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id     INTEGER  NOT NULL,
+  owner_id    INTEGER  NOT NULL,
+  action      TEXT     NOT NULL,
+  actor       TEXT     NOT NULL DEFAULT '',
+  before_json TEXT,
+  after_json  TEXT,
+  created_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(before_json IS NOT NULL OR after_json IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS index_todo_events_todo_id ON todo_events(todo_id);
+
+-- 発火したリマインダーを記録する. 同じremind_atのリマインダーは再起動しても一度しか発火しない
+CREATE TABLE IF NOT EXISTS reminder_events (
+  id        INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id   INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  remind_at DATETIME NOT NULL,
+  fired_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  UNIQUE(todo_id, remind_at)
+);
@@ -3,9 +3,11 @@ module github.com/TechBowl-japan/go-stations
 go 1.16
 
 require (
-	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/golang-jwt/jwt/v4 v4.5.2
+	github.com/google/go-cmp v0.5.9
 	github.com/jstemmer/go-junit-report v0.9.1
 	github.com/mattn/go-sqlite3 v1.14.7
-	github.com/mileusna/useragent v1.3.4 // indirect
-	golang.org/x/sync v0.8.0 // indirect
+	github.com/mileusna/useragent v1.3.4
+	golang.org/x/crypto v0.9.0
+	golang.org/x/sync v0.8.0
 )
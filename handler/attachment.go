@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// multipartOverhead is the size allowed for the boundaries and the headers of a multipart upload
+// in addition to the file itself.
+const multipartOverhead = 1 << 20
+
+// An AttachmentHandler implements handling REST endpoints of attachments under /todos/{id}/attachments.
+type AttachmentHandler struct {
+	svc *service.AttachmentService
+}
+
+// NewAttachmentHandler returns AttachmentHandler to be registered with TODOHandler.Handle.
+func NewAttachmentHandler(svc *service.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{
+		svc: svc,
+	}
+}
+
+// ServeTODO handles requests to /todos/{id}/attachments, /todos/{id}/attachments/{attachmentID}
+// and /todos/{id}/attachments/{attachmentID}/content.
+func (h *AttachmentHandler) ServeTODO(w http.ResponseWriter, r *http.Request, todoID int64, path string) {
+	if path == "" {
+		h.serveCollection(w, r, todoID)
+		return
+	}
+	parts := strings.SplitN(path, "/", 2)
+	id, ok := parseIDPath("/"+parts[0], "/")
+	switch {
+	case !ok:
+		http.NotFound(w, r)
+	case len(parts) == 1:
+		h.serveItem(w, r, todoID, id)
+	case parts[1] == "content":
+		h.serveContent(w, r, todoID, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveCollection handles requests to /todos/{id}/attachments.
+func (h *AttachmentHandler) serveCollection(w http.ResponseWriter, r *http.Request, todoID int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Read(ctx, &model.ReadAttachmentRequest{TODOID: todoID})
+
+	case http.MethodPost:
+		if max := h.svc.MaxSize(); max > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, max+multipartOverhead)
+		}
+		var part *multipart.Part
+		if part, err = filePart(r); err != nil {
+			if tooLarge := bodyTooLarge(err); tooLarge != nil {
+				writeError(w, tooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer part.Close()
+		resp, err = h.Create(ctx, todoID, part.FileName(), part)
+		if tooLarge := bodyTooLarge(err); tooLarge != nil {
+			err = tooLarge
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// filePart returns the part named "file" of the multipart/form-data body of r.
+// ファイル全体をメモリや一時ファイルに読み込まないように、パートを順に読む.
+func filePart(r *http.Request) (*multipart.Part, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, &model.ErrInvalid{Reason: "file is required"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// bodyTooLarge returns ErrTooLarge if err is caused by reading more than http.MaxBytesReader allows, or nil otherwise.
+// http.MaxBytesErrorはGo 1.19からなので、エラーメッセージで判定する.
+func bodyTooLarge(err error) error {
+	if err == nil || !strings.Contains(err.Error(), "http: request body too large") {
+		return nil
+	}
+	return &model.ErrTooLarge{Reason: "request body too large"}
+}
+
+// serveItem handles requests to /todos/{id}/attachments/{attachmentID}.
+func (h *AttachmentHandler) serveItem(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Get(ctx, &model.GetAttachmentRequest{TODOID: todoID, ID: id})
+
+	case http.MethodDelete:
+		resp, err = h.Delete(ctx, &model.DeleteAttachmentRequest{TODOID: todoID, ID: id})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveContent handles requests to /todos/{id}/attachments/{attachmentID}/content.
+// Rangeリクエストや条件付きリクエストはhttp.ServeContentで処理する.
+func (h *AttachmentHandler) serveContent(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	attachment, f, err := h.svc.OpenAttachment(r.Context(), todoID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+
+	// アップロードされたHTMLなどがブラウザで実行されないように、常にダウンロードさせる
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// 内容が同じなら同じETagになる
+	w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	http.ServeContent(w, r, attachment.Filename, attachment.CreatedAt, f)
+}
+
+// Create handles the endpoint that attaches the content to the TODO.
+func (h *AttachmentHandler) Create(ctx context.Context, todoID int64, filename string, content io.Reader) (*model.CreateAttachmentResponse, error) {
+	attachment, err := h.svc.CreateAttachment(ctx, todoID, filename, content)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateAttachmentResponse{Attachment: *attachment}, nil
+}
+
+// Read handles the endpoint that reads the attachments of the TODO.
+func (h *AttachmentHandler) Read(ctx context.Context, req *model.ReadAttachmentRequest) (*model.ReadAttachmentResponse, error) {
+	attachments, err := h.svc.ReadAttachment(ctx, req.TODOID)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.Attachment を []model.Attachment に変換
+	resp := model.ReadAttachmentResponse{Attachments: make([]model.Attachment, len(attachments))}
+	for i, attachment := range attachments {
+		resp.Attachments[i] = *attachment
+	}
+	return &resp, nil
+}
+
+// Get handles the endpoint that reads the metadata of the attachment.
+func (h *AttachmentHandler) Get(ctx context.Context, req *model.GetAttachmentRequest) (*model.GetAttachmentResponse, error) {
+	attachment, err := h.svc.GetAttachment(ctx, req.TODOID, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetAttachmentResponse{Attachment: *attachment}, nil
+}
+
+// Delete handles the endpoint that deletes the attachment.
+func (h *AttachmentHandler) Delete(ctx context.Context, req *model.DeleteAttachmentRequest) (*model.DeleteAttachmentResponse, error) {
+	if err := h.svc.DeleteAttachment(ctx, req.TODOID, req.ID); err != nil {
+		return nil, err
+	}
+	return &model.DeleteAttachmentResponse{}, nil
+}
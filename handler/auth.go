@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// An AuthHandler implements handling REST endpoints of the login flow with access tokens and refresh tokens.
+// これらのエンドポイントはBasic認証やBearerトークンを必要としない.
+type AuthHandler struct {
+	svc *service.AuthService
+}
+
+// NewAuthHandler returns AuthHandler based http.Handler.
+func NewAuthHandler(svc *service.AuthService) *AuthHandler {
+	return &AuthHandler{
+		svc: svc,
+	}
+}
+
+// ServeHTTP handles HTTP requests and routes them to the appropriate method.
+func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/.well-known/jwks.json" {
+		h.serveJWKS(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.URL.Path {
+	case "/auth/login":
+		var req model.LoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err = h.Login(ctx, &req)
+
+	case "/auth/refresh":
+		var req model.RefreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err = h.Refresh(ctx, &req)
+
+	case "/auth/logout":
+		var req model.LogoutRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err = h.Logout(ctx, &req)
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	// トークンを含むレスポンスはキャッシュさせない
+	w.Header().Set("Cache-Control", "no-store")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveJWKS handles requests to /.well-known/jwks.json.
+func (h *AuthHandler) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := h.ReadJWKS(r.Context(), &model.ReadJWKSRequest{})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Login handles the endpoint that issues tokens for the user and the password.
+func (h *AuthHandler) Login(ctx context.Context, req *model.LoginRequest) (*model.LoginResponse, error) {
+	token, err := h.svc.Login(ctx, req.Name, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &model.LoginResponse{AuthToken: *token}, nil
+}
+
+// Refresh handles the endpoint that exchanges the refresh token for new tokens.
+func (h *AuthHandler) Refresh(ctx context.Context, req *model.RefreshRequest) (*model.RefreshResponse, error) {
+	token, err := h.svc.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return &model.RefreshResponse{AuthToken: *token}, nil
+}
+
+// Logout handles the endpoint that revokes the refresh token.
+func (h *AuthHandler) Logout(ctx context.Context, req *model.LogoutRequest) (*model.LogoutResponse, error) {
+	if err := h.svc.Logout(ctx, req.RefreshToken); err != nil {
+		return nil, err
+	}
+	return &model.LogoutResponse{}, nil
+}
+
+// ReadJWKS handles the endpoint that publishes the keys verifying access tokens.
+func (h *AuthHandler) ReadJWKS(ctx context.Context, req *model.ReadJWKSRequest) (*model.ReadJWKSResponse, error) {
+	keys, err := h.svc.ReadJWKS(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.JWK を []model.JWK に変換
+	resp := model.ReadJWKSResponse{Keys: make([]model.JWK, len(keys))}
+	for i, key := range keys {
+		resp.Keys[i] = *key
+	}
+	return &resp, nil
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A ChecklistHandler implements handling REST endpoints of checklists under /todos/{id}/checklist.
+type ChecklistHandler struct {
+	svc *service.ChecklistService
+}
+
+// NewChecklistHandler returns ChecklistHandler to be registered with TODOHandler.Handle.
+func NewChecklistHandler(svc *service.ChecklistService) *ChecklistHandler {
+	return &ChecklistHandler{
+		svc: svc,
+	}
+}
+
+// ServeTODO handles requests to /todos/{id}/checklist, /todos/{id}/checklist/reorder,
+// /todos/{id}/checklist/{itemID} and /todos/{id}/checklist/{itemID}/toggle.
+func (h *ChecklistHandler) ServeTODO(w http.ResponseWriter, r *http.Request, todoID int64, path string) {
+	switch path {
+	case "":
+		h.serveCollection(w, r, todoID)
+		return
+	case "reorder":
+		h.serveReorder(w, r, todoID)
+		return
+	}
+	parts := strings.SplitN(path, "/", 2)
+	id, ok := parseIDPath("/"+parts[0], "/")
+	switch {
+	case !ok:
+		http.NotFound(w, r)
+	case len(parts) == 1:
+		h.serveItem(w, r, todoID, id)
+	case parts[1] == "toggle":
+		h.serveToggle(w, r, todoID, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveCollection handles requests to /todos/{id}/checklist.
+func (h *ChecklistHandler) serveCollection(w http.ResponseWriter, r *http.Request, todoID int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Read(ctx, &model.ReadChecklistRequest{TODOID: todoID})
+
+	case http.MethodPost:
+		var req model.CreateChecklistItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TODOID = todoID
+		resp, err = h.Create(ctx, &req)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveReorder handles requests to /todos/{id}/checklist/reorder.
+func (h *ChecklistHandler) serveReorder(w http.ResponseWriter, r *http.Request, todoID int64) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.ReorderChecklistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.TODOID = todoID
+	resp, err := h.Reorder(r.Context(), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveItem handles requests to /todos/{id}/checklist/{itemID}.
+func (h *ChecklistHandler) serveItem(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodPut:
+		var req model.UpdateChecklistItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TODOID, req.ID = todoID, id
+		resp, err = h.Update(ctx, &req)
+
+	case http.MethodDelete:
+		resp, err = h.Delete(ctx, &model.DeleteChecklistItemRequest{TODOID: todoID, ID: id})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveToggle handles requests to /todos/{id}/checklist/{itemID}/toggle.
+func (h *ChecklistHandler) serveToggle(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := h.Toggle(r.Context(), &model.ToggleChecklistItemRequest{TODOID: todoID, ID: id})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Create handles the endpoint that appends an item to the checklist of the TODO.
+func (h *ChecklistHandler) Create(ctx context.Context, req *model.CreateChecklistItemRequest) (*model.CreateChecklistItemResponse, error) {
+	if req.Body == "" {
+		return nil, &model.ErrInvalid{Reason: "body is required"}
+	}
+	item, err := h.svc.CreateChecklistItem(ctx, req.TODOID, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateChecklistItemResponse{Item: *item}, nil
+}
+
+// Read handles the endpoint that reads the checklist of the TODO.
+func (h *ChecklistHandler) Read(ctx context.Context, req *model.ReadChecklistRequest) (*model.ReadChecklistResponse, error) {
+	items, err := h.svc.ReadChecklist(ctx, req.TODOID)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.ChecklistItem を []model.ChecklistItem に変換
+	resp := model.ReadChecklistResponse{Items: make([]model.ChecklistItem, len(items))}
+	for i, item := range items {
+		resp.Items[i] = *item
+	}
+	return &resp, nil
+}
+
+// Update handles the endpoint that edits the item in the checklist.
+func (h *ChecklistHandler) Update(ctx context.Context, req *model.UpdateChecklistItemRequest) (*model.UpdateChecklistItemResponse, error) {
+	if req.Body == "" {
+		return nil, &model.ErrInvalid{Reason: "body is required"}
+	}
+	item, err := h.svc.UpdateChecklistItem(ctx, req.TODOID, req.ID, req.Body, req.Done)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateChecklistItemResponse{Item: *item}, nil
+}
+
+// Toggle handles the endpoint that flips the state of the item in the checklist.
+func (h *ChecklistHandler) Toggle(ctx context.Context, req *model.ToggleChecklistItemRequest) (*model.ToggleChecklistItemResponse, error) {
+	item, err := h.svc.ToggleChecklistItem(ctx, req.TODOID, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ToggleChecklistItemResponse{Item: *item}, nil
+}
+
+// Reorder handles the endpoint that sorts the checklist of the TODO.
+func (h *ChecklistHandler) Reorder(ctx context.Context, req *model.ReorderChecklistRequest) (*model.ReorderChecklistResponse, error) {
+	items, err := h.svc.ReorderChecklist(ctx, req.TODOID, req.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.ChecklistItem を []model.ChecklistItem に変換
+	resp := model.ReorderChecklistResponse{Items: make([]model.ChecklistItem, len(items))}
+	for i, item := range items {
+		resp.Items[i] = *item
+	}
+	return &resp, nil
+}
+
+// Delete handles the endpoint that removes the item from the checklist.
+func (h *ChecklistHandler) Delete(ctx context.Context, req *model.DeleteChecklistItemRequest) (*model.DeleteChecklistItemResponse, error) {
+	if err := h.svc.DeleteChecklistItem(ctx, req.TODOID, req.ID); err != nil {
+		return nil, err
+	}
+	return &model.DeleteChecklistItemResponse{}, nil
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A CommentHandler implements handling REST endpoints of comments under /todos/{id}/comments.
+type CommentHandler struct {
+	svc       *service.CommentService
+	cursorKey []byte
+}
+
+// NewCommentHandler returns CommentHandler to be registered with TODOHandler.Handle.
+// cursorKeyはページングのcursorの署名に使う.
+func NewCommentHandler(svc *service.CommentService, cursorKey []byte) *CommentHandler {
+	return &CommentHandler{
+		svc:       svc,
+		cursorKey: cursorKey,
+	}
+}
+
+// ServeTODO handles requests to /todos/{id}/comments and /todos/{id}/comments/{commentID}.
+func (h *CommentHandler) ServeTODO(w http.ResponseWriter, r *http.Request, todoID int64, path string) {
+	if path == "" {
+		h.serveCollection(w, r, todoID)
+		return
+	}
+	id, ok := parseIDPath("/"+path, "/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.serveItem(w, r, todoID, id)
+}
+
+// serveCollection handles requests to /todos/{id}/comments.
+func (h *CommentHandler) serveCollection(w http.ResponseWriter, r *http.Request, todoID int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		req := model.ReadCommentRequest{TODOID: todoID}
+		query := r.URL.Query()
+		if req.Size, err = parsePageSize(query, 5); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if v := query.Get("cursor"); v != "" {
+			var c model.CommentCursor
+			if err := decodeSignedCursor(h.cursorKey, v, &c); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.Cursor = &c
+		}
+		resp, err = h.Read(ctx, &req)
+
+	case http.MethodPost:
+		var req model.CreateCommentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TODOID = todoID
+		resp, err = h.Create(ctx, &req)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveItem handles requests to /todos/{id}/comments/{commentID}.
+func (h *CommentHandler) serveItem(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Get(ctx, &model.GetCommentRequest{TODOID: todoID, ID: id})
+
+	case http.MethodPut:
+		var req model.UpdateCommentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TODOID, req.ID = todoID, id
+		resp, err = h.Update(ctx, &req)
+
+	case http.MethodDelete:
+		resp, err = h.Delete(ctx, &model.DeleteCommentRequest{TODOID: todoID, ID: id})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Create handles the endpoint that creates the comment.
+func (h *CommentHandler) Create(ctx context.Context, req *model.CreateCommentRequest) (*model.CreateCommentResponse, error) {
+	comment, err := h.svc.CreateComment(ctx, req.TODOID, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateCommentResponse{Comment: *comment}, nil
+}
+
+// Read handles the endpoint that reads the comments on the TODO.
+func (h *CommentHandler) Read(ctx context.Context, req *model.ReadCommentRequest) (*model.ReadCommentResponse, error) {
+	var afterID int64
+	if req.Cursor != nil {
+		afterID = req.Cursor.ID
+	}
+	// 続きのページがあるかを判定するため1件多く読み込む
+	comments, err := h.svc.ReadComment(ctx, req.TODOID, afterID, req.Size+1)
+	if err != nil {
+		return nil, err
+	}
+	more := int64(len(comments)) > req.Size
+	if more {
+		comments = comments[:req.Size]
+	}
+
+	// []*model.Comment を []model.Comment に変換
+	resp := model.ReadCommentResponse{Comments: make([]model.Comment, len(comments))}
+	for i, comment := range comments {
+		resp.Comments[i] = *comment
+	}
+	if more && len(comments) > 0 {
+		last := comments[len(comments)-1]
+		if resp.NextCursor, err = encodeSignedCursor(h.cursorKey, &model.CommentCursor{ID: last.ID}); err != nil {
+			return nil, err
+		}
+	}
+	return &resp, nil
+}
+
+// Get handles the endpoint that reads the comment.
+func (h *CommentHandler) Get(ctx context.Context, req *model.GetCommentRequest) (*model.GetCommentResponse, error) {
+	comment, err := h.svc.GetComment(ctx, req.TODOID, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetCommentResponse{Comment: *comment}, nil
+}
+
+// Update handles the endpoint that edits the comment.
+func (h *CommentHandler) Update(ctx context.Context, req *model.UpdateCommentRequest) (*model.UpdateCommentResponse, error) {
+	comment, err := h.svc.UpdateComment(ctx, req.TODOID, req.ID, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateCommentResponse{Comment: *comment}, nil
+}
+
+// Delete handles the endpoint that deletes the comment.
+func (h *CommentHandler) Delete(ctx context.Context, req *model.DeleteCommentRequest) (*model.DeleteCommentResponse, error) {
+	if err := h.svc.DeleteComment(ctx, req.TODOID, req.ID); err != nil {
+		return nil, err
+	}
+	return &model.DeleteCommentResponse{}, nil
+}
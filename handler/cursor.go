@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// errInvalidCursor is returned when a cursor is malformed or its signature does not match.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor encodes c into an opaque string signed with the cursor key.
+func (h *TODOHandler) encodeCursor(c *model.TODOCursor) (string, error) {
+	return encodeSignedCursor(h.cursorKey, c)
+}
+
+// decodeCursor decodes a string returned by encodeCursor and verifies its signature.
+func (h *TODOHandler) decodeCursor(s string) (*model.TODOCursor, error) {
+	var c model.TODOCursor
+	if err := decodeSignedCursor(h.cursorKey, s, &c); err != nil || !c.Sort.Valid() {
+		return nil, errInvalidCursor
+	}
+	return &c, nil
+}
+
+// encodeSignedCursor encodes the cursor c into an opaque string signed with key.
+// 形式は base64(JSON) + "." + base64(HMAC-SHA256).
+func encodeSignedCursor(key []byte, c interface{}) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signCursor(key, payload)), nil
+}
+
+// decodeSignedCursor verifies the signature of a string returned by encodeSignedCursor and decodes it into c.
+func decodeSignedCursor(key []byte, s string, c interface{}) error {
+	i := strings.IndexByte(s, '.')
+	if i < 0 {
+		return errInvalidCursor
+	}
+	payload := s[:i]
+	sig, err := base64.RawURLEncoding.DecodeString(s[i+1:])
+	if err != nil || !hmac.Equal(sig, signCursor(key, payload)) {
+		return errInvalidCursor
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return errInvalidCursor
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return errInvalidCursor
+	}
+	return nil
+}
+
+// signCursor returns the HMAC-SHA256 of payload.
+func signCursor(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// cursorAt returns the cursor pointing at todo in the given sort order.
+func cursorAt(todo *model.TODO, sort model.TODOSort, backward bool) *model.TODOCursor {
+	c := &model.TODOCursor{Sort: sort, ID: todo.ID, Backward: backward}
+	switch sort.Column() {
+	case "created_at":
+		c.Key = &todo.CreatedAt
+	case "updated_at":
+		c.Key = &todo.UpdatedAt
+	case "priority":
+		level, _ := todo.Priority.Level()
+		num := float64(level)
+		c.Num = &num
+	case "position":
+		c.Num = &todo.Position
+	default:
+		// カスタムフィールドの値がない場合はKey、NumとStrのいずれも設定しない
+		if name, ok := sort.Field(); ok {
+			switch v := todo.Fields[name].(type) {
+			case float64:
+				c.Num = &v
+			case string:
+				c.Str = &v
+			}
+		}
+	}
+	return c
+}
+
+// setPageLinks sets the RFC 8288 Link header pointing at the next, previous and first pages.
+// フィルタなどのクエリパラメータは引き継ぎ、cursorだけを差し替える.
+func setPageLinks(w http.ResponseWriter, r *http.Request, resp *model.ReadTODOResponse) {
+	link := func(cursor, rel string) string {
+		query := r.URL.Query()
+		query.Del("prev_id")
+		query.Del("cursor")
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		return "<" + u.String() + `>; rel="` + rel + `"`
+	}
+
+	links := []string{link("", "first")}
+	if resp.PrevCursor != "" {
+		links = append(links, link(resp.PrevCursor, "prev"))
+	}
+	if resp.NextCursor != "" {
+		links = append(links, link(resp.NextCursor, "next"))
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
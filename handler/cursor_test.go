@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCursor(t *testing.T) {
+	t.Parallel()
+
+	h := NewTODOHandler(nil, []byte("secret"))
+	key := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	want := &model.TODOCursor{Sort: model.TODOSortCreatedAtDesc, Key: &key, ID: 3, Backward: true}
+
+	s, err := h.encodeCursor(want)
+	if err != nil {
+		t.Fatal("failed to encode cursor, err =", err)
+	}
+
+	cases := map[string]struct {
+		h       *TODOHandler
+		cursor  string
+		wantErr bool
+	}{
+		"Round trip":        {h: h, cursor: s},
+		"Other key":         {h: NewTODOHandler(nil, []byte("other")), cursor: s, wantErr: true},
+		"Tampered payload":  {h: h, cursor: "x" + s[1:], wantErr: true},
+		"Missing signature": {h: h, cursor: s[:strings.IndexByte(s, '.')], wantErr: true},
+		"Not a cursor":      {h: h, cursor: "garbage", wantErr: true},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := c.h.decodeCursor(c.cursor)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("expected error, given = %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("unexpected error, err =", err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected value (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
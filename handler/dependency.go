@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A DependencyHandler implements handling REST endpoints of dependencies under /todos/{id}/dependencies.
+type DependencyHandler struct {
+	svc *service.TODOService
+}
+
+// NewDependencyHandler returns DependencyHandler to be registered with TODOHandler.Handle.
+func NewDependencyHandler(svc *service.TODOService) *DependencyHandler {
+	return &DependencyHandler{
+		svc: svc,
+	}
+}
+
+// ServeTODO handles requests to /todos/{id}/dependencies and /todos/{id}/dependencies/{dependsOnID}.
+func (h *DependencyHandler) ServeTODO(w http.ResponseWriter, r *http.Request, todoID int64, path string) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		resp, err = h.Read(ctx, &model.ReadDependencyRequest{TODOID: todoID})
+
+	case path == "" && r.Method == http.MethodPost:
+		var req model.CreateDependencyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TODOID = todoID
+		resp, err = h.Create(ctx, &req)
+
+	case path == "":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+
+	default:
+		dependsOnID, ok := parseIDPath("/"+path, "/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		resp, err = h.Delete(ctx, &model.DeleteDependencyRequest{TODOID: todoID, DependsOnID: dependsOnID})
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Read handles the endpoint that reads the dependencies of the TODO.
+func (h *DependencyHandler) Read(ctx context.Context, req *model.ReadDependencyRequest) (*model.ReadDependencyResponse, error) {
+	blockedBy, blocks, err := h.svc.ReadDependency(ctx, req.TODOID)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.TODO を []model.TODO に変換
+	resp := model.ReadDependencyResponse{
+		BlockedBy: make([]model.TODO, len(blockedBy)),
+		Blocks:    make([]model.TODO, len(blocks)),
+	}
+	for i, todo := range blockedBy {
+		resp.BlockedBy[i] = *todo
+	}
+	for i, todo := range blocks {
+		resp.Blocks[i] = *todo
+	}
+	return &resp, nil
+}
+
+// Create handles the endpoint that adds the dependency to the TODO.
+func (h *DependencyHandler) Create(ctx context.Context, req *model.CreateDependencyRequest) (*model.CreateDependencyResponse, error) {
+	todo, err := h.svc.CreateDependency(ctx, req.TODOID, req.DependsOnID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateDependencyResponse{TODO: *todo}, nil
+}
+
+// Delete handles the endpoint that removes the dependency from the TODO.
+func (h *DependencyHandler) Delete(ctx context.Context, req *model.DeleteDependencyRequest) (*model.DeleteDependencyResponse, error) {
+	todo, err := h.svc.DeleteDependency(ctx, req.TODOID, req.DependsOnID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.DeleteDependencyResponse{TODO: *todo}, nil
+}
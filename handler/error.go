@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/mattn/go-sqlite3"
+)
+
+// errorStatus returns the HTTP status code corresponding to err.
+func errorStatus(err error) int {
+	var (
+		unauthorized       *model.ErrUnauthorized
+		notFound           *model.ErrNotFound
+		forbidden          *model.ErrForbidden
+		conflict           *model.ErrConflict
+		preconditionFailed *model.ErrPreconditionFailed
+		unavailable        *model.ErrUnavailable
+		tooLarge           *model.ErrTooLarge
+		invalid            *model.ErrInvalid
+		sqliteErr          sqlite3.Error
+	)
+	switch {
+	case errors.As(err, &unauthorized):
+		return http.StatusUnauthorized
+	case errors.As(err, &notFound):
+		return http.StatusNotFound
+	case errors.As(err, &forbidden):
+		return http.StatusForbidden
+	case errors.As(err, &conflict):
+		return http.StatusConflict
+	case errors.As(err, &preconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.As(err, &unavailable):
+		return http.StatusNotImplemented
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &invalid):
+		return http.StatusBadRequest
+	case errors.As(err, &sqliteErr) && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey):
+		// UNIQUE制約や主キーに違反した場合は既存のリソースと競合している
+		return http.StatusConflict
+	case errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint:
+		// CHECK制約などに違反した場合はリクエストの内容が不正
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError writes err with the status code returned by errorStatus.
+func writeError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), errorStatus(err))
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// todoETag returns the strong entity tag of the TODO derived from its version.
+func todoETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// matchETag reports whether etag is listed in the If-Match or If-None-Match header value.
+// weakがtrueの場合は弱い比較(W/を無視する)を行い、falseの場合は強い比較を行う.
+func matchETag(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			// 強い比較では弱いETagは一致しない
+			if !weak {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion evaluates the If-Match header of r against the TODO.
+// If-Matchがない場合は0を返し、一致した場合は更新の条件となるバージョンを返す.
+func (h *TODOHandler) ifMatchVersion(ctx context.Context, r *http.Request, id int64) (int64, error) {
+	header := strings.Join(r.Header.Values("If-Match"), ",")
+	if header == "" {
+		return 0, nil
+	}
+
+	_, version, err := h.svc.GetVersionedTODO(ctx, id)
+	// 対象が存在しない場合もIf-Matchは失敗する
+	var notFound *model.ErrNotFound
+	if errors.As(err, &notFound) {
+		return 0, &model.ErrPreconditionFailed{}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !matchETag(header, todoETag(version), false) {
+		return 0, &model.ErrPreconditionFailed{}
+	}
+	return version, nil
+}
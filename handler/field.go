@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A CustomFieldHandler implements handling REST endpoints of custom fields under /projects/{id}/fields.
+type CustomFieldHandler struct {
+	svc *service.CustomFieldService
+}
+
+// NewCustomFieldHandler returns CustomFieldHandler to be passed to NewProjectHandler.
+func NewCustomFieldHandler(svc *service.CustomFieldService) *CustomFieldHandler {
+	return &CustomFieldHandler{
+		svc: svc,
+	}
+}
+
+// ServeProject handles requests to /projects/{id}/fields and /projects/{id}/fields/{fieldID}.
+// pathは/projects/{id}/fields/より後ろの部分になる.
+func (h *CustomFieldHandler) ServeProject(w http.ResponseWriter, r *http.Request, projectID int64, path string) {
+	if path == "" {
+		h.serveCollection(w, r, projectID)
+		return
+	}
+	id, ok := parseIDPath("/"+path, "/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.serveItem(w, r, projectID, id)
+}
+
+// serveCollection handles requests to /projects/{id}/fields.
+func (h *CustomFieldHandler) serveCollection(w http.ResponseWriter, r *http.Request, projectID int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Read(ctx, &model.ReadCustomFieldRequest{ProjectID: projectID})
+
+	case http.MethodPost:
+		var req model.CreateCustomFieldRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.ProjectID = projectID
+		resp, err = h.Create(ctx, &req)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveItem handles requests to /projects/{id}/fields/{fieldID}.
+func (h *CustomFieldHandler) serveItem(w http.ResponseWriter, r *http.Request, projectID, id int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Get(ctx, &model.GetCustomFieldRequest{ProjectID: projectID, ID: id})
+
+	case http.MethodPut:
+		var req model.UpdateCustomFieldRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.ProjectID, req.ID = projectID, id
+		resp, err = h.Update(ctx, &req)
+
+	case http.MethodDelete:
+		resp, err = h.Delete(ctx, &model.DeleteCustomFieldRequest{ProjectID: projectID, ID: id})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Create handles the endpoint that creates the custom field of the project.
+func (h *CustomFieldHandler) Create(ctx context.Context, req *model.CreateCustomFieldRequest) (*model.CreateCustomFieldResponse, error) {
+	field, err := h.svc.CreateCustomField(ctx, req.ProjectID, req.Name, req.Type, req.Options)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateCustomFieldResponse{Field: *field}, nil
+}
+
+// Read handles the endpoint that reads the custom fields of the project.
+func (h *CustomFieldHandler) Read(ctx context.Context, req *model.ReadCustomFieldRequest) (*model.ReadCustomFieldResponse, error) {
+	fields, err := h.svc.ReadCustomField(ctx, req.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.CustomField を []model.CustomField に変換
+	resp := model.ReadCustomFieldResponse{Fields: make([]model.CustomField, len(fields))}
+	for i, field := range fields {
+		resp.Fields[i] = *field
+	}
+	return &resp, nil
+}
+
+// Get handles the endpoint that reads the custom field.
+func (h *CustomFieldHandler) Get(ctx context.Context, req *model.GetCustomFieldRequest) (*model.GetCustomFieldResponse, error) {
+	field, err := h.svc.GetCustomField(ctx, req.ProjectID, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetCustomFieldResponse{Field: *field}, nil
+}
+
+// Update handles the endpoint that renames the custom field and replaces its options.
+func (h *CustomFieldHandler) Update(ctx context.Context, req *model.UpdateCustomFieldRequest) (*model.UpdateCustomFieldResponse, error) {
+	field, err := h.svc.UpdateCustomField(ctx, req.ProjectID, req.ID, req.Name, req.Options)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateCustomFieldResponse{Field: *field}, nil
+}
+
+// Delete handles the endpoint that deletes the custom field together with its values.
+func (h *CustomFieldHandler) Delete(ctx context.Context, req *model.DeleteCustomFieldRequest) (*model.DeleteCustomFieldResponse, error) {
+	if err := h.svc.DeleteCustomField(ctx, req.ProjectID, req.ID); err != nil {
+		return nil, err
+	}
+	return &model.DeleteCustomFieldResponse{}, nil
+}
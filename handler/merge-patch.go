@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// mergePatchContentType is the media type of RFC 7396 JSON Merge Patch documents.
+const mergePatchContentType = "application/merge-patch+json"
+
+// isMergePatchContentType reports whether contentType can be applied as a merge patch.
+// application/jsonもmerge patchとして扱う.
+func isMergePatchContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == mergePatchContentType || mediaType == "application/json"
+}
+
+// decodeTODOMergePatch decodes an RFC 7396 merge patch document for a TODO.
+// 存在しないメンバーは更新せず、nullはそのフィールドを既定値に戻すことを意味する.
+func decodeTODOMergePatch(r io.Reader, req *model.PatchTODORequest) error {
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+	// オブジェクト以外(nullを含む)はTODO全体を置き換えることになるので受け付けない
+	if doc == nil {
+		return fmt.Errorf("merge patch must be a JSON object")
+	}
+
+	for name, value := range doc {
+		isNull := bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+		switch name {
+		case "subject":
+			// subjectは必須なので削除できない
+			if isNull {
+				return fmt.Errorf("subject cannot be removed")
+			}
+			if err := json.Unmarshal(value, &req.Subject); err != nil {
+				return fmt.Errorf("subject: %w", err)
+			}
+		case "description":
+			description := ""
+			if !isNull {
+				if err := json.Unmarshal(value, &description); err != nil {
+					return fmt.Errorf("description: %w", err)
+				}
+			}
+			req.Description = &description
+		case "project_id":
+			// nullの場合はInboxに戻す
+			projectID := model.InboxProjectID
+			if !isNull {
+				if err := json.Unmarshal(value, &projectID); err != nil {
+					return fmt.Errorf("project_id: %w", err)
+				}
+			}
+			req.ProjectID = &projectID
+		case "parent_id":
+			// nullの場合は親から外す
+			var parentID int64
+			if !isNull {
+				if err := json.Unmarshal(value, &parentID); err != nil {
+					return fmt.Errorf("parent_id: %w", err)
+				}
+				if parentID <= 0 {
+					return fmt.Errorf("parent_id must be positive")
+				}
+			}
+			req.ParentID = &parentID
+		case "rrule":
+			// nullの場合は繰り返しをやめる
+			rule := ""
+			if !isNull {
+				if err := json.Unmarshal(value, &rule); err != nil {
+					return fmt.Errorf("rrule: %w", err)
+				}
+			}
+			req.RRule = &rule
+		case "priority":
+			// nullの場合は優先度なしに戻す
+			priority := model.TODOPriorityNone
+			if !isNull {
+				if err := json.Unmarshal(value, &priority); err != nil {
+					return fmt.Errorf("priority: %w", err)
+				}
+			}
+			req.Priority = &priority
+		case "fields":
+			// 個々のフィールドのnullはその値を削除する. fields自体は削除できない
+			if isNull {
+				return fmt.Errorf("fields cannot be removed")
+			}
+			if err := json.Unmarshal(value, &req.Fields); err != nil {
+				return fmt.Errorf("fields: %w", err)
+			}
+		case "due_at", "remind_at":
+			// nullの場合は日時を削除する
+			var t time.Time
+			if !isNull {
+				if err := json.Unmarshal(value, &t); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			if name == "due_at" {
+				req.DueAt = &t
+			} else {
+				req.RemindAt = &t
+			}
+		default:
+			return fmt.Errorf("unknown or read-only member %q", name)
+		}
+	}
+	return nil
+}
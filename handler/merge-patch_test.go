@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeTODOMergePatch(t *testing.T) {
+	t.Parallel()
+
+	str := func(s string) *string { return &s }
+	id := func(i int64) *int64 { return &i }
+	at := func(t time.Time) *time.Time { return &t }
+	priority := func(p model.TODOPriority) *model.TODOPriority { return &p }
+
+	cases := map[string]struct {
+		doc     string
+		want    model.PatchTODORequest
+		wantErr bool
+	}{
+		"Empty":               {doc: `{}`},
+		"Subject only":        {doc: `{"subject":"s"}`, want: model.PatchTODORequest{Subject: str("s")}},
+		"Description only":    {doc: `{"description":"d"}`, want: model.PatchTODORequest{Description: str("d")}},
+		"Remove description":  {doc: `{"description":null}`, want: model.PatchTODORequest{Description: str("")}},
+		"Remove subject":      {doc: `{"subject":null}`, wantErr: true},
+		"Read-only member":    {doc: `{"id":1}`, wantErr: true},
+		"Not an object":       {doc: `["subject"]`, wantErr: true},
+		"Null document":       {doc: `null`, wantErr: true},
+		"Wrong member type":   {doc: `{"subject":1}`, wantErr: true},
+		"Empty subject value": {doc: `{"subject":""}`, want: model.PatchTODORequest{Subject: str("")}},
+		"Move project":        {doc: `{"project_id":2}`, want: model.PatchTODORequest{ProjectID: id(2)}},
+		"Remove project":      {doc: `{"project_id":null}`, want: model.PatchTODORequest{ProjectID: id(model.InboxProjectID)}},
+		"Set parent":          {doc: `{"parent_id":3}`, want: model.PatchTODORequest{ParentID: id(3)}},
+		"Remove parent":       {doc: `{"parent_id":null}`, want: model.PatchTODORequest{ParentID: id(0)}},
+		"Zero parent":         {doc: `{"parent_id":0}`, wantErr: true},
+		"Set due":             {doc: `{"due_at":"2021-04-01T09:00:00Z"}`, want: model.PatchTODORequest{DueAt: at(time.Date(2021, 4, 1, 9, 0, 0, 0, time.UTC))}},
+		"Remove reminder":     {doc: `{"remind_at":null}`, want: model.PatchTODORequest{RemindAt: at(time.Time{})}},
+		"Set rrule":           {doc: `{"rrule":"FREQ=DAILY"}`, want: model.PatchTODORequest{RRule: str("FREQ=DAILY")}},
+		"Remove rrule":        {doc: `{"rrule":null}`, want: model.PatchTODORequest{RRule: str("")}},
+		"Set priority":        {doc: `{"priority":"high"}`, want: model.PatchTODORequest{Priority: priority(model.TODOPriorityHigh)}},
+		"Remove priority":     {doc: `{"priority":null}`, want: model.PatchTODORequest{Priority: priority(model.TODOPriorityNone)}},
+		"Invalid due":         {doc: `{"due_at":"tomorrow"}`, wantErr: true},
+		"Set fields":          {doc: `{"fields":{"customer":"ACME","estimate":3,"size":null}}`, want: model.PatchTODORequest{Fields: map[string]interface{}{"customer": "ACME", "estimate": 3.0, "size": nil}}},
+		"Remove fields":       {doc: `{"fields":null}`, wantErr: true},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got model.PatchTODORequest
+			err := decodeTODOMergePatch(strings.NewReader(c.doc), &got)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("expected error, given = %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("unexpected error, err =", err)
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected value (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
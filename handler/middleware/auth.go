@@ -1,23 +1,153 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/common"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+const (
+	// basicChallenge is the WWW-Authenticate challenge of Basic authentication.
+	basicChallenge = `Basic realm="restricted"`
+	// bearerChallenge is the WWW-Authenticate challenge for invalid API tokens and access tokens.
+	bearerChallenge = `Bearer error="invalid_token"`
+	// bearerPrefix is the scheme of the Authorization header with an API token or an access token.
+	bearerPrefix = "Bearer "
 )
 
-func BasicAuthMiddleware(h http.Handler, username, password string) http.Handler {
+func BasicAuthMiddleware(h http.Handler, users *service.UserService) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		// Basic認証のユーザー名とパスワードを取得
-		user, pass, ok := r.BasicAuth()
-
-		// Basic認証情報がない(または空で送られくる)、またはユーザー名とパスワードが一致しない場合は401 Unauthorizedを返す
-		if !ok || user == "" || pass == "" || user != username || pass != password {
-			// WWW-Authenticate ヘッダーを設定
-			w.Header().Set("WWW-Authenticate", `Basic realm="restricted"`)
-			// 401 Unauthorized ステータスコードを設定
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		name, pass, ok := r.BasicAuth()
+
+		// Basic認証情報がない(または空で送られくる)場合は401 Unauthorizedを返す
+		if !ok || name == "" || pass == "" {
+			unauthorized(w, basicChallenge)
+			return
+		}
+
+		// ユーザーが存在しない、またはパスワードが一致しない場合も401 Unauthorizedを返す
+		user, err := users.Authenticate(r.Context(), name, pass)
+		if err != nil {
+			authError(w, err, basicChallenge)
+			return
+		}
+
+		h.ServeHTTP(w, withUser(r, user))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// BearerAuthMiddleware authenticates requests with the API token or the access token in the Authorization header.
+// Bearerトークンがないリクエストはfallbackに任せ、Basic認証などで認証できるようにする.
+func BearerAuthMiddleware(h http.Handler, tokens *service.TokenService, sessions *service.AuthService, fallback http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		secret := strings.TrimSpace(header[len(bearerPrefix):])
+
+		// JWTはピリオドで区切られた3つの部分からなるので、APIトークンと区別できる.
+		// ログインで発行したアクセストークンはパスワードと同じくスコープで制限しない
+		if strings.Count(secret, ".") == 2 {
+			user, err := sessions.Authenticate(r.Context(), secret)
+			if err != nil {
+				authError(w, err, bearerChallenge)
+				return
+			}
+			h.ServeHTTP(w, withUser(r, user))
+			return
+		}
+
+		// 存在しない、失効した、または期限切れのトークンは401 Unauthorizedを返す
+		user, scopes, err := tokens.Authenticate(r.Context(), secret)
+		if err != nil {
+			authError(w, err, bearerChallenge)
+			return
+		}
+
+		// トークンのスコープをcontextに格納し、ScopeMiddlewareで確認する
+		names := make([]string, len(scopes))
+		for i, scope := range scopes {
+			names[i] = string(scope)
+		}
+		r = withUser(r, user)
+		h.ServeHTTP(w, r.WithContext(common.SetScopes(r.Context(), names)))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// ScopeMiddleware rejects requests authenticated with an API token that lacks the scope.
+// GETとHEADにはread、それ以外のメソッドにはwriteのスコープが必要で、adminはすべてを許可する.
+// パスワードで認証したリクエストはスコープで制限しない.
+func ScopeMiddleware(h http.Handler, read, write model.TokenScope) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		scopes := common.GetScopes(r.Context())
+		if scopes == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		required := write
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			required = read
+		}
+		for _, scope := range scopes {
+			if scope == string(required) || scope == string(model.TokenScopeAdmin) {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+string(required)+`"`)
+		http.Error(w, "insufficient scope", http.StatusForbidden)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// AdminMiddleware rejects requests of users who are not an admin with 403 Forbidden.
+// パスワードやアクセストークンで認証したリクエストはスコープで制限されないので、adminのスコープとは別にユーザー自身を確認する.
+func AdminMiddleware(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if !common.IsAdmin(r.Context()) {
+			http.Error(w, "admin role is required", http.StatusForbidden)
 			return
 		}
 		h.ServeHTTP(w, r)
 	}
 	return http.HandlerFunc(fn)
 }
+
+// withUser returns r with the authenticated user in its context.
+// 名前は変更履歴に記録し、IDでTODOを絞り込む. 管理者かどうかはAdminMiddlewareで確認する.
+func withUser(r *http.Request, user *model.User) *http.Request {
+	ctx := common.SetActor(r.Context(), user.Name)
+	ctx = common.SetUserID(ctx, user.ID)
+	ctx = common.SetAdmin(ctx, user.Admin)
+	return r.WithContext(ctx)
+}
+
+// authError writes 401 Unauthorized with the challenge if err is ErrUnauthorized, or 500 Internal Server Error otherwise.
+func authError(w http.ResponseWriter, err error, challenge string) {
+	var unauthorizedErr *model.ErrUnauthorized
+	if !errors.As(err, &unauthorizedErr) {
+		log.Println("auth: failed to authenticate, err =", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	unauthorized(w, challenge)
+}
+
+// unauthorized writes 401 Unauthorized with the challenge in the WWW-Authenticate header.
+func unauthorized(w http.ResponseWriter, challenge string) {
+	// WWW-Authenticate ヘッダーを設定
+	w.Header().Set("WWW-Authenticate", challenge)
+	// 401 Unauthorized ステータスコードを設定
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
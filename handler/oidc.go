@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// oidcStateCookie is the cookie that binds a login to the user agent that started it.
+const oidcStateCookie = "oidc_state"
+
+// An OIDCHandler implements handling REST endpoints of the login with an OpenID Connect identity provider.
+// これらのエンドポイントはBasic認証やBearerトークンを必要としない.
+type OIDCHandler struct {
+	svc *service.OIDCService
+}
+
+// NewOIDCHandler returns OIDCHandler based http.Handler.
+func NewOIDCHandler(svc *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		svc: svc,
+	}
+}
+
+// ServeHTTP handles HTTP requests and routes them to the appropriate method.
+// /auth/oidc/loginでIdPにリダイレクトし、IdPから/auth/oidc/callbackに戻ってきたらトークンを発行する.
+func (h *OIDCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/auth/oidc/login":
+		h.serveLogin(w, r)
+	case "/auth/oidc/callback":
+		h.serveCallback(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveLogin handles requests to /auth/oidc/login.
+func (h *OIDCHandler) serveLogin(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.Login(r.Context(), &model.OIDCLoginRequest{})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// stateをCookieにも保存し、ログインを始めたユーザーエージェント以外からのコールバックを拒否する
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    resp.State,
+		Path:     "/auth/oidc/",
+		MaxAge:   600, // stateの有効期間と同じ10分
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, resp.AuthorizationURL, http.StatusFound)
+}
+
+// serveCallback handles requests to /auth/oidc/callback.
+func (h *OIDCHandler) serveCallback(w http.ResponseWriter, r *http.Request) {
+	// トークンを含むレスポンスはキャッシュさせない
+	w.Header().Set("Cache-Control", "no-store")
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/auth/oidc/", MaxAge: -1})
+
+	query := r.URL.Query()
+	// ユーザーが拒否した場合などは、IdPから認可コードの代わりにerrorが渡される
+	if e := query.Get("error"); e != "" {
+		http.Error(w, "identity provider returned "+e, http.StatusUnauthorized)
+		return
+	}
+
+	req := model.OIDCCallbackRequest{State: query.Get("state"), Code: query.Get("code")}
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(req.State)) != 1 {
+		writeError(w, &model.ErrUnauthorized{})
+		return
+	}
+
+	resp, err := h.Callback(r.Context(), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Login handles the endpoint that starts the login with the identity provider.
+func (h *OIDCHandler) Login(ctx context.Context, req *model.OIDCLoginRequest) (*model.OIDCLoginResponse, error) {
+	u, state, err := h.svc.AuthCodeURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &model.OIDCLoginResponse{AuthorizationURL: u, State: state}, nil
+}
+
+// Callback handles the endpoint that issues tokens for the user authenticated by the identity provider.
+func (h *OIDCHandler) Callback(ctx context.Context, req *model.OIDCCallbackRequest) (*model.OIDCCallbackResponse, error) {
+	token, err := h.svc.Exchange(ctx, req.State, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	return &model.OIDCCallbackResponse{AuthToken: *token}, nil
+}
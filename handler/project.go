@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A ProjectHandler implements handling REST endpoints of projects.
+type ProjectHandler struct {
+	svc    *service.ProjectService
+	todos  *TODOHandler
+	fields *CustomFieldHandler
+}
+
+// NewProjectHandler returns ProjectHandler based http.Handler.
+// /projects/{id}/todosはtodosに、/projects/{id}/fieldsはfieldsに処理を任せる.
+func NewProjectHandler(svc *service.ProjectService, todos *TODOHandler, fields *CustomFieldHandler) *ProjectHandler {
+	return &ProjectHandler{
+		svc:    svc,
+		todos:  todos,
+		fields: fields,
+	}
+}
+
+// ServeHTTP handles HTTP requests and routes them to the appropriate method.
+func (h *ProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/projects" {
+		h.serveCollection(w, r)
+		return
+	}
+	if id, ok := parseIDPath(r.URL.Path, "/projects/"); ok {
+		h.serveItem(w, r, id)
+		return
+	}
+	id, sub, rest, ok := parseNestedPath(r.URL.Path, "/projects/")
+	switch {
+	case ok && sub == "todos" && rest == "":
+		h.serveTODOs(w, r, id)
+	case ok && sub == "fields":
+		h.fields.ServeProject(w, r, id, rest)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveCollection handles requests to /projects.
+func (h *ProjectHandler) serveCollection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Read(ctx, &model.ReadProjectRequest{})
+
+	case http.MethodPost:
+		var req model.CreateProjectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err = h.Create(ctx, &req)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveItem handles requests to /projects/{id}.
+func (h *ProjectHandler) serveItem(w http.ResponseWriter, r *http.Request, id int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Get(ctx, &model.GetProjectRequest{ID: id})
+
+	case http.MethodPut:
+		var req model.UpdateProjectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.ID = id
+		resp, err = h.Update(ctx, &req)
+
+	case http.MethodDelete:
+		// TODOを一緒に削除するかInboxに移動するかの指定を必須にする
+		mode := model.ProjectDeleteMode(r.URL.Query().Get("todos"))
+		resp, err = h.Delete(ctx, &model.DeleteProjectRequest{ID: id, Mode: mode})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveTODOs handles requests to /projects/{id}/todos.
+func (h *ProjectHandler) serveTODOs(w http.ResponseWriter, r *http.Request, id int64) {
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 存在しないプロジェクトは空の一覧ではなく404にする
+	if _, err := h.svc.GetProject(r.Context(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+	if r.Method == http.MethodPost {
+		h.todos.serveCreate(w, r, id)
+		return
+	}
+	h.todos.serveList(w, r, id)
+}
+
+// Create handles the endpoint that creates the Project.
+func (h *ProjectHandler) Create(ctx context.Context, req *model.CreateProjectRequest) (*model.CreateProjectResponse, error) {
+	project, err := h.svc.CreateProject(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateProjectResponse{Project: *project}, nil
+}
+
+// Read handles the endpoint that reads the Projects.
+func (h *ProjectHandler) Read(ctx context.Context, req *model.ReadProjectRequest) (*model.ReadProjectResponse, error) {
+	projects, err := h.svc.ReadProject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.Project を []model.Project に変換
+	resp := model.ReadProjectResponse{Projects: make([]model.Project, len(projects))}
+	for i, project := range projects {
+		resp.Projects[i] = *project
+	}
+	return &resp, nil
+}
+
+// Get handles the endpoint that reads the Project.
+func (h *ProjectHandler) Get(ctx context.Context, req *model.GetProjectRequest) (*model.GetProjectResponse, error) {
+	project, err := h.svc.GetProject(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetProjectResponse{Project: *project}, nil
+}
+
+// Update handles the endpoint that renames the Project.
+func (h *ProjectHandler) Update(ctx context.Context, req *model.UpdateProjectRequest) (*model.UpdateProjectResponse, error) {
+	project, err := h.svc.UpdateProject(ctx, req.ID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateProjectResponse{Project: *project}, nil
+}
+
+// Delete handles the endpoint that deletes the Project.
+func (h *ProjectHandler) Delete(ctx context.Context, req *model.DeleteProjectRequest) (*model.DeleteProjectResponse, error) {
+	if err := h.svc.DeleteProject(ctx, req.ID, req.Mode); err != nil {
+		return nil, err
+	}
+	return &model.DeleteProjectResponse{}, nil
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A ReportHandler implements handling REST endpoints of reports.
+type ReportHandler struct {
+	svc *service.TimeEntryService
+}
+
+// NewReportHandler returns ReportHandler based http.Handler.
+func NewReportHandler(svc *service.TimeEntryService) *ReportHandler {
+	return &ReportHandler{
+		svc: svc,
+	}
+}
+
+// ServeHTTP handles HTTP requests and routes them to the appropriate method.
+func (h *ReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/reports/time" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := parseTimeReportRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := h.TimeReport(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseTimeReportRequest parses the query parameters of /reports/time.
+// fromとtoはサーバーのタイムゾーンのYYYY-MM-DD形式で、省略した場合は今日までの7日間を集計する.
+func parseTimeReportRequest(r *http.Request) (*model.TimeReportRequest, error) {
+	query := r.URL.Query()
+	req := model.TimeReportRequest{
+		To:     time.Now(),
+		Period: model.TimeReportPeriod(query.Get("period")),
+		Owner:  query.Get("owner"),
+	}
+	if req.Period == "" {
+		req.Period = model.TimeReportPeriodDay
+	}
+	if !req.Period.Valid() {
+		return nil, fmt.Errorf("invalid period")
+	}
+
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{
+		{"to", &req.To},
+		{"from", &req.From},
+	} {
+		v := query.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.name, err)
+		}
+		*p.t = t
+	}
+	if req.From.IsZero() {
+		req.From = req.To.AddDate(0, 0, -6)
+	}
+	return &req, nil
+}
+
+// TimeReport handles the endpoint that aggregates the time spent on TODOs.
+func (h *ReportHandler) TimeReport(ctx context.Context, req *model.TimeReportRequest) (*model.TimeReportResponse, error) {
+	report, err := h.svc.TimeReport(ctx, req.From, req.To, req.Period, req.Owner)
+	if err != nil {
+		return nil, err
+	}
+	return &model.TimeReportResponse{TimeReport: *report}, nil
+}
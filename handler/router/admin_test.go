@@ -0,0 +1,72 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAdminRole(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		user   string
+		auth   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		"Admin creates user":                  {user: "admin", auth: "basic", method: http.MethodPost, path: "/users", body: `{"name":"carol","password":"password"}`, status: http.StatusOK},
+		"Admin lists signing keys":            {user: "admin", auth: "basic", method: http.MethodGet, path: "/signing-keys", status: http.StatusOK},
+		"Admin rotates with access token":     {user: "admin", auth: "jwt", method: http.MethodPost, path: "/signing-keys", status: http.StatusOK},
+		"User creates user":                   {user: "bob", auth: "basic", method: http.MethodPost, path: "/users", body: `{"name":"carol","password":"password"}`, status: http.StatusForbidden},
+		"User lists signing keys":             {user: "bob", auth: "basic", method: http.MethodGet, path: "/signing-keys", status: http.StatusForbidden},
+		"User rotates signing key":            {user: "bob", auth: "basic", method: http.MethodPost, path: "/signing-keys", status: http.StatusForbidden},
+		"User with access token":              {user: "bob", auth: "jwt", method: http.MethodGet, path: "/signing-keys", status: http.StatusForbidden},
+		"User with admin scoped API token":    {user: "bob", auth: "token", method: http.MethodPost, path: "/users", body: `{"name":"carol","password":"password"}`, status: http.StatusForbidden},
+		"User reads itself":                   {user: "bob", auth: "basic", method: http.MethodGet, path: "/users/me", status: http.StatusOK},
+		"User changes its password":           {user: "bob", auth: "basic", method: http.MethodPut, path: "/users/me/password", body: `{"current_password":"password","password":"password"}`, status: http.StatusOK},
+		"User reads itself with access token": {user: "bob", auth: "jwt", method: http.MethodGet, path: "/users/me", status: http.StatusOK},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, d := newTestServer(t)
+			createTestAdmin(t, d, "admin")
+			createTestUser(t, d, "bob")
+
+			user := c.user
+			var header []string
+			switch c.auth {
+			case "jwt":
+				resp, body := testRequest(t, srv, "", http.MethodPost, "/auth/login", `{"name":"`+c.user+`","password":"`+testPassword+`"}`)
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("POST /auth/login returned %d: %s", resp.StatusCode, body)
+				}
+				var token struct {
+					AccessToken string `json:"access_token"`
+				}
+				decodeBody(t, body, &token)
+				user, header = "", []string{"Authorization", "Bearer " + token.AccessToken}
+			case "token":
+				resp, body := testRequest(t, srv, c.user, http.MethodPost, "/tokens", `{"name":"admin","scopes":["admin"]}`)
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("POST /tokens returned %d: %s", resp.StatusCode, body)
+				}
+				var token struct {
+					Secret string `json:"secret"`
+				}
+				decodeBody(t, body, &token)
+				user, header = "", []string{"Authorization", "Bearer " + token.Secret}
+			}
+
+			resp, body := testRequest(t, srv, user, c.method, c.path, c.body, header...)
+			if resp.StatusCode != c.status {
+				t.Fatalf("unexpected status %d, want %d: %s", resp.StatusCode, c.status, body)
+			}
+		})
+	}
+}
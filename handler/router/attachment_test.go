@@ -0,0 +1,283 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// uploadTestFile attaches the content to the TODO as the user.
+// fieldが空でない場合は、ファイルの前に"note"パートとして送る.
+func uploadTestFile(t *testing.T, srv *httptest.Server, user string, todoID int64, content, field string) (*http.Response, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if field != "" {
+		if err := mw.WriteField("note", field); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fw, err := mw.CreateFormFile("file", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := "/todos/" + strconv.FormatInt(todoID, 10) + "/attachments"
+	return testRequest(t, srv, user, http.MethodPost, path, buf.String(), "Content-Type", mw.FormDataContentType())
+}
+
+// listBlobs returns the names of the files stored under dir except temporary files.
+func listBlobs(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var names []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !strings.HasPrefix(d.Name(), "upload-") {
+			names = append(names, d.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestAttachmentDedupAndGarbage(t *testing.T) {
+	t.Parallel()
+
+	d := newTestDB(t)
+	dir := t.TempDir()
+	attachments := service.NewAttachmentService(d, dir, 0, 0)
+	srv := startTestServer(t, d, attachments)
+	createTestUser(t, d, "alice")
+	for _, subject := range []string{"a", "b"} {
+		if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos", `{"subject":"`+subject+`"}`); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+		}
+	}
+
+	// 同じ内容のファイルは別のTODOに添付しても1つだけ保存する
+	var sums []string
+	for _, upload := range []struct {
+		todoID  int64
+		content string
+	}{{1, "same"}, {2, "same"}, {1, "other"}} {
+		resp, body := uploadTestFile(t, srv, "alice", upload.todoID, upload.content, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("upload returned %d: %s", resp.StatusCode, body)
+		}
+		var created model.CreateAttachmentResponse
+		decodeBody(t, body, &created)
+		sums = append(sums, created.Attachment.SHA256)
+	}
+	if sums[0] != sums[1] || sums[0] == sums[2] {
+		t.Errorf("unexpected sha256 %q", sums)
+	}
+	if got := listBlobs(t, dir); len(got) != 2 {
+		t.Fatalf("unexpected blobs %q, want 2", got)
+	}
+
+	// 失敗したアップロードの一時ファイルも削除する
+	if err := os.WriteFile(filepath.Join(dir, "upload-stale"), []byte("stale"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 参照が残っているblobは削除しない
+	ctx := context.Background()
+	before := time.Now().Add(time.Hour)
+	if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/todos/1/attachments/1", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE returned %d: %s", resp.StatusCode, body)
+	}
+	deleted, err := attachments.CollectGarbage(ctx, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted %d files, want 1", deleted)
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/2/attachments/2/content", ""); resp.StatusCode != http.StatusOK || body != "same" {
+		t.Errorf("unexpected content %d: %s", resp.StatusCode, body)
+	}
+
+	// 最後の参照を削除すると、blobも削除する
+	if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/todos/2/attachments/2", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE returned %d: %s", resp.StatusCode, body)
+	}
+	deleted, err = attachments.CollectGarbage(ctx, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted %d files, want 1", deleted)
+	}
+	if got := listBlobs(t, dir); len(got) != 1 || got[0] != sums[2] {
+		t.Errorf("unexpected blobs %q, want %q", got, sums[2])
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/1/attachments/3/content", ""); resp.StatusCode != http.StatusOK || body != "other" {
+		t.Errorf("unexpected content %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestAttachmentGarbageDuringUpload(t *testing.T) {
+	t.Parallel()
+
+	d := newTestDB(t)
+	dir := t.TempDir()
+	attachments := service.NewAttachmentService(d, dir, 0, 0)
+	srv := startTestServer(t, d, attachments)
+	createTestUser(t, d, "alice")
+	if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos", `{"subject":"a"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+	}
+
+	// 同じ内容の添付と削除を繰り返す間、参照のないblobを削除し続ける.
+	// 書き込み中の一時ファイルは残し、blobは更新日時を古くしていつでも削除の対象にする
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := attachments.CollectGarbage(context.Background(), time.Now().Add(-time.Minute)); err != nil {
+				t.Error(err)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	for i := 0; i < 20; i++ {
+		resp, body := uploadTestFile(t, srv, "alice", 1, "same", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("upload returned %d: %s", resp.StatusCode, body)
+		}
+		var created model.CreateAttachmentResponse
+		decodeBody(t, body, &created)
+		path := "/todos/1/attachments/" + strconv.FormatInt(created.Attachment.ID, 10)
+		if resp, body := testRequest(t, srv, "alice", http.MethodGet, path+"/content", ""); resp.StatusCode != http.StatusOK || body != "same" {
+			t.Fatalf("unexpected content of attachment %d, %d: %s", created.Attachment.ID, resp.StatusCode, body)
+		}
+		sum := created.Attachment.SHA256
+		old := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(filepath.Join(dir, sum[:2], sum), old, old); err != nil {
+			t.Fatal(err)
+		}
+		if resp, body := testRequest(t, srv, "alice", http.MethodDelete, path, ""); resp.StatusCode != http.StatusOK {
+			t.Fatalf("DELETE returned %d: %s", resp.StatusCode, body)
+		}
+	}
+}
+
+func TestAttachmentTooLarge(t *testing.T) {
+	t.Parallel()
+
+	const maxSize = 16
+	cases := map[string]struct {
+		content string
+		field   string
+		status  int
+	}{
+		"Max size":               {content: strings.Repeat("a", maxSize), status: http.StatusOK},
+		"File too large":         {content: strings.Repeat("a", maxSize+1), status: http.StatusRequestEntityTooLarge},
+		"Request body too large": {content: "a", field: strings.Repeat("a", 1<<20+maxSize+1), status: http.StatusRequestEntityTooLarge},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d := newTestDB(t)
+			srv := startTestServer(t, d, service.NewAttachmentService(d, t.TempDir(), maxSize, 0))
+			createTestUser(t, d, "alice")
+			if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos", `{"subject":"a"}`); resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+			}
+
+			if resp, body := uploadTestFile(t, srv, "alice", 1, c.content, c.field); resp.StatusCode != c.status {
+				t.Errorf("unexpected status %d, want %d: %s", resp.StatusCode, c.status, body)
+			}
+		})
+	}
+}
+
+func TestAttachmentQuota(t *testing.T) {
+	t.Parallel()
+
+	const quota = 16
+	d := newTestDB(t)
+	srv := startTestServer(t, d, service.NewAttachmentService(d, t.TempDir(), 0, quota))
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+	for _, user := range []string{"alice", "bob"} {
+		if resp, body := testRequest(t, srv, user, http.MethodPost, "/todos", `{"subject":"a"}`); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /todos as %s returned %d: %s", user, resp.StatusCode, body)
+		}
+	}
+
+	// 容量はユーザーごとに数え、他のユーザーの添付ファイルは含まない
+	large, small := strings.Repeat("a", quota/2+1), strings.Repeat("b", quota/4)
+	for _, req := range []struct {
+		user    string
+		todoID  int64
+		content string
+		status  int
+	}{
+		{"alice", 1, large, http.StatusOK},
+		{"alice", 1, large, http.StatusRequestEntityTooLarge},
+		{"bob", 2, small, http.StatusOK},
+	} {
+		if resp, body := uploadTestFile(t, srv, req.user, req.todoID, req.content, ""); resp.StatusCode != req.status {
+			t.Errorf("uploading as %s returned %d, want %d: %s", req.user, resp.StatusCode, req.status, body)
+		}
+	}
+
+	// 表示名が同じでも、別のユーザーが添付したファイルは容量に含めず削除もできない
+	if _, err := d.Exec(`UPDATE attachments SET owner = 'bob' WHERE todo_id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if resp, body := uploadTestFile(t, srv, "bob", 2, small, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("uploading as bob returned %d after renaming: %s", resp.StatusCode, body)
+	}
+	if resp, body := testRequest(t, srv, "bob", http.MethodDelete, "/todos/1/attachments/1", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("DELETE /todos/1/attachments/1 as bob returned %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/todos/1/attachments/1", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /todos/1/attachments/1 returned %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := uploadTestFile(t, srv, "alice", 1, large, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("uploading as alice returned %d after deleting: %s", resp.StatusCode, body)
+	}
+}
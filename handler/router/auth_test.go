@@ -0,0 +1,185 @@
+package router_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// loginTestUser logs in as the user with testPassword and returns the issued tokens.
+func loginTestUser(t *testing.T, srv *httptest.Server, name string) *model.AuthToken {
+	t.Helper()
+
+	resp, body := testRequest(t, srv, "", http.MethodPost, "/auth/login", `{"name":"`+name+`","password":"`+testPassword+`"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /auth/login returned %d: %s", resp.StatusCode, body)
+	}
+	var token model.AuthToken
+	decodeBody(t, body, &token)
+	return &token
+}
+
+// refreshTestToken exchanges the refresh token and returns the response and its body.
+func refreshTestToken(t *testing.T, srv *httptest.Server, refreshToken string) (*http.Response, string) {
+	t.Helper()
+
+	return testRequest(t, srv, "", http.MethodPost, "/auth/refresh", `{"refresh_token":"`+refreshToken+`"}`)
+}
+
+// accessTokenKID returns the kid in the header of the access token.
+func accessTokenKID(t *testing.T, accessToken string) string {
+	t.Helper()
+
+	b, err := base64.RawURLEncoding.DecodeString(strings.SplitN(accessToken, ".", 2)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header struct {
+		KID string `json:"kid"`
+	}
+	if err := json.Unmarshal(b, &header); err != nil {
+		t.Fatal(err)
+	}
+	return header.KID
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+
+	// 1回目のログインで交換を2回繰り返し、2回目のログインは別のfamilyにする
+	first := loginTestUser(t, srv, "alice")
+	other := loginTestUser(t, srv, "alice")
+	tokens := []*model.AuthToken{first}
+	for i := 0; i < 2; i++ {
+		resp, body := refreshTestToken(t, srv, tokens[i].RefreshToken)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /auth/refresh returned %d: %s", resp.StatusCode, body)
+		}
+		var token model.AuthToken
+		decodeBody(t, body, &token)
+		tokens = append(tokens, &token)
+	}
+
+	// 交換済みのトークンを再び使うと、同じfamilyの最新のトークンも失効する
+	if resp, body := refreshTestToken(t, srv, tokens[0].RefreshToken); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token returned %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := refreshTestToken(t, srv, tokens[2].RefreshToken); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("latest refresh token of the revoked family returned %d: %s", resp.StatusCode, body)
+	}
+
+	// 別のログインのトークンと、発行済みのアクセストークンは有効なまま
+	if resp, body := refreshTestToken(t, srv, other.RefreshToken); resp.StatusCode != http.StatusOK {
+		t.Errorf("refresh token of another login returned %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := testRequest(t, srv, "", http.MethodGet, "/users/me", "", "Authorization", "Bearer "+tokens[2].AccessToken); resp.StatusCode != http.StatusOK {
+		t.Errorf("access token of the revoked family returned %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestAdmin(t, d, "admin")
+	createTestUser(t, d, "alice")
+
+	old := loginTestUser(t, srv, "alice")
+	resp, body := testRequest(t, srv, "admin", http.MethodPost, "/signing-keys", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /signing-keys returned %d: %s", resp.StatusCode, body)
+	}
+	var rotated model.RotateSigningKeyResponse
+	decodeBody(t, body, &rotated)
+	oldKID := accessTokenKID(t, old.AccessToken)
+	if rotated.Key.KID == "" || rotated.Key.KID == oldKID {
+		t.Fatalf("unexpected rotated key %q, old key %q", rotated.Key.KID, oldKID)
+	}
+
+	// 以前の鍵で署名したアクセストークンは期限が切れるまで使える
+	if resp, body := testRequest(t, srv, "", http.MethodGet, "/users/me", "", "Authorization", "Bearer "+old.AccessToken); resp.StatusCode != http.StatusOK {
+		t.Errorf("access token signed with the previous key returned %d: %s", resp.StatusCode, body)
+	}
+
+	// 新しいトークンは新しい鍵で署名し、JWKSは両方の鍵を公開する
+	if kid := accessTokenKID(t, loginTestUser(t, srv, "alice").AccessToken); kid != rotated.Key.KID {
+		t.Errorf("new access token is signed with %q, want %q", kid, rotated.Key.KID)
+	}
+	resp, body = testRequest(t, srv, "", http.MethodGet, "/.well-known/jwks.json", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /.well-known/jwks.json returned %d: %s", resp.StatusCode, body)
+	}
+	var jwks model.ReadJWKSResponse
+	decodeBody(t, body, &jwks)
+	published := map[string]bool{}
+	for _, key := range jwks.Keys {
+		published[key.KID] = true
+	}
+	if !published[oldKID] || !published[rotated.Key.KID] {
+		t.Errorf("unexpected published keys: %s", body)
+	}
+}
+
+func TestUpdatePasswordRevokesTokens(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+	refreshTokens := map[string]string{}
+	secrets := map[string]string{}
+	for _, user := range []string{"alice", "bob"} {
+		refreshTokens[user] = loginTestUser(t, srv, user).RefreshToken
+		secrets[user] = createTestToken(t, srv, user, `{"name":"read","scopes":["todos:read"]}`).Secret
+	}
+
+	// usable reports whether the refresh token and the API token of the user can be used.
+	// 交換したリフレッシュトークンは次の確認で使う
+	usable := func(user string) (bool, bool) {
+		t.Helper()
+
+		resp, body := refreshTestToken(t, srv, refreshTokens[user])
+		if resp.StatusCode == http.StatusOK {
+			var token model.AuthToken
+			decodeBody(t, body, &token)
+			refreshTokens[user] = token.RefreshToken
+		}
+		tokenResp, _ := testRequest(t, srv, "", http.MethodGet, "/todos", "", "Authorization", "Bearer "+secrets[user])
+		return resp.StatusCode == http.StatusOK, tokenResp.StatusCode == http.StatusOK
+	}
+
+	// 現在のパスワードが違う場合は変更せず、トークンも失効させない
+	if resp, body := testRequest(t, srv, "alice", http.MethodPut, "/users/me/password", `{"current_password":"wrong","password":"changed"}`); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("PUT /users/me/password with a wrong password returned %d: %s", resp.StatusCode, body)
+	}
+	if refresh, token := usable("alice"); !refresh || !token {
+		t.Fatalf("tokens of alice are revoked by a failed change, refresh = %v, api token = %v", refresh, token)
+	}
+
+	// パスワードを変更すると、そのユーザーのリフレッシュトークンとAPIトークンだけが失効する
+	body := `{"current_password":"` + testPassword + `","password":"` + testPassword + `"}`
+	if resp, got := testRequest(t, srv, "alice", http.MethodPut, "/users/me/password", body); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /users/me/password returned %d: %s", resp.StatusCode, got)
+	}
+	if refresh, token := usable("alice"); refresh || token {
+		t.Errorf("tokens of alice are usable after the change, refresh = %v, api token = %v", refresh, token)
+	}
+	if refresh, token := usable("bob"); !refresh || !token {
+		t.Errorf("tokens of bob are revoked, refresh = %v, api token = %v", refresh, token)
+	}
+
+	// 変更後に発行したトークンは使える
+	refreshTokens["alice"] = loginTestUser(t, srv, "alice").RefreshToken
+	secrets["alice"] = createTestToken(t, srv, "alice", `{"name":"new","scopes":["todos:read"]}`).Secret
+	if refresh, token := usable("alice"); !refresh || !token {
+		t.Errorf("new tokens of alice are not usable, refresh = %v, api token = %v", refresh, token)
+	}
+}
@@ -0,0 +1,135 @@
+package router_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestChecklist(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+	for _, req := range []struct{ user, path, body string }{
+		{"alice", "/todos", `{"subject":"a"}`},
+		{"alice", "/todos", `{"subject":"b"}`},
+		{"bob", "/todos", `{"subject":"c"}`},
+		{"alice", "/todos/1/checklist", `{"body":"one"}`},
+		{"alice", "/todos/1/checklist", `{"body":"two"}`},
+		{"alice", "/todos/1/checklist", `{"body":"three"}`},
+		{"alice", "/todos/2/checklist", `{"body":"other"}`},
+	} {
+		if resp, body := testRequest(t, srv, req.user, http.MethodPost, req.path, req.body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s returned %d: %s", req.path, resp.StatusCode, body)
+		}
+	}
+
+	// checklist returns the bodies of the items of TODO 1 with "+" for done items.
+	checklist := func() string {
+		t.Helper()
+
+		resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/1/checklist", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /todos/1/checklist returned %d: %s", resp.StatusCode, body)
+		}
+		var got model.ReadChecklistResponse
+		decodeBody(t, body, &got)
+		items := make([]string, len(got.Items))
+		for i, item := range got.Items {
+			items[i] = item.Body
+			if item.Done {
+				items[i] = "+" + item.Body
+			}
+		}
+		return strings.Join(items, ",")
+	}
+	// progress returns the progress of the checklist of TODO 1.
+	progress := func() model.ChecklistProgress {
+		t.Helper()
+
+		resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/1", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /todos/1 returned %d: %s", resp.StatusCode, body)
+		}
+		var got model.GetTODOResponse
+		decodeBody(t, body, &got)
+		return got.TODO.Checklist
+	}
+
+	if got := checklist(); got != "one,two,three" {
+		t.Errorf("unexpected checklist %q", got)
+	}
+	if got := progress(); got != (model.ChecklistProgress{Done: 0, Total: 3}) {
+		t.Errorf("unexpected progress %+v", got)
+	}
+
+	// 切り替えと編集で完了状態が変わり、TODOの進捗にも反映される
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPost, "/todos/1/checklist/1/toggle", ""},
+		{http.MethodPost, "/todos/1/checklist/2/toggle", ""},
+		{http.MethodPost, "/todos/1/checklist/2/toggle", ""},
+		{http.MethodPut, "/todos/1/checklist/3", `{"body":"3","done":true}`},
+	} {
+		if resp, body := testRequest(t, srv, "alice", req.method, req.path, req.body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s returned %d: %s", req.method, req.path, resp.StatusCode, body)
+		}
+	}
+	if got := checklist(); got != "+one,two,+3" {
+		t.Errorf("unexpected checklist %q after toggle", got)
+	}
+	if got := progress(); got != (model.ChecklistProgress{Done: 2, Total: 3}) {
+		t.Errorf("unexpected progress %+v after toggle", got)
+	}
+
+	// 並べ替えにはすべての項目をちょうど1回ずつ指定する
+	for body, status := range map[string]int{
+		`{"ids":[3,1]}`:     http.StatusBadRequest,
+		`{"ids":[3,1,1]}`:   http.StatusBadRequest,
+		`{"ids":[3,1,4]}`:   http.StatusBadRequest,
+		`{"ids":[3,1,2,4]}`: http.StatusBadRequest,
+		`{"ids":[3,1,2]}`:   http.StatusOK,
+	} {
+		if resp, got := testRequest(t, srv, "alice", http.MethodPost, "/todos/1/checklist/reorder", body); resp.StatusCode != status {
+			t.Errorf("reordering with %s returned %d, want %d: %s", body, resp.StatusCode, status, got)
+		}
+	}
+	if got := checklist(); got != "+3,+one,two" {
+		t.Errorf("unexpected checklist %q after reorder", got)
+	}
+
+	// 削除した項目は進捗にも数えない
+	if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/todos/1/checklist/1", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /todos/1/checklist/1 returned %d: %s", resp.StatusCode, body)
+	}
+	if got := checklist(); got != "+3,two" {
+		t.Errorf("unexpected checklist %q after delete", got)
+	}
+	if got := progress(); got != (model.ChecklistProgress{Done: 1, Total: 2}) {
+		t.Errorf("unexpected progress %+v after delete", got)
+	}
+
+	// 空の本文、別のTODOの項目と、他のユーザーのTODOは扱えない
+	for _, req := range []struct {
+		user, method, path, body string
+		status                   int
+	}{
+		{"alice", http.MethodPost, "/todos/1/checklist", `{"body":""}`, http.StatusBadRequest},
+		{"alice", http.MethodPut, "/todos/1/checklist/2", `{"body":""}`, http.StatusBadRequest},
+		{"alice", http.MethodPost, "/todos/1/checklist/4/toggle", "", http.StatusNotFound},
+		{"alice", http.MethodDelete, "/todos/1/checklist/1", "", http.StatusNotFound},
+		{"alice", http.MethodGet, "/todos/3/checklist", "", http.StatusNotFound},
+		{"bob", http.MethodPost, "/todos/1/checklist", `{"body":"four"}`, http.StatusNotFound},
+		{"bob", http.MethodPost, "/todos/1/checklist/2/toggle", "", http.StatusNotFound},
+	} {
+		if resp, body := testRequest(t, srv, req.user, req.method, req.path, req.body); resp.StatusCode != req.status {
+			t.Errorf("%s %s as %s returned %d, want %d: %s", req.method, req.path, req.user, resp.StatusCode, req.status, body)
+		}
+	}
+	if got := checklist(); got != "+3,two" {
+		t.Errorf("unexpected checklist %q after the failed requests", got)
+	}
+}
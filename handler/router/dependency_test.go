@@ -0,0 +1,179 @@
+package router_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestDependency(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+	for _, req := range []struct{ user, path, body string }{
+		{"alice", "/todos", `{"subject":"a"}`},
+		{"alice", "/todos", `{"subject":"b"}`},
+		{"alice", "/todos", `{"subject":"c"}`},
+		{"bob", "/todos", `{"subject":"d"}`},
+		{"alice", "/todos/2/dependencies", `{"depends_on_id":1}`},
+		{"alice", "/todos/3/dependencies", `{"depends_on_id":2}`},
+	} {
+		if resp, body := testRequest(t, srv, req.user, http.MethodPost, req.path, req.body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s returned %d: %s", req.path, resp.StatusCode, body)
+		}
+	}
+
+	// 循環する依存関係と、見つからないTODOへの依存関係は追加できない
+	for _, req := range []struct {
+		path, body string
+		status     int
+	}{
+		{"/todos/1/dependencies", `{"depends_on_id":3}`, http.StatusBadRequest},
+		{"/todos/1/dependencies", `{"depends_on_id":1}`, http.StatusBadRequest},
+		{"/todos/1/dependencies", `{"depends_on_id":4}`, http.StatusBadRequest},
+		{"/todos/1/dependencies", `{"depends_on_id":99}`, http.StatusBadRequest},
+		{"/todos/2/dependencies", `{"depends_on_id":1}`, http.StatusConflict},
+		{"/todos/4/dependencies", `{"depends_on_id":1}`, http.StatusNotFound},
+	} {
+		if resp, body := testRequest(t, srv, "alice", http.MethodPost, req.path, req.body); resp.StatusCode != req.status {
+			t.Errorf("POST %s %s returned %d, want %d: %s", req.path, req.body, resp.StatusCode, req.status, body)
+		}
+	}
+
+	// dependencies returns the subjects of the TODOs that TODO id is blocked by and blocks.
+	dependencies := func(id string) (string, string) {
+		t.Helper()
+
+		resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/"+id+"/dependencies", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /todos/%s/dependencies returned %d: %s", id, resp.StatusCode, body)
+		}
+		var got model.ReadDependencyResponse
+		decodeBody(t, body, &got)
+		subjects := func(todos []model.TODO) string {
+			names := make([]string, len(todos))
+			for i, todo := range todos {
+				names[i] = todo.Subject
+			}
+			return strings.Join(names, ",")
+		}
+		return subjects(got.BlockedBy), subjects(got.Blocks)
+	}
+	// blocked returns the blocked flag of TODO id.
+	blocked := func(id string) bool {
+		t.Helper()
+
+		resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/"+id, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /todos/%s returned %d: %s", id, resp.StatusCode, body)
+		}
+		var got model.GetTODOResponse
+		decodeBody(t, body, &got)
+		return got.TODO.Blocked
+	}
+
+	if blockedBy, blocks := dependencies("2"); blockedBy != "a" || blocks != "c" {
+		t.Errorf("unexpected dependencies of b: blocked by %q, blocks %q", blockedBy, blocks)
+	}
+	if blocked("1") || !blocked("2") || !blocked("3") {
+		t.Errorf("unexpected blocked flags %v, %v, %v", blocked("1"), blocked("2"), blocked("3"))
+	}
+
+	// 依存先が完了するか、ゴミ箱に移動すると待たなくなる
+	if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos/complete", `{"id":1}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /todos/complete returned %d: %s", resp.StatusCode, body)
+	}
+	if blocked("2") {
+		t.Error("b is blocked after a is completed")
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/todos/2", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /todos/2 returned %d: %s", resp.StatusCode, body)
+	}
+	if blockedBy, _ := dependencies("3"); blocked("3") || blockedBy != "" {
+		t.Errorf("c is blocked by %q after b is trashed", blockedBy)
+	}
+
+	// 依存関係を外すと、もう一度外すことはできない
+	if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/todos/3/dependencies/2", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /todos/3/dependencies/2 returned %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/todos/3/dependencies/2", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleting the removed dependency returned %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := testRequest(t, srv, "bob", http.MethodGet, "/todos/3/dependencies", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /todos/3/dependencies as bob returned %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestOrderTODO(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+	for _, req := range []struct{ user, path, body string }{
+		{"alice", "/todos", `{"subject":"a"}`},
+		{"alice", "/todos", `{"subject":"b"}`},
+		{"alice", "/todos", `{"subject":"c"}`},
+		{"alice", "/todos", `{"subject":"d"}`},
+		{"alice", "/todos", `{"subject":"e"}`},
+		{"alice", "/todos", `{"subject":"f"}`},
+		{"bob", "/todos", `{"subject":"g"}`},
+		// a→c、b→a、d→b、e→dの順に完了を待つ
+		{"alice", "/todos/1/dependencies", `{"depends_on_id":3}`},
+		{"alice", "/todos/2/dependencies", `{"depends_on_id":1}`},
+		{"alice", "/todos/4/dependencies", `{"depends_on_id":2}`},
+		{"alice", "/todos/5/dependencies", `{"depends_on_id":4}`},
+	} {
+		if resp, body := testRequest(t, srv, req.user, http.MethodPost, req.path, req.body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s returned %d: %s", req.path, resp.StatusCode, body)
+		}
+	}
+
+	cases := map[string]struct {
+		ids    string
+		status int
+		want   string
+	}{
+		"Dependencies":          {ids: "1,2,3", want: "c,a,b"},
+		"Reversed ids":          {ids: "2,3,1", want: "c,a,b"},
+		"Indirect dependencies": {ids: "5,3", want: "c,e"},
+		"No dependencies":       {ids: "6,4", want: "d,f"},
+		"Duplicate ids":         {ids: "3,1,3", want: "c,a"},
+		"Empty ids":             {ids: "", status: http.StatusBadRequest},
+		"Invalid id":            {ids: "1,a", status: http.StatusBadRequest},
+		"Not found":             {ids: "1,99", status: http.StatusNotFound},
+		"TODO of another owner": {ids: "1,7", status: http.StatusNotFound},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/order?ids="+c.ids, "")
+			if c.status != 0 {
+				if resp.StatusCode != c.status {
+					t.Errorf("unexpected status %d, want %d: %s", resp.StatusCode, c.status, body)
+				}
+				return
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET /todos/order returned %d: %s", resp.StatusCode, body)
+			}
+			var got model.OrderTODOResponse
+			decodeBody(t, body, &got)
+			subjects := make([]string, len(got.TODOs))
+			for i, todo := range got.TODOs {
+				subjects[i] = todo.Subject
+			}
+			if s := strings.Join(subjects, ","); s != c.want {
+				t.Errorf("unexpected order %q, want %q", s, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTODOConditionalRequests(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		method      string
+		path        string
+		body        string
+		contentType string
+		header      string
+		stale       bool
+		weak        bool
+		status      int
+	}{
+		"GET If-None-Match current":  {method: http.MethodGet, path: "/todos/1", header: "If-None-Match", status: http.StatusNotModified},
+		"GET If-None-Match weak":     {method: http.MethodGet, path: "/todos/1", header: "If-None-Match", weak: true, status: http.StatusNotModified},
+		"GET If-None-Match stale":    {method: http.MethodGet, path: "/todos/1", header: "If-None-Match", stale: true, status: http.StatusOK},
+		"PUT If-Match current":       {method: http.MethodPut, path: "/todos/1", body: `{"subject":"c"}`, header: "If-Match", status: http.StatusOK},
+		"PUT If-Match stale":         {method: http.MethodPut, path: "/todos/1", body: `{"subject":"c"}`, header: "If-Match", stale: true, status: http.StatusPreconditionFailed},
+		"PUT If-Match weak":          {method: http.MethodPut, path: "/todos/1", body: `{"subject":"c"}`, header: "If-Match", weak: true, status: http.StatusPreconditionFailed},
+		"PATCH If-Match current":     {method: http.MethodPatch, path: "/todos/1", body: `{"subject":"c"}`, contentType: "application/merge-patch+json", header: "If-Match", status: http.StatusOK},
+		"PATCH If-Match stale":       {method: http.MethodPatch, path: "/todos/1", body: `{"subject":"c"}`, contentType: "application/merge-patch+json", header: "If-Match", stale: true, status: http.StatusPreconditionFailed},
+		"DELETE If-Match current":    {method: http.MethodDelete, path: "/todos/1", header: "If-Match", status: http.StatusOK},
+		"DELETE If-Match stale":      {method: http.MethodDelete, path: "/todos/1", header: "If-Match", stale: true, status: http.StatusPreconditionFailed},
+		"DELETE If-Match not found":  {method: http.MethodDelete, path: "/todos/99", header: "If-Match", status: http.StatusPreconditionFailed},
+		"PUT If-Match another owner": {method: http.MethodPut, path: "/todos/2", body: `{"subject":"c"}`, header: "If-Match", status: http.StatusPreconditionFailed},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, d := newTestServer(t)
+			createTestUser(t, d, "alice")
+			createTestUser(t, d, "bob")
+
+			// 作成直後のETagを古いETagとして、更新後のETagと比べる
+			if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos", `{"subject":"a"}`); resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+			}
+			resp, _ := testRequest(t, srv, "alice", http.MethodGet, "/todos/1", "")
+			stale := resp.Header.Get("ETag")
+			resp, body := testRequest(t, srv, "alice", http.MethodPut, "/todos/1", `{"subject":"b"}`)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("PUT /todos/1 returned %d: %s", resp.StatusCode, body)
+			}
+			current := resp.Header.Get("ETag")
+			if stale == "" || current == "" || stale == current {
+				t.Fatalf("unexpected ETags, stale = %q, current = %q", stale, current)
+			}
+			if resp, _ := testRequest(t, srv, "alice", http.MethodGet, "/todos/1", ""); resp.Header.Get("ETag") != current {
+				t.Fatalf("GET returned ETag %q, want %q", resp.Header.Get("ETag"), current)
+			}
+			// 他のユーザーのTODOは同じバージョンでも一致しない
+			if resp, body := testRequest(t, srv, "bob", http.MethodPost, "/todos", `{"subject":"other"}`); resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+			}
+
+			etag := current
+			if c.stale {
+				etag = stale
+			}
+			if c.weak {
+				etag = "W/" + etag
+			}
+			header := []string{c.header, etag}
+			if c.contentType != "" {
+				header = append(header, "Content-Type", c.contentType)
+			}
+			resp, body = testRequest(t, srv, "alice", c.method, c.path, c.body, header...)
+			if resp.StatusCode != c.status {
+				t.Fatalf("unexpected status %d, want %d: %s", resp.StatusCode, c.status, body)
+			}
+			if c.status == http.StatusNotModified && body != "" {
+				t.Errorf("unexpected body of 304: %s", body)
+			}
+			if c.status == http.StatusOK && (c.method == http.MethodPut || c.method == http.MethodPatch) && resp.Header.Get("ETag") == current {
+				t.Errorf("ETag %q did not change after %s", current, c.method)
+			}
+		})
+	}
+}
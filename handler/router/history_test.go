@@ -0,0 +1,123 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestTODOHistory(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPost, "/todos", `{"subject":"a"}`},
+		{http.MethodPost, "/todos", `{"subject":"b"}`},
+		{http.MethodPut, "/todos/1", `{"subject":"a-1"}`},
+		{http.MethodPost, "/todos/complete", `{"id":1}`},
+		{http.MethodPost, "/todos/reopen", `{"id":1}`},
+		{http.MethodPost, "/todos/1/move", `{"after_id":2}`},
+		{http.MethodDelete, "/todos/1", ""},
+		{http.MethodPost, "/trash/restore", `{"ids":[1]}`},
+	} {
+		if resp, body := testRequest(t, srv, "alice", req.method, req.path, req.body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s returned %d: %s", req.method, req.path, resp.StatusCode, body)
+		}
+	}
+
+	// history returns the events of TODO 1 read as the user.
+	history := func(user, query string) []model.TODOEvent {
+		t.Helper()
+
+		resp, body := testRequest(t, srv, user, http.MethodGet, "/todos/1/history?"+query, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /todos/1/history returned %d: %s", resp.StatusCode, body)
+		}
+		var got model.ReadTODOHistoryResponse
+		decodeBody(t, body, &got)
+		return got.Events
+	}
+	actions := func(events []model.TODOEvent) string {
+		names := make([]string, len(events))
+		for i, e := range events {
+			names[i] = string(e.Action)
+		}
+		return strings.Join(names, ",")
+	}
+
+	// 新しい変更から順に並び、作成の前と削除の後はnullになる
+	events := history("alice", "size=10")
+	if got := actions(events); got != "restore,delete,move,reopen,complete,update,create" {
+		t.Fatalf("unexpected actions %q", got)
+	}
+	for _, e := range events {
+		if e.TODOID != 1 || e.Actor != "alice" {
+			t.Errorf("unexpected event %+v", e)
+		}
+	}
+	if create := events[6]; string(create.Before) != "null" || len(create.After) == 0 || string(create.After) == "null" {
+		t.Errorf("unexpected create event before=%s after=%s", create.Before, create.After)
+	}
+	if del := events[1]; string(del.After) != "null" {
+		t.Errorf("unexpected delete event after=%s", del.After)
+	}
+	var before, after model.TODO
+	if err := json.Unmarshal(events[5].Before, &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(events[5].After, &after); err != nil {
+		t.Fatal(err)
+	}
+	if before.Subject != "a" || after.Subject != "a-1" {
+		t.Errorf("unexpected update event before=%q after=%q", before.Subject, after.Subject)
+	}
+
+	// prev_idで続きを読める
+	var paged []model.TODOEvent
+	prevID := int64(0)
+	for i := 0; i < 5; i++ {
+		page := history("alice", "size=3&prev_id="+strconv.FormatInt(prevID, 10))
+		paged = append(paged, page...)
+		if len(page) < 3 {
+			break
+		}
+		prevID = page[len(page)-1].ID
+	}
+	if got := actions(paged); got != actions(events) {
+		t.Errorf("unexpected paged actions %q", got)
+	}
+
+	// 完全に削除した後も履歴は残る
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodDelete, "/todos/1", ""},
+		{http.MethodDelete, "/trash", `{"ids":[1]}`},
+	} {
+		if resp, body := testRequest(t, srv, "alice", req.method, req.path, req.body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s returned %d: %s", req.method, req.path, resp.StatusCode, body)
+		}
+	}
+	if got := actions(history("alice", "size=2")); got != "delete,restore" {
+		t.Errorf("unexpected actions %q after purge", got)
+	}
+
+	// 他のユーザーのTODOと、見つからないTODOの履歴は読めない
+	for _, req := range []struct{ user, path string }{
+		{"bob", "/todos/1/history"},
+		{"alice", "/todos/99/history"},
+	} {
+		if resp, body := testRequest(t, srv, req.user, http.MethodGet, req.path, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s as %s returned %d: %s", req.path, req.user, resp.StatusCode, body)
+		}
+	}
+	for _, query := range []string{"size=0", "prev_id=abc"} {
+		if resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/1/history?"+query, ""); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET /todos/1/history?%s returned %d: %s", query, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !sqlite_fts5
+// +build !sqlite_fts5
+
+package router_test
+
+// fts5 reports whether the tests are built with the sqlite_fts5 build tag.
+const fts5 = false
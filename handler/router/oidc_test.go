@@ -0,0 +1,433 @@
+package router_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler/router"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	fakeClientID     = "todo-app"
+	fakeClientSecret = "s3cr3t/+="
+	fakeKeyID        = "k1"
+)
+
+// fakeIdP is an in-process OpenID Connect identity provider that approves every authorization request.
+type fakeIdP struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	// tamper changes the claims of the ID token before it is signed.
+	tamper func(claims jwt.MapClaims)
+	// signer signs the ID token instead of key if not nil.
+	signer *rsa.PrivateKey
+
+	mu sync.Mutex
+	// subject and username are the sub and preferred_username claims of the next ID token.
+	subject, username string
+	grants            map[string]url.Values
+}
+
+func newFakeIdP(t *testing.T, tamper func(claims jwt.MapClaims), signer *rsa.PrivateKey) *fakeIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{t: t, key: key, tamper: tamper, signer: signer, subject: "alice-sub", username: "alice", grants: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/jwks", idp.jwks)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// setUser changes the user authenticated by the next authorization request.
+func (idp *fakeIdP) setUser(subject, username string) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.subject, idp.username = subject, username
+}
+
+func (idp *fakeIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 idp.URL,
+		"authorization_endpoint": idp.URL + "/authorize",
+		"token_endpoint":         idp.URL + "/token",
+		"jwks_uri":               idp.URL + "/jwks",
+	})
+}
+
+// authorize redirects back to the client with a new code, remembering the request to check it at the token endpoint.
+func (idp *fakeIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != fakeClientID || q.Get("code_challenge_method") != "S256" {
+		idp.t.Errorf("unexpected authorization request: %s", r.URL.RawQuery)
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		idp.t.Error(err)
+		return
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+	idp.mu.Lock()
+	idp.grants[code] = q
+	idp.mu.Unlock()
+
+	u, _ := url.Parse(q.Get("redirect_uri"))
+	u.RawQuery = url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// token exchanges a code for an ID token after checking the client credentials and the PKCE verifier.
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, _ := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if id != fakeClientID || secret != fakeClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	idp.mu.Lock()
+	grant, ok := idp.grants[r.PostFormValue("code")]
+	delete(idp.grants, r.PostFormValue("code"))
+	subject, username := idp.subject, idp.username
+	idp.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("redirect_uri") != grant.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != grant.Get("code_challenge") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                idp.URL,
+		"aud":                fakeClientID,
+		"sub":                subject,
+		"preferred_username": username,
+		"nonce":              grant.Get("nonce"),
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+	}
+	if idp.tamper != nil {
+		idp.tamper(claims)
+	}
+	signer := idp.key
+	if idp.signer != nil {
+		signer = idp.signer
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = fakeKeyID
+	signed, err := token.SignedString(signer)
+	if err != nil {
+		idp.t.Error(err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "unused", "token_type": "Bearer", "id_token": signed})
+}
+
+func (idp *fakeIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": fakeKeyID,
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}},
+	})
+}
+
+// newOIDCServer returns the server under test that logs in with idp.
+func newOIDCServer(t *testing.T, idp *fakeIdP) (*httptest.Server, *sql.DB) {
+	t.Helper()
+
+	d, err := db.NewDB(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	// コールバックのURLはサーバーを起動するまで決まらないので、起動してからルーターを作る
+	var h http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { h.ServeHTTP(w, r) }))
+	t.Cleanup(srv.Close)
+
+	oidc := service.NewOIDCService(d, service.OIDCConfig{
+		Issuer:       idp.URL,
+		ClientID:     fakeClientID,
+		ClientSecret: fakeClientSecret,
+		RedirectURL:  srv.URL + "/auth/oidc/callback",
+		Scopes:       []string{"profile"},
+	})
+	h = router.NewRouter(d, []byte("test"), service.NewAttachmentService(d, t.TempDir(), 0, 0), oidc)
+	return srv, d
+}
+
+// oidcLogin follows the whole login flow from /auth/oidc/login like a browser and returns the final response.
+func oidcLogin(t *testing.T, srv *httptest.Server) (int, string) {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Jar: jar}).Get(srv.URL + "/auth/oidc/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// currentUser returns the id and the name of the user of the access token in the login response body.
+func currentUser(t *testing.T, srv *httptest.Server, body string) (int64, string) {
+	t.Helper()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal([]byte(body), &token); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/users/me", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /users/me returned %d", resp.StatusCode)
+	}
+
+	var me struct {
+		User struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&me); err != nil {
+		t.Fatal(err)
+	}
+	return me.User.ID, me.User.Name
+}
+
+func TestOIDCLogin(t *testing.T) {
+	t.Parallel()
+
+	forged, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		tamper func(claims jwt.MapClaims)
+		signer *rsa.PrivateKey
+		status int
+	}{
+		"Normal":           {status: http.StatusOK},
+		"Audience list":    {tamper: func(c jwt.MapClaims) { c["aud"] = []string{"other", fakeClientID}; c["azp"] = fakeClientID }, status: http.StatusOK},
+		"Wrong audience":   {tamper: func(c jwt.MapClaims) { c["aud"] = "other" }, status: http.StatusUnauthorized},
+		"Wrong azp":        {tamper: func(c jwt.MapClaims) { c["azp"] = "other" }, status: http.StatusUnauthorized},
+		"Wrong issuer":     {tamper: func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }, status: http.StatusUnauthorized},
+		"Wrong nonce":      {tamper: func(c jwt.MapClaims) { c["nonce"] = "replayed" }, status: http.StatusUnauthorized},
+		"Expired":          {tamper: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, status: http.StatusUnauthorized},
+		"No expiration":    {tamper: func(c jwt.MapClaims) { delete(c, "exp") }, status: http.StatusUnauthorized},
+		"Forged signature": {signer: forged, status: http.StatusUnauthorized},
+		"No username":      {tamper: func(c jwt.MapClaims) { delete(c, "preferred_username") }, status: http.StatusBadRequest},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, _ := newOIDCServer(t, newFakeIdP(t, c.tamper, c.signer))
+
+			status, body := oidcLogin(t, srv)
+			if status != c.status {
+				t.Fatalf("unexpected status %d, want %d: %s", status, c.status, body)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if _, name := currentUser(t, srv, body); name != "alice" {
+				t.Errorf("unexpected user %q, want %q", name, "alice")
+			}
+		})
+	}
+}
+
+func TestOIDCLoginMapsUsers(t *testing.T) {
+	t.Parallel()
+
+	idp := newFakeIdP(t, nil, nil)
+	srv, _ := newOIDCServer(t, idp)
+
+	// 新しいsubjectは新しいユーザーになる
+	status, body := oidcLogin(t, srv)
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", status, body)
+	}
+	first, name := currentUser(t, srv, body)
+	if name != "alice" {
+		t.Errorf("logged in as %q, want alice", name)
+	}
+
+	// 対応付けた後は、IdPで名前が変わってもsubjectで同じユーザーになる
+	idp.setUser("alice-sub", "alice.renamed")
+	status, body = oidcLogin(t, srv)
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", status, body)
+	}
+	if id, name := currentUser(t, srv, body); id != first || name != "alice" {
+		t.Errorf("logged in as user %d %q, want %d alice", id, name, first)
+	}
+
+	// 同じ名前の別のsubjectは、番号を付けた別のユーザーになる
+	for i, subject := range []string{"mallory-sub", "eve-sub"} {
+		idp.setUser(subject, "alice")
+		status, body = oidcLogin(t, srv)
+		if status != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", status, body)
+		}
+		want := fmt.Sprintf("alice-%d", i+2)
+		if id, name := currentUser(t, srv, body); id == first || name != want {
+			t.Errorf("logged in as user %d %q, want a new user %s", id, name, want)
+		}
+	}
+}
+
+func TestOIDCLoginNameCollision(t *testing.T) {
+	t.Parallel()
+
+	idp := newFakeIdP(t, nil, nil)
+	srv, d := newOIDCServer(t, idp)
+
+	// IdPのpreferred_usernameがパスワードでログインする既存のユーザーと同じでも、既存のユーザーには対応付けない
+	existing := createTestUser(t, d, "alice")
+	if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos", `{"subject":"secret"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+	}
+
+	status, body := oidcLogin(t, srv)
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", status, body)
+	}
+	id, name := currentUser(t, srv, body)
+	if id == existing.ID || name != "alice-2" {
+		t.Errorf("logged in as user %d %q, want a new user alice-2", id, name)
+	}
+
+	var token model.AuthToken
+	decodeBody(t, body, &token)
+	header := []string{"Authorization", "Bearer " + token.AccessToken}
+	if resp, body := testRequest(t, srv, "", http.MethodGet, "/todos/1", "", header...); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /todos/1 of the existing user returned %d: %s", resp.StatusCode, body)
+	}
+	resp, body := testRequest(t, srv, "", http.MethodGet, "/todos", "", header...)
+	if resp.StatusCode != http.StatusOK || strings.Contains(body, "secret") {
+		t.Errorf("unexpected todos %d: %s", resp.StatusCode, body)
+	}
+
+	// 既存のユーザーは引き続きパスワードでログインできる
+	if got := listSubjects(t, srv, "alice", "/todos"); len(got) != 1 || got[0] != "secret" {
+		t.Errorf("unexpected todos of the existing user: %q", got)
+	}
+}
+
+func TestOIDCCallbackState(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newOIDCServer(t, newFakeIdP(t, nil, nil))
+
+	// IdPにリダイレクトせずに、ログインを始めたのとは別のユーザーエージェントでコールバックする
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(srv.URL + "/auth/oidc/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("unexpected status %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = client.Get(srv.URL + "/auth/oidc/callback?code=x&state=" + url.QueryEscape(location.Query().Get("state")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unexpected status %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestOIDCDisabled(t *testing.T) {
+	t.Parallel()
+
+	d, err := db.NewDB(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	srv := httptest.NewServer(router.NewRouter(d, []byte("test"), service.NewAttachmentService(d, t.TempDir(), 0, 0), service.NewOIDCService(d, service.OIDCConfig{})))
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/auth/oidc/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("unexpected status %d, want %d", resp.StatusCode, http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,137 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOwnerIsolation(t *testing.T) {
+	t.Parallel()
+
+	// aliceがプロジェクト2、タグ1、プロジェクト2のフィールド1とInboxのフィールド2を作成した後に、bobとしてリクエストする
+	cases := map[string]struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		"GET project":                 {method: http.MethodGet, path: "/projects/2", status: http.StatusNotFound},
+		"PUT project":                 {method: http.MethodPut, path: "/projects/2", body: `{"name":"b"}`, status: http.StatusNotFound},
+		"DELETE project":              {method: http.MethodDelete, path: "/projects/2?todos=inbox", status: http.StatusNotFound},
+		"GET project todos":           {method: http.MethodGet, path: "/projects/2/todos", status: http.StatusNotFound},
+		"POST todo to project":        {method: http.MethodPost, path: "/todos", body: `{"subject":"b","project_id":2}`, status: http.StatusBadRequest},
+		"GET tag":                     {method: http.MethodGet, path: "/tags/1", status: http.StatusNotFound},
+		"PUT tag":                     {method: http.MethodPut, path: "/tags/1", body: `{"name":"b"}`, status: http.StatusNotFound},
+		"DELETE tag":                  {method: http.MethodDelete, path: "/tags/1", status: http.StatusNotFound},
+		"POST todo with tag":          {method: http.MethodPost, path: "/todos", body: `{"subject":"b","tag_ids":[1]}`, status: http.StatusBadRequest},
+		"POST tag with same name":     {method: http.MethodPost, path: "/tags", body: `{"name":"work"}`, status: http.StatusOK},
+		"GET fields":                  {method: http.MethodGet, path: "/projects/2/fields", status: http.StatusNotFound},
+		"GET field":                   {method: http.MethodGet, path: "/projects/2/fields/1", status: http.StatusNotFound},
+		"DELETE inbox field":          {method: http.MethodDelete, path: "/projects/1/fields/2", status: http.StatusNotFound},
+		"POST todo with inbox field":  {method: http.MethodPost, path: "/todos", body: `{"subject":"b","fields":{"estimate":1}}`, status: http.StatusBadRequest},
+		"POST inbox field same name":  {method: http.MethodPost, path: "/projects/1/fields", body: `{"name":"estimate","type":"text"}`, status: http.StatusOK},
+		"POST field to project":       {method: http.MethodPost, path: "/projects/2/fields", body: `{"name":"b","type":"text"}`, status: http.StatusNotFound},
+		"PUT inbox":                   {method: http.MethodPut, path: "/projects/1", body: `{"name":"b"}`, status: http.StatusBadRequest},
+		"GET inbox":                   {method: http.MethodGet, path: "/projects/1", status: http.StatusOK},
+		"POST todo to inbox explicit": {method: http.MethodPost, path: "/todos", body: `{"subject":"b","project_id":1}`, status: http.StatusOK},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, d := newTestServer(t)
+			createTestUser(t, d, "alice")
+			createTestUser(t, d, "bob")
+			for _, req := range []struct{ path, body string }{
+				{"/projects", `{"name":"work"}`},
+				{"/tags", `{"name":"work"}`},
+				{"/projects/2/fields", `{"name":"estimate","type":"number"}`},
+				{"/projects/1/fields", `{"name":"estimate","type":"number"}`},
+				{"/todos", `{"subject":"a","project_id":2,"tag_ids":[1],"fields":{"estimate":1}}`},
+			} {
+				if resp, body := testRequest(t, srv, "alice", http.MethodPost, req.path, req.body); resp.StatusCode != http.StatusOK {
+					t.Fatalf("POST %s returned %d: %s", req.path, resp.StatusCode, body)
+				}
+			}
+
+			resp, body := testRequest(t, srv, "bob", c.method, c.path, c.body)
+			if resp.StatusCode != c.status {
+				t.Fatalf("unexpected status %d, want %d: %s", resp.StatusCode, c.status, body)
+			}
+
+			// aliceのデータはbobのリクエストの後も変わらない
+			var projects struct {
+				Projects []struct{ Name string } `json:"projects"`
+			}
+			_, body = testRequest(t, srv, "alice", http.MethodGet, "/projects", "")
+			decodeBody(t, body, &projects)
+			if len(projects.Projects) != 2 || projects.Projects[0].Name != "Inbox" || projects.Projects[1].Name != "work" {
+				t.Errorf("unexpected projects of alice: %s", body)
+			}
+			var tags struct {
+				Tags []struct{ Name string } `json:"tags"`
+			}
+			_, body = testRequest(t, srv, "alice", http.MethodGet, "/tags", "")
+			decodeBody(t, body, &tags)
+			if len(tags.Tags) != 1 || tags.Tags[0].Name != "work" {
+				t.Errorf("unexpected tags of alice: %s", body)
+			}
+			for _, path := range []string{"/projects/1/fields", "/projects/2/fields"} {
+				var fields struct {
+					Fields []struct{ Name string } `json:"fields"`
+				}
+				_, body = testRequest(t, srv, "alice", http.MethodGet, path, "")
+				decodeBody(t, body, &fields)
+				if len(fields.Fields) != 1 || fields.Fields[0].Name != "estimate" {
+					t.Errorf("unexpected fields of alice in %s: %s", path, body)
+				}
+			}
+		})
+	}
+}
+
+func TestOwnerLists(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+	for _, path := range []string{"/projects", "/tags", "/projects/1/fields"} {
+		body := `{"name":"alice"}`
+		if path == "/projects/1/fields" {
+			body = `{"name":"alice","type":"text"}`
+		}
+		if resp, body := testRequest(t, srv, "alice", http.MethodPost, path, body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s returned %d: %s", path, resp.StatusCode, body)
+		}
+	}
+
+	cases := map[string]struct {
+		path string
+		key  string
+		want int
+	}{
+		"Projects have only the inbox": {path: "/projects", key: "projects", want: 1},
+		"Tags are empty":               {path: "/tags", key: "tags", want: 0},
+		"Inbox fields are empty":       {path: "/projects/1/fields", key: "fields", want: 0},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, body := testRequest(t, srv, "bob", http.MethodGet, c.path, "")
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s returned %d: %s", c.path, resp.StatusCode, body)
+			}
+			var list map[string][]interface{}
+			decodeBody(t, body, &list)
+			if got := len(list[c.key]); got != c.want {
+				t.Errorf("unexpected number of %s %d, want %d: %s", c.key, got, c.want, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestReadTODOPageSize(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos", `{"subject":"a"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+	}
+
+	cases := map[string]struct {
+		path   string
+		status int
+	}{
+		"Negative":          {path: "/todos?size=-1", status: http.StatusBadRequest},
+		"Zero":              {path: "/todos?size=0", status: http.StatusBadRequest},
+		"Too large":         {path: "/todos?size=101", status: http.StatusBadRequest},
+		"Overflowing":       {path: "/todos?size=9223372036854775807", status: http.StatusBadRequest},
+		"Max":               {path: "/todos?size=100", status: http.StatusOK},
+		"Negative search":   {path: "/todos/search?q=a&size=-1", status: http.StatusBadRequest},
+		"Negative history":  {path: "/todos/1/history?size=-1", status: http.StatusBadRequest},
+		"Negative comments": {path: "/todos/1/comments?size=-1", status: http.StatusBadRequest},
+		"Negative trash":    {path: "/trash?size=-1", status: http.StatusBadRequest},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, body := testRequest(t, srv, "alice", http.MethodGet, c.path, "")
+			if resp.StatusCode != c.status {
+				t.Errorf("unexpected status %d, want %d: %s", resp.StatusCode, c.status, body)
+			}
+		})
+	}
+}
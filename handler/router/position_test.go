@@ -0,0 +1,134 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestMoveTODO(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		moves  []string
+		status int
+		want   string
+	}{
+		"Not moved":        {want: "a,b,c"},
+		"After":            {moves: []string{`3 {"after_id":1}`}, want: "a,c,b"},
+		"Before":           {moves: []string{`3 {"before_id":1}`}, want: "c,a,b"},
+		"Between":          {moves: []string{`1 {"after_id":3}`, `2 {"after_id":1,"before_id":3}`}, want: "b,c,a"},
+		"Wrong neighbours": {moves: []string{`2 {"after_id":3,"before_id":1}`}, status: http.StatusBadRequest, want: "a,b,c"},
+		"Next to itself":   {moves: []string{`2 {"after_id":2}`}, status: http.StatusBadRequest, want: "a,b,c"},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, d := newTestServer(t)
+			createTestUser(t, d, "alice")
+			for _, subject := range []string{"a", "b", "c"} {
+				if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos", `{"subject":"`+subject+`"}`); resp.StatusCode != http.StatusOK {
+					t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+				}
+			}
+
+			status := http.StatusOK
+			for _, move := range c.moves {
+				parts := strings.SplitN(move, " ", 2)
+				resp, _ := testRequest(t, srv, "alice", http.MethodPost, "/todos/"+parts[0]+"/move", parts[1])
+				status = resp.StatusCode
+			}
+			if c.status != 0 && status != c.status {
+				t.Errorf("unexpected status %d, want %d", status, c.status)
+			}
+			if got := strings.Join(listSubjects(t, srv, "alice", "/todos?sort=position"), ","); got != c.want {
+				t.Errorf("unexpected order %q, want %q", got, c.want)
+			}
+
+			// 新しく作成したTODOは並び替えたTODOより前に並ぶ
+			if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos", `{"subject":"new"}`); resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+			}
+			want := "new," + c.want
+			if len(c.moves) == 0 || c.status != 0 {
+				want = c.want + ",new"
+			}
+			if got := strings.Join(listSubjects(t, srv, "alice", "/todos?sort=position"), ","); got != want {
+				t.Errorf("unexpected order %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// listPositions returns the positions of the TODOs of GET path as the user by their subjects.
+func listPositions(t *testing.T, srv *httptest.Server, user, path string) map[string]float64 {
+	t.Helper()
+
+	resp, body := testRequest(t, srv, user, http.MethodGet, path, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s returned %d: %s", path, resp.StatusCode, body)
+	}
+	var list struct {
+		TODOs []model.TODO `json:"todos"`
+	}
+	decodeBody(t, body, &list)
+
+	positions := make(map[string]float64, len(list.TODOs))
+	for _, todo := range list.TODOs {
+		positions[todo.Subject] = todo.Position
+	}
+	return positions
+}
+
+func TestMoveTODOKeepsOtherUsers(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+	for _, req := range []struct{ user, method, path, body string }{
+		{"alice", http.MethodPost, "/todos", `{"subject":"a"}`},
+		{"alice", http.MethodPost, "/todos", `{"subject":"b"}`},
+		{"alice", http.MethodPost, "/todos", `{"subject":"c"}`},
+		{"alice", http.MethodPost, "/todos", `{"subject":"d"}`},
+		{"alice", http.MethodPost, "/todos/3/move", `{"after_id":1}`},
+		{"alice", http.MethodDelete, "/todos/2", ""},
+		{"bob", http.MethodPost, "/todos", `{"subject":"x"}`},
+		{"bob", http.MethodPost, "/todos", `{"subject":"y"}`},
+		{"bob", http.MethodPost, "/todos", `{"subject":"z"}`},
+	} {
+		if resp, body := testRequest(t, srv, req.user, req.method, req.path, req.body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s returned %d: %s", req.method, req.path, resp.StatusCode, body)
+		}
+	}
+	todos := listPositions(t, srv, "alice", "/todos")
+	trash := listPositions(t, srv, "alice", "/trash")
+
+	// bobのTODOを振り直すまで間隔を詰めても、aliceのTODOとゴミ箱のTODOのpositionは変わらない
+	moves := []string{`7 {"after_id":5}`}
+	for i := 0; i < 40; i++ {
+		moves = append(moves, []string{`6 {"after_id":5}`, `7 {"after_id":5}`}[i%2])
+	}
+	for _, move := range moves {
+		parts := strings.SplitN(move, " ", 2)
+		if resp, body := testRequest(t, srv, "bob", http.MethodPost, "/todos/"+parts[0]+"/move", parts[1]); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /todos/%s/move returned %d: %s", parts[0], resp.StatusCode, body)
+		}
+	}
+	if got := strings.Join(listSubjects(t, srv, "bob", "/todos?sort=position"), ","); got != "x,z,y" {
+		t.Errorf("unexpected order of bob %q", got)
+	}
+	if got := listPositions(t, srv, "alice", "/todos"); !reflect.DeepEqual(got, todos) {
+		t.Errorf("positions of alice changed from %v to %v", todos, got)
+	}
+	if got := listPositions(t, srv, "alice", "/trash"); !reflect.DeepEqual(got, trash) {
+		t.Errorf("positions of the trash of alice changed from %v to %v", trash, got)
+	}
+}
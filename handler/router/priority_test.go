@@ -0,0 +1,65 @@
+package router_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTODOPriority(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	for _, body := range []string{
+		`{"subject":"a"}`,
+		`{"subject":"b","priority":"high"}`,
+		`{"subject":"c","priority":"none"}`,
+		`{"subject":"d","priority":"low"}`,
+	} {
+		if resp, got := testRequest(t, srv, "alice", http.MethodPost, "/todos", body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /todos %s returned %d: %s", body, resp.StatusCode, got)
+		}
+	}
+
+	// 優先度を指定しないTODOも、noneを指定したTODOと同じくnoneとして返す
+	for _, path := range []string{"/todos/1", "/todos/3"} {
+		resp, body := testRequest(t, srv, "alice", http.MethodGet, path, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s returned %d: %s", path, resp.StatusCode, body)
+		}
+		if !strings.Contains(body, `"priority":"none"`) {
+			t.Errorf("GET %s returned the priority other than none: %s", path, body)
+		}
+	}
+
+	cases := map[string]struct {
+		query  string
+		status int
+		want   string
+	}{
+		"Filter by none":    {query: "priority=none", want: "c,a"},
+		"Filter by high":    {query: "priority=high", want: "b"},
+		"Sort by priority":  {query: "sort=priority", want: "a,c,d,b"},
+		"Sort descending":   {query: "sort=-priority", want: "b,d,c,a"},
+		"Unknown priority":  {query: "priority=urgent", status: http.StatusBadRequest},
+		"Priority is empty": {query: "priority=", want: "d,c,b,a"},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if c.status != 0 {
+				if resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos?"+c.query, ""); resp.StatusCode != c.status {
+					t.Errorf("unexpected status %d, want %d: %s", resp.StatusCode, c.status, body)
+				}
+				return
+			}
+			if got := strings.Join(listSubjects(t, srv, "alice", "/todos?"+c.query), ","); got != c.want {
+				t.Errorf("unexpected todos %q, want %q", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestDeleteProject(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		mode  string
+		todos string
+		trash string
+	}{
+		"Cascade": {mode: "cascade", todos: "c", trash: "a"},
+		"Inbox":   {mode: "inbox", todos: "c,b,a"},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, d := newTestServer(t)
+			createTestUser(t, d, "alice")
+			for _, req := range []struct{ path, body string }{
+				{"/projects", `{"name":"work"}`},
+				{"/todos", `{"subject":"a","project_id":2}`},
+				{"/todos", `{"subject":"b","project_id":2,"parent_id":1}`},
+				{"/todos", `{"subject":"c"}`},
+			} {
+				if resp, body := testRequest(t, srv, "alice", http.MethodPost, req.path, req.body); resp.StatusCode != http.StatusOK {
+					t.Fatalf("POST %s returned %d: %s", req.path, resp.StatusCode, body)
+				}
+			}
+
+			if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/projects/2?todos="+c.mode, ""); resp.StatusCode != http.StatusOK {
+				t.Fatalf("DELETE /projects/2 returned %d: %s", resp.StatusCode, body)
+			}
+			if got := strings.Join(listSubjects(t, srv, "alice", "/todos"), ","); got != c.todos {
+				t.Errorf("unexpected todos %q, want %q", got, c.todos)
+			}
+			if got := strings.Join(listSubjects(t, srv, "alice", "/trash"), ","); got != c.trash {
+				t.Errorf("unexpected trash %q, want %q", got, c.trash)
+			}
+			if c.trash == "" {
+				return
+			}
+
+			// ゴミ箱への移動は履歴に残り、復元したTODOはInboxに戻る
+			for _, path := range []string{"/todos/1/history", "/todos/2/history"} {
+				resp, body := testRequest(t, srv, "alice", http.MethodGet, path, "")
+				if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"delete"`) {
+					t.Errorf("unexpected history of %s %d: %s", path, resp.StatusCode, body)
+				}
+			}
+			resp, body := testRequest(t, srv, "alice", http.MethodPost, "/trash/restore", `{"ids":[1]}`)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST /trash/restore returned %d: %s", resp.StatusCode, body)
+			}
+			resp, body = testRequest(t, srv, "alice", http.MethodGet, "/todos/2", "")
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET /todos/2 returned %d: %s", resp.StatusCode, body)
+			}
+			var got model.GetTODOResponse
+			decodeBody(t, body, &got)
+			if got.TODO.ProjectID != nil || got.TODO.ParentID == nil || *got.TODO.ParentID != 1 {
+				t.Errorf("unexpected restored subtask: %s", body)
+			}
+		})
+	}
+}
+
+func TestUpdateTODOProject(t *testing.T) {
+	t.Parallel()
+
+	// aliceがプロジェクト2と3を作成し、TODO 1をプロジェクト2に作成した後に、aliceとしてリクエストする
+	cases := map[string]struct {
+		method  string
+		path    string
+		body    string
+		status  int
+		project int64
+	}{
+		"PUT omitted":             {method: http.MethodPut, path: "/todos/1", body: `{"subject":"a"}`, project: 2},
+		"PUT null":                {method: http.MethodPut, path: "/todos/1", body: `{"subject":"a","project_id":null}`},
+		"PUT zero":                {method: http.MethodPut, path: "/todos/1", body: `{"subject":"a","project_id":0}`},
+		"PUT another project":     {method: http.MethodPut, path: "/todos/1", body: `{"subject":"a","project_id":3}`, project: 3},
+		"PUT unknown project":     {method: http.MethodPut, path: "/todos/1", body: `{"subject":"a","project_id":99}`, status: http.StatusBadRequest, project: 2},
+		"Legacy PUT omitted":      {method: http.MethodPut, path: "/todos", body: `{"id":1,"subject":"a"}`, project: 2},
+		"Legacy PUT null":         {method: http.MethodPut, path: "/todos", body: `{"id":1,"subject":"a","project_id":null}`},
+		"POST unknown project":    {method: http.MethodPost, path: "/todos", body: `{"subject":"b","project_id":99}`, status: http.StatusBadRequest, project: 2},
+		"POST unknown parent":     {method: http.MethodPost, path: "/todos", body: `{"subject":"b","parent_id":99}`, status: http.StatusBadRequest, project: 2},
+		"POST missing project":    {method: http.MethodPost, path: "/projects/99/todos", body: `{"subject":"b"}`, status: http.StatusNotFound, project: 2},
+		"POST project mismatch":   {method: http.MethodPost, path: "/projects/2/todos", body: `{"subject":"b","project_id":3}`, status: http.StatusBadRequest, project: 2},
+		"POST subject is empty":   {method: http.MethodPost, path: "/todos", body: `{"subject":""}`, status: http.StatusBadRequest, project: 2},
+		"POST invalid body":       {method: http.MethodPost, path: "/todos", body: `{"subject":`, status: http.StatusBadRequest, project: 2},
+		"POST to another project": {method: http.MethodPost, path: "/projects/3/todos", body: `{"subject":"b"}`, project: 2},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, d := newTestServer(t)
+			createTestUser(t, d, "alice")
+			for _, req := range []struct{ path, body string }{
+				{"/projects", `{"name":"work"}`},
+				{"/projects", `{"name":"home"}`},
+				{"/todos", `{"subject":"a","project_id":2}`},
+			} {
+				if resp, body := testRequest(t, srv, "alice", http.MethodPost, req.path, req.body); resp.StatusCode != http.StatusOK {
+					t.Fatalf("POST %s returned %d: %s", req.path, resp.StatusCode, body)
+				}
+			}
+
+			status := c.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if resp, body := testRequest(t, srv, "alice", c.method, c.path, c.body); resp.StatusCode != status {
+				t.Fatalf("unexpected status %d, want %d: %s", resp.StatusCode, status, body)
+			}
+
+			// InboxのTODOはproject_idがnullになる
+			resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/1", "")
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET /todos/1 returned %d: %s", resp.StatusCode, body)
+			}
+			var got model.GetTODOResponse
+			decodeBody(t, body, &got)
+			switch {
+			case c.project == 0 && got.TODO.ProjectID != nil,
+				c.project != 0 && (got.TODO.ProjectID == nil || *got.TODO.ProjectID != c.project):
+				t.Errorf("unexpected project_id of the todo, want %d: %s", c.project, body)
+			}
+		})
+	}
+}
+
+func TestUpdateTODORoundTrip(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	for _, req := range []struct{ path, body string }{
+		{"/projects", `{"name":"work"}`},
+		{"/todos", `{"subject":"a","project_id":2}`},
+	} {
+		if resp, body := testRequest(t, srv, "alice", http.MethodPost, req.path, req.body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s returned %d: %s", req.path, resp.StatusCode, body)
+		}
+	}
+
+	// read returns the todo object of GET /todos/1 as it is.
+	read := func() map[string]interface{} {
+		t.Helper()
+
+		resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/1", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /todos/1 returned %d: %s", resp.StatusCode, body)
+		}
+		var got struct {
+			TODO map[string]interface{} `json:"todo"`
+		}
+		decodeBody(t, body, &got)
+		return got.TODO
+	}
+	// write puts the todo object back to /todos/1.
+	write := func(todo map[string]interface{}) {
+		t.Helper()
+
+		b, err := json.Marshal(todo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp, body := testRequest(t, srv, "alice", http.MethodPut, "/todos/1", string(b)); resp.StatusCode != http.StatusOK {
+			t.Fatalf("PUT /todos/1 returned %d: %s", resp.StatusCode, body)
+		}
+	}
+
+	// 読み取ったTODOのproject_idをnullにして書き戻すとInboxに移り、そのまま書き戻してもInboxに残る
+	todo := read()
+	if todo["project_id"] != 2.0 {
+		t.Fatalf("unexpected project_id %v", todo["project_id"])
+	}
+	write(todo)
+	if got := read()["project_id"]; got != 2.0 {
+		t.Errorf("unexpected project_id %v after writing back the todo", got)
+	}
+	todo["project_id"] = nil
+	write(todo)
+	todo = read()
+	if got, ok := todo["project_id"]; !ok || got != nil {
+		t.Errorf("unexpected project_id %v after moving to the inbox", got)
+	}
+	write(todo)
+	if got := read()["project_id"]; got != nil {
+		t.Errorf("unexpected project_id %v after writing back the inbox todo", got)
+	}
+}
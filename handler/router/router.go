@@ -6,10 +6,11 @@ import (
 
 	"github.com/TechBowl-japan/go-stations/handler"
 	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/TechBowl-japan/go-stations/model"
 	"github.com/TechBowl-japan/go-stations/service"
 )
 
-func NewRouter(todoDB *sql.DB, username, password string) *http.ServeMux {
+func NewRouter(todoDB *sql.DB, cursorKey []byte, attachments *service.AttachmentService, oidc *service.OIDCService) *http.ServeMux {
 	// register routes
 	mux := http.NewServeMux()
 	// /healthzの時にHealthzHandlerを呼び出す
@@ -18,9 +19,64 @@ func NewRouter(todoDB *sql.DB, username, password string) *http.ServeMux {
 	// do-panicの時にmiddlewareのRecoveryを通してDoPanicHandlerを呼び出す
 	mux.Handle("/do-panic", middleware.Recovery(handler.NewDoPanicHandler()))
 
+	// ログインとトークンの更新、およびアクセストークンを検証する公開鍵の取得には認証を必要としない.
+	// OpenID ConnectでのログインもIdPで認証するので、ここでは認証を必要としない
+	sessions := service.NewAuthService(todoDB)
+	authHandler := middleware.AccessLoggingMiddleware(handler.NewAuthHandler(sessions))
+	mux.Handle("/auth/", authHandler)
+	mux.Handle("/.well-known/jwks.json", authHandler)
+	mux.Handle("/auth/oidc/", middleware.AccessLoggingMiddleware(handler.NewOIDCHandler(oidc)))
+
+	// Bearerトークンがある場合はAPIトークンかログインで発行したアクセストークンで、ない場合はusersテーブルのユーザーでBasic認証を行う.
+	// APIトークンで認証した場合は、GETとHEADにはread、それ以外にはwriteのスコープが必要になる
+	users := service.NewUserService(todoDB)
+	tokens := service.NewTokenService(todoDB)
+	authenticate := func(h http.Handler, read, write model.TokenScope) http.Handler {
+		h = middleware.AccessLoggingMiddleware(middleware.ScopeMiddleware(h, read, write))
+		return middleware.BearerAuthMiddleware(h, tokens, sessions, middleware.BasicAuthMiddleware(h, users))
+	}
+
+	// ユーザーとAPIトークンの管理にはadminのスコープが必要.
+	// ユーザーの登録は管理者のユーザーに限り、/users/meは自分自身なので誰でも使える
+	userHandler := handler.NewUserHandler(users)
+	mux.Handle("/users", authenticate(middleware.AdminMiddleware(userHandler), model.TokenScopeAdmin, model.TokenScopeAdmin))
+	mux.Handle("/users/", authenticate(userHandler, model.TokenScopeAdmin, model.TokenScopeAdmin))
+
+	tokenHandler := authenticate(handler.NewTokenHandler(tokens), model.TokenScopeAdmin, model.TokenScopeAdmin)
+	mux.Handle("/tokens", tokenHandler)
+	mux.Handle("/tokens/", tokenHandler)
+
+	// アクセストークンの署名鍵のローテーションにもadminのスコープと管理者のユーザーが必要
+	mux.Handle("/signing-keys", authenticate(middleware.AdminMiddleware(handler.NewSigningKeyHandler(sessions)), model.TokenScopeAdmin, model.TokenScopeAdmin))
+
 	//todoDBを使ってserviceを作成
 	todoService := service.NewTODOService(todoDB)
-	mux.Handle("/todos", middleware.BasicAuthMiddleware(middleware.AccessLoggingMiddleware(handler.NewTODOHandler(todoService)), username, password))
+	todos := handler.NewTODOHandler(todoService, cursorKey)
+	todos.Handle("comments", handler.NewCommentHandler(service.NewCommentService(todoDB), cursorKey))
+	todos.Handle("dependencies", handler.NewDependencyHandler(todoService))
+	todos.Handle("attachments", handler.NewAttachmentHandler(attachments))
+	todos.Handle("checklist", handler.NewChecklistHandler(service.NewChecklistService(todoDB)))
+	timeEntryService := service.NewTimeEntryService(todoDB)
+	todos.Handle("timer", handler.NewTimerHandler(timeEntryService))
+	todos.Handle("time-entries", handler.NewTimeEntryHandler(timeEntryService))
+	todoHandler := authenticate(todos, model.TokenScopeTODOsRead, model.TokenScopeTODOsWrite)
+	mux.Handle("/todos", todoHandler)
+	mux.Handle("/todos/", todoHandler)
+
+	tagHandler := authenticate(handler.NewTagHandler(service.NewTagService(todoDB)), model.TokenScopeTODOsRead, model.TokenScopeTODOsWrite)
+	mux.Handle("/tags", tagHandler)
+	mux.Handle("/tags/", tagHandler)
+
+	projectHandler := authenticate(handler.NewProjectHandler(service.NewProjectService(todoDB), todos, handler.NewCustomFieldHandler(service.NewCustomFieldService(todoDB))), model.TokenScopeTODOsRead, model.TokenScopeTODOsWrite)
+	mux.Handle("/projects", projectHandler)
+	mux.Handle("/projects/", projectHandler)
+
+	reportHandler := authenticate(handler.NewReportHandler(timeEntryService), model.TokenScopeTODOsRead, model.TokenScopeTODOsWrite)
+	mux.Handle("/reports/", reportHandler)
+
+	trashHandler := authenticate(handler.NewTrashHandler(service.NewTrashService(todoDB)), model.TokenScopeTODOsRead, model.TokenScopeTODOsWrite)
+	mux.Handle("/trash", trashHandler)
+	mux.Handle("/trash/", trashHandler)
 
 	return mux
 }
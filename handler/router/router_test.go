@@ -0,0 +1,176 @@
+package router_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler/router"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPassword is the password of every user created by createTestUser.
+const testPassword = "password"
+
+func TestMain(m *testing.M) {
+	// リクエストごとにBasic認証でパスワードを照合するので、テストでは最小のコストでハッシュを作成する
+	if err := service.SetPasswordCost(bcrypt.MinCost); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestServer returns the server under test with a new DB.
+func newTestServer(t *testing.T) (*httptest.Server, *sql.DB) {
+	t.Helper()
+
+	d := newTestDB(t)
+	return startTestServer(t, d, service.NewAttachmentService(d, t.TempDir(), 0, 0)), d
+}
+
+// newTestDB returns a new DB closed at the end of the test.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	d, err := db.NewDB(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+// startTestServer returns the server under test with d and the attachment service.
+func startTestServer(t *testing.T, d *sql.DB, attachments *service.AttachmentService) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(router.NewRouter(d, []byte("test"), attachments, service.NewOIDCService(d, service.OIDCConfig{})))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// createTestUser creates the user with testPassword.
+func createTestUser(t *testing.T, d *sql.DB, name string) *model.User {
+	t.Helper()
+
+	user, err := service.NewUserService(d).CreateUser(context.Background(), name, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// createTestAdmin creates the user with testPassword as an admin.
+func createTestAdmin(t *testing.T, d *sql.DB, name string) *model.User {
+	t.Helper()
+
+	user, err := service.NewUserService(d).EnsureAdmin(context.Background(), name, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// testRequest sends the request to srv as the user with Basic authentication and returns the response and its body.
+// userが空の場合は認証しない. headerにはヘッダーの名前と値を交互に並べる.
+func testRequest(t *testing.T, srv *httptest.Server, user, method, path, body string, header ...string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != "" {
+		req.SetBasicAuth(user, testPassword)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(b)
+}
+
+// decodeBody decodes the JSON response body into v.
+func decodeBody(t *testing.T, body string, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal([]byte(body), v); err != nil {
+		t.Fatalf("failed to decode %q, err = %v", body, err)
+	}
+}
+
+// listSubjects returns the subjects of the TODOs listed by GET path as the user.
+func listSubjects(t *testing.T, srv *httptest.Server, user, path string) []string {
+	t.Helper()
+
+	resp, body := testRequest(t, srv, user, http.MethodGet, path, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s returned %d: %s", path, resp.StatusCode, body)
+	}
+	var list struct {
+		TODOs []model.TODO `json:"todos"`
+	}
+	decodeBody(t, body, &list)
+
+	subjects := make([]string, len(list.TODOs))
+	for i, todo := range list.TODOs {
+		subjects[i] = todo.Subject
+	}
+	return subjects
+}
+
+func TestAdoptTODOs(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	alice := createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+
+	// ユーザーのいないcontextで作成したTODOには所有者がいない
+	if _, err := service.NewTODOService(d).CreateTODO(context.Background(), &model.CreateTODORequest{Subject: "legacy"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := listSubjects(t, srv, "alice", "/todos"); len(got) != 0 {
+		t.Errorf("unexpected todos before adoption: %q", got)
+	}
+
+	adopted, err := service.NewUserService(d).AdoptTODOs(context.Background(), alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adopted != 1 {
+		t.Errorf("adopted %d todos, want 1", adopted)
+	}
+
+	if got := listSubjects(t, srv, "alice", "/todos"); len(got) != 1 || got[0] != "legacy" {
+		t.Errorf("unexpected todos of the adopting user: %q", got)
+	}
+	if got := listSubjects(t, srv, "bob", "/todos"); len(got) != 0 {
+		t.Errorf("unexpected todos of another user: %q", got)
+	}
+
+	// 作成時の履歴も引き継ぐ
+	resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/1/history", "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"create"`) {
+		t.Errorf("unexpected history %d: %s", resp.StatusCode, body)
+	}
+}
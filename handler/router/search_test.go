@@ -0,0 +1,108 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// searchSubjects returns the subjects and the results of GET /todos/search with the query as the user.
+func searchSubjects(t *testing.T, srv *httptest.Server, user, query string) ([]string, []model.SearchTODOResult) {
+	t.Helper()
+
+	resp, body := testRequest(t, srv, user, http.MethodGet, "/todos/search?"+query, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /todos/search returned %d: %s", resp.StatusCode, body)
+	}
+	var got model.SearchTODOResponse
+	decodeBody(t, body, &got)
+	subjects := make([]string, len(got.Results))
+	for i, result := range got.Results {
+		subjects[i] = result.TODO.Subject
+	}
+	return subjects, got.Results
+}
+
+func TestSearchTODO(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+	for _, req := range []struct{ user, body string }{
+		{"alice", `{"subject":"buy milk","description":"at the store"}`},
+		{"alice", `{"subject":"call bob","description":"ask about the milk"}`},
+		{"alice", `{"subject":"milk milk"}`},
+		{"alice", `{"subject":"water the plants"}`},
+		{"alice", `{"subject":"trashed milk"}`},
+		{"bob", `{"subject":"milk of bob"}`},
+	} {
+		if resp, body := testRequest(t, srv, req.user, http.MethodPost, "/todos", req.body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+		}
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/todos/5", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /todos/5 returned %d: %s", resp.StatusCode, body)
+	}
+
+	for query, status := range map[string]int{
+		"":               http.StatusBadRequest,
+		"?q=+":           http.StatusBadRequest,
+		"?q=milk&size=0": http.StatusBadRequest,
+	} {
+		if resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/search"+query, ""); resp.StatusCode != status {
+			t.Errorf("GET /todos/search%s returned %d, want %d: %s", query, resp.StatusCode, status, body)
+		}
+	}
+
+	// FTS5はsqlite_fts5のビルドタグを付けた場合のみ使え、付けない場合は501を返す.
+	// 検索結果はgo test -tags sqlite_fts5で確かめる
+	if !fts5 {
+		if resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/search?q=milk", ""); resp.StatusCode != http.StatusNotImplemented {
+			t.Errorf("GET /todos/search without FTS5 returned %d: %s", resp.StatusCode, body)
+		}
+		return
+	}
+
+	// 件名に多く含むほど上位になり、ゴミ箱と他のユーザーのTODOは含まない
+	subjects, results := searchSubjects(t, srv, "alice", "q=milk")
+	if got := strings.Join(subjects, ","); got != "milk milk,buy milk,call bob" {
+		t.Fatalf("unexpected results %q", got)
+	}
+	if results[1].SubjectHighlight != "buy <mark>milk</mark>" || !strings.Contains(results[2].DescriptionSnippet, "<mark>milk</mark>") {
+		t.Errorf("unexpected highlights %+v", results)
+	}
+
+	// prev_idで次の順位から続きを返す
+	var paged []string
+	prevID := int64(0)
+	for i := 0; i < 3; i++ {
+		subjects, results := searchSubjects(t, srv, "alice", "q=milk&size=2&prev_id="+strconv.FormatInt(prevID, 10))
+		paged = append(paged, subjects...)
+		if len(results) < 2 {
+			break
+		}
+		prevID = results[len(results)-1].TODO.ID
+	}
+	if got := strings.Join(paged, ","); got != "milk milk,buy milk,call bob" {
+		t.Errorf("unexpected paged results %q", got)
+	}
+
+	// 更新した内容で検索でき、記号を含む検索語もエラーにならない
+	if resp, body := testRequest(t, srv, "alice", http.MethodPut, "/todos/4", `{"subject":"water the milk"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /todos/4 returned %d: %s", resp.StatusCode, body)
+	}
+	subjects, _ = searchSubjects(t, srv, "alice", "q="+url.QueryEscape("water milk"))
+	if got := strings.Join(subjects, ","); got != "water the milk" {
+		t.Errorf("unexpected results of the updated todo %q", got)
+	}
+	subjects, _ = searchSubjects(t, srv, "alice", "q="+url.QueryEscape(`"milk OR*`))
+	if len(subjects) != 0 {
+		t.Errorf("unexpected results of the quoted query %q", subjects)
+	}
+}
@@ -0,0 +1,130 @@
+package router_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestTagFilter(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	for _, req := range []struct{ path, body string }{
+		{"/tags", `{"name":"work"}`},
+		{"/tags", `{"name":"home"}`},
+		{"/tags", `{"name":"urgent"}`},
+		{"/todos", `{"subject":"a","tag_ids":[1]}`},
+		{"/todos", `{"subject":"b","tag_ids":[1,2]}`},
+		{"/todos", `{"subject":"c","tag_ids":[2,3]}`},
+		{"/todos", `{"subject":"d"}`},
+	} {
+		if resp, body := testRequest(t, srv, "alice", http.MethodPost, req.path, req.body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s returned %d: %s", req.path, resp.StatusCode, body)
+		}
+	}
+
+	cases := map[string]struct {
+		query  string
+		status int
+		want   string
+	}{
+		"No filter":        {query: "", want: "d,c,b,a"},
+		"Any of one":       {query: "tags_any=1", want: "b,a"},
+		"Any of two":       {query: "tags_any=1,3", want: "c,b,a"},
+		"All of two":       {query: "tags_all=1,2", want: "b"},
+		"All of unrelated": {query: "tags_all=1,3", want: ""},
+		"Any and all":      {query: "tags_any=1,3&tags_all=2", want: "c,b"},
+		"Unknown tag":      {query: "tags_any=99", want: ""},
+		"Invalid id":       {query: "tags_any=work", status: http.StatusBadRequest},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if c.status != 0 {
+				if resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos?"+c.query, ""); resp.StatusCode != c.status {
+					t.Errorf("unexpected status %d, want %d: %s", resp.StatusCode, c.status, body)
+				}
+				return
+			}
+			if got := strings.Join(listSubjects(t, srv, "alice", "/todos?size=10&"+c.query), ","); got != c.want {
+				t.Errorf("unexpected todos %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTagAttachAndDetach(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	for _, req := range []struct{ path, body string }{
+		{"/tags", `{"name":"work"}`},
+		{"/tags", `{"name":"home"}`},
+		{"/todos", `{"subject":"a","tag_ids":[1]}`},
+	} {
+		if resp, body := testRequest(t, srv, "alice", http.MethodPost, req.path, req.body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s returned %d: %s", req.path, resp.StatusCode, body)
+		}
+	}
+
+	// tagNames returns the names of the tags of TODO 1.
+	tagNames := func() string {
+		t.Helper()
+
+		resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/1", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /todos/1 returned %d: %s", resp.StatusCode, body)
+		}
+		var got model.GetTODOResponse
+		decodeBody(t, body, &got)
+		names := make([]string, len(got.TODO.Tags))
+		for i, tag := range got.TODO.Tags {
+			names[i] = tag.Name
+		}
+		return strings.Join(names, ",")
+	}
+
+	// 付け外しは同じリクエストで行え、見つからないタグがある場合は何も変えない
+	if resp, body := testRequest(t, srv, "alice", http.MethodPut, "/todos/1", `{"subject":"a","attach_tag_ids":[2],"detach_tag_ids":[1]}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /todos/1 returned %d: %s", resp.StatusCode, body)
+	}
+	if got := tagNames(); got != "home" {
+		t.Errorf("unexpected tags %q after attach and detach", got)
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodPut, "/todos/1", `{"subject":"a","attach_tag_ids":[1,99]}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("attaching an unknown tag returned %d: %s", resp.StatusCode, body)
+	}
+	if got := tagNames(); got != "home" {
+		t.Errorf("unexpected tags %q after the failed attach", got)
+	}
+
+	// 同じ名前のタグは作成できず、名前の変更はTODOにも反映される
+	if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/tags", `{"name":"home"}`); resp.StatusCode != http.StatusConflict {
+		t.Errorf("POST /tags with a duplicate name returned %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodPut, "/tags/2", `{"name":"house"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /tags/2 returned %d: %s", resp.StatusCode, body)
+	}
+	if got := tagNames(); got != "house" {
+		t.Errorf("unexpected tags %q after rename", got)
+	}
+
+	// 削除したタグはTODOからも外れる
+	if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/tags/2", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /tags/2 returned %d: %s", resp.StatusCode, body)
+	}
+	if got := tagNames(); got != "" {
+		t.Errorf("unexpected tags %q after delete", got)
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodGet, "/tags/2", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /tags/2 returned %d: %s", resp.StatusCode, body)
+	}
+}
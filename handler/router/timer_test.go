@@ -0,0 +1,105 @@
+package router_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/common"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestTimerOnTrashedTODO(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		trash   string
+		restore string
+	}{
+		"Delete TODO":        {trash: "/todos/2", restore: "2"},
+		"Delete parent TODO": {trash: "/todos/1", restore: "1"},
+		"Delete project":     {trash: "/projects/2?todos=cascade", restore: "1"},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, d := newTestServer(t)
+			createTestUser(t, d, "alice")
+			for _, req := range []struct{ path, body string }{
+				{"/projects", `{"name":"work"}`},
+				{"/todos", `{"subject":"parent","project_id":2}`},
+				{"/todos", `{"subject":"a","project_id":2,"parent_id":1}`},
+				{"/todos", `{"subject":"other"}`},
+				{"/todos/2/timer/start", ""},
+			} {
+				if resp, body := testRequest(t, srv, "alice", http.MethodPost, req.path, req.body); resp.StatusCode != http.StatusOK {
+					t.Fatalf("POST %s returned %d: %s", req.path, resp.StatusCode, body)
+				}
+			}
+
+			if resp, body := testRequest(t, srv, "alice", http.MethodDelete, c.trash, ""); resp.StatusCode != http.StatusOK {
+				t.Fatalf("DELETE %s returned %d: %s", c.trash, resp.StatusCode, body)
+			}
+
+			// ゴミ箱に移動したTODOのタイマーは止まっているので、別のTODOで開始できる
+			if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos/3/timer/start", ""); resp.StatusCode != http.StatusOK {
+				t.Errorf("POST /todos/3/timer/start returned %d: %s", resp.StatusCode, body)
+			}
+
+			// 復元しても止まったまま
+			if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/trash/restore", `{"ids":[`+c.restore+`]}`); resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST /trash/restore returned %d: %s", resp.StatusCode, body)
+			}
+			resp, body := testRequest(t, srv, "alice", http.MethodGet, "/todos/2/time-entries", "")
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET /todos/2/time-entries returned %d: %s", resp.StatusCode, body)
+			}
+			var entries model.ReadTimeEntryResponse
+			decodeBody(t, body, &entries)
+			if len(entries.TimeEntries) != 1 || entries.TimeEntries[0].StoppedAt == nil {
+				t.Errorf("unexpected time entries: %s", body)
+			}
+		})
+	}
+}
+
+func TestTimerIsKeyedByUserID(t *testing.T) {
+	t.Parallel()
+
+	_, d := newTestServer(t)
+	todos := service.NewTODOService(d)
+	timers := service.NewTimeEntryService(d)
+
+	// 表示名が同じでも、別のユーザーのタイマーは区別する
+	var ctxs []context.Context
+	for _, name := range []string{"alice", "bob"} {
+		user := createTestUser(t, d, name)
+		ctx := common.SetActor(common.SetUserID(context.Background(), user.ID), "same")
+		todo, err := todos.CreateTODO(ctx, &model.CreateTODORequest{Subject: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := timers.StartTimer(ctx, todo.ID, ""); err != nil {
+			t.Fatalf("failed to start the timer of %s, err = %v", name, err)
+		}
+		ctxs = append(ctxs, ctx)
+	}
+
+	if _, err := timers.StopTimer(ctxs[0], 2); err == nil {
+		t.Error("stopped the timer of another user")
+	}
+	entry, err := timers.StopTimer(ctxs[1], 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Owner != "same" || entry.StoppedAt == nil {
+		t.Errorf("unexpected time entry %+v", entry)
+	}
+	if _, err := timers.UpdateTimeEntry(ctxs[0], 2, entry.ID, entry.StartedAt, entry.StoppedAt, "edited"); err == nil {
+		t.Error("edited the time entry of another user")
+	}
+}
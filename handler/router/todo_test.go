@@ -0,0 +1,145 @@
+package router_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestTODOItem(t *testing.T) {
+	t.Parallel()
+
+	// aliceがTODO 1、bobがTODO 2を作成した後に、aliceとしてリクエストする
+	cases := map[string]struct {
+		method      string
+		path        string
+		body        string
+		contentType string
+		status      int
+		subject     string
+	}{
+		"GET":                     {method: http.MethodGet, path: "/todos/1", status: http.StatusOK, subject: "a"},
+		"GET not found":           {method: http.MethodGet, path: "/todos/99", status: http.StatusNotFound},
+		"GET another owner":       {method: http.MethodGet, path: "/todos/2", status: http.StatusNotFound},
+		"GET invalid id":          {method: http.MethodGet, path: "/todos/abc", status: http.StatusNotFound},
+		"PUT":                     {method: http.MethodPut, path: "/todos/1", body: `{"subject":"b"}`, status: http.StatusOK, subject: "b"},
+		"PUT id mismatch":         {method: http.MethodPut, path: "/todos/1", body: `{"id":2,"subject":"b"}`, status: http.StatusBadRequest},
+		"PUT not found":           {method: http.MethodPut, path: "/todos/99", body: `{"subject":"b"}`, status: http.StatusNotFound},
+		"PUT another owner":       {method: http.MethodPut, path: "/todos/2", body: `{"subject":"b"}`, status: http.StatusNotFound},
+		"PATCH":                   {method: http.MethodPatch, path: "/todos/1", body: `{"subject":"b"}`, contentType: "application/merge-patch+json", status: http.StatusOK, subject: "b"},
+		"PATCH wrong media type":  {method: http.MethodPatch, path: "/todos/1", body: `{"subject":"b"}`, contentType: "text/plain", status: http.StatusUnsupportedMediaType},
+		"DELETE":                  {method: http.MethodDelete, path: "/todos/1", status: http.StatusOK},
+		"DELETE not found":        {method: http.MethodDelete, path: "/todos/99", status: http.StatusNotFound},
+		"DELETE another owner":    {method: http.MethodDelete, path: "/todos/2", status: http.StatusNotFound},
+		"POST method not allowed": {method: http.MethodPost, path: "/todos/1", status: http.StatusMethodNotAllowed},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, d := newTestServer(t)
+			createTestUser(t, d, "alice")
+			createTestUser(t, d, "bob")
+			for _, user := range []string{"alice", "bob"} {
+				if resp, body := testRequest(t, srv, user, http.MethodPost, "/todos", `{"subject":"a"}`); resp.StatusCode != http.StatusOK {
+					t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+				}
+			}
+
+			var header []string
+			if c.contentType != "" {
+				header = []string{"Content-Type", c.contentType}
+			}
+			resp, body := testRequest(t, srv, "alice", c.method, c.path, c.body, header...)
+			if resp.StatusCode != c.status {
+				t.Fatalf("unexpected status %d, want %d: %s", resp.StatusCode, c.status, body)
+			}
+			if c.subject != "" {
+				var got model.GetTODOResponse
+				decodeBody(t, body, &got)
+				if got.TODO.ID != 1 || got.TODO.Subject != c.subject {
+					t.Errorf("unexpected todo %+v", got.TODO)
+				}
+			}
+
+			// 削除したTODOは見つからなくなり、他のTODOは変わらない
+			want := []string{"a"}
+			switch {
+			case c.status == http.StatusOK && c.method == http.MethodDelete:
+				want = nil
+			case c.subject != "":
+				want = []string{c.subject}
+			}
+			if got := listSubjects(t, srv, "alice", "/todos"); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("unexpected todos of alice %q, want %q", got, want)
+			}
+			if got := listSubjects(t, srv, "bob", "/todos"); len(got) != 1 || got[0] != "a" {
+				t.Errorf("unexpected todos of bob %q", got)
+			}
+		})
+	}
+}
+
+func TestBulkDeleteAndRestore(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	for _, body := range []string{
+		`{"subject":"a"}`,
+		`{"subject":"b"}`,
+		`{"subject":"b-1","parent_id":2}`,
+		`{"subject":"c"}`,
+	} {
+		if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos", body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+		}
+	}
+
+	// idsが空の場合と、見つからないidだけの場合は何も削除しない
+	for body, status := range map[string]int{
+		`{"ids":[]}`:   http.StatusBadRequest,
+		`{"ids":[99]}`: http.StatusNotFound,
+	} {
+		if resp, got := testRequest(t, srv, "alice", http.MethodDelete, "/todos", body); resp.StatusCode != status {
+			t.Errorf("DELETE /todos %s returned %d, want %d: %s", body, resp.StatusCode, status, got)
+		}
+	}
+
+	// まとめて削除すると、サブタスクも一緒にゴミ箱に移動する
+	if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/todos", `{"ids":[1,2]}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /todos returned %d: %s", resp.StatusCode, body)
+	}
+	if got := strings.Join(listSubjects(t, srv, "alice", "/todos"), ","); got != "c" {
+		t.Errorf("unexpected todos %q after delete", got)
+	}
+	if got := strings.Join(listSubjects(t, srv, "alice", "/trash"), ","); got != "b,a" {
+		t.Errorf("unexpected trash %q", got)
+	}
+
+	// サブタスクだけは復元できず、親を復元するとサブタスクも戻る
+	if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/trash/restore", `{"ids":[3]}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("restoring the subtask returned %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/trash/restore", `{"ids":[2]}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /trash/restore returned %d: %s", resp.StatusCode, body)
+	}
+	if got := strings.Join(listSubjects(t, srv, "alice", "/todos"), ","); got != "c,b-1,b" {
+		t.Errorf("unexpected todos %q after restore", got)
+	}
+
+	// 完全に削除したTODOは復元できない
+	if resp, body := testRequest(t, srv, "alice", http.MethodDelete, "/trash", `{"ids":[1]}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /trash returned %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/trash/restore", `{"ids":[1]}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("restoring the purged todo returned %d: %s", resp.StatusCode, body)
+	}
+	if got := listSubjects(t, srv, "alice", "/trash"); len(got) != 0 {
+		t.Errorf("unexpected trash %q after purge", got)
+	}
+}
@@ -0,0 +1,159 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// createTestToken creates an API token of the user with the request body and returns the response.
+func createTestToken(t *testing.T, srv *httptest.Server, user, body string) *model.CreateAPITokenResponse {
+	t.Helper()
+
+	resp, got := testRequest(t, srv, user, http.MethodPost, "/tokens", body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /tokens returned %d: %s", resp.StatusCode, got)
+	}
+	var created model.CreateAPITokenResponse
+	decodeBody(t, got, &created)
+	return &created
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	read := createTestToken(t, srv, "alice", `{"name":"read","scopes":["todos:read"]}`)
+	write := createTestToken(t, srv, "alice", `{"name":"write","scopes":["todos:write"]}`)
+	both := createTestToken(t, srv, "alice", `{"name":"both","scopes":["todos:read","todos:write"]}`)
+	admin := createTestToken(t, srv, "alice", `{"name":"admin","scopes":["admin"]}`)
+
+	// GETとHEADにはread、それ以外にはwriteのスコープが必要で、adminはすべてに使える.
+	// 管理者でないユーザーはadminのスコープがあっても/usersを使えない
+	cases := map[string]struct {
+		secret string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		"Read with read":         {secret: read.Secret, method: http.MethodGet, path: "/todos", status: http.StatusOK},
+		"Write with read":        {secret: read.Secret, method: http.MethodPost, path: "/todos", body: `{"subject":"a"}`, status: http.StatusForbidden},
+		"Read with write":        {secret: write.Secret, method: http.MethodGet, path: "/todos", status: http.StatusForbidden},
+		"Write with write":       {secret: write.Secret, method: http.MethodPost, path: "/todos", body: `{"subject":"a"}`, status: http.StatusOK},
+		"Read with both":         {secret: both.Secret, method: http.MethodGet, path: "/projects", status: http.StatusOK},
+		"Tokens with both":       {secret: both.Secret, method: http.MethodGet, path: "/tokens", status: http.StatusForbidden},
+		"Read with admin":        {secret: admin.Secret, method: http.MethodGet, path: "/todos", status: http.StatusOK},
+		"Write with admin":       {secret: admin.Secret, method: http.MethodPost, path: "/todos", body: `{"subject":"a"}`, status: http.StatusOK},
+		"Tokens with admin":      {secret: admin.Secret, method: http.MethodGet, path: "/tokens", status: http.StatusOK},
+		"Me with admin":          {secret: admin.Secret, method: http.MethodGet, path: "/users/me", status: http.StatusOK},
+		"Users with admin scope": {secret: admin.Secret, method: http.MethodGet, path: "/users", status: http.StatusForbidden},
+		"Unknown token":          {secret: read.Secret + "x", method: http.MethodGet, path: "/todos", status: http.StatusUnauthorized},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, body := testRequest(t, srv, "", c.method, c.path, c.body, "Authorization", "Bearer "+c.secret)
+			if resp.StatusCode != c.status {
+				t.Errorf("unexpected status %d, want %d: %s", resp.StatusCode, c.status, body)
+			}
+		})
+	}
+}
+
+func TestAPITokenLifetime(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+
+	// スコープがないか不明な場合と、期限が過去の場合はトークンを作成できない
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	for _, body := range []string{
+		`{"name":"none","scopes":[]}`,
+		`{"name":"unknown","scopes":["todos:delete"]}`,
+		`{"name":"past","scopes":["todos:read"],"expires_at":"` + past + `"}`,
+	} {
+		if resp, got := testRequest(t, srv, "alice", http.MethodPost, "/tokens", body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST /tokens %s returned %d: %s", body, resp.StatusCode, got)
+		}
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	expiring := createTestToken(t, srv, "alice", `{"name":"expiring","scopes":["todos:read"],"expires_at":"`+future+`"}`)
+	revoked := createTestToken(t, srv, "alice", `{"name":"revoked","scopes":["todos:read"]}`)
+	admin := createTestToken(t, srv, "alice", `{"name":"admin","scopes":["admin"]}`)
+	if expiring.Secret == "" || expiring.Token.LastUsedAt != nil || expiring.Token.ExpiresAt == nil {
+		t.Fatalf("unexpected token %+v", expiring)
+	}
+
+	// use returns the status of GET /todos with the API token secret.
+	use := func(secret string) int {
+		t.Helper()
+
+		resp, _ := testRequest(t, srv, "", http.MethodGet, "/todos", "", "Authorization", "Bearer "+secret)
+		return resp.StatusCode
+	}
+	// 使ったトークンは最後に使われた日時を記録する
+	if status := use(expiring.Secret); status != http.StatusOK {
+		t.Fatalf("GET /todos with the token returned %d", status)
+	}
+	resp, body := testRequest(t, srv, "alice", http.MethodGet, "/tokens", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /tokens returned %d: %s", resp.StatusCode, body)
+	}
+	var tokens model.ReadAPITokenResponse
+	decodeBody(t, body, &tokens)
+	used := map[string]bool{}
+	for _, token := range tokens.Tokens {
+		used[token.Name] = token.LastUsedAt != nil
+	}
+	if len(tokens.Tokens) != 3 || !used["expiring"] || used["revoked"] {
+		t.Errorf("unexpected tokens: %s", body)
+	}
+
+	// 期限が切れたトークンは使えない
+	if _, err := d.Exec(`UPDATE api_tokens SET expires_at = DATETIME('now', '-1 minute') WHERE id = ?`, expiring.Token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status := use(expiring.Secret); status != http.StatusUnauthorized {
+		t.Errorf("GET /todos with the expired token returned %d", status)
+	}
+
+	// 他のユーザーのトークンは失効できず、失効したトークンは使えない
+	path := "/tokens/" + strconv.FormatInt(revoked.Token.ID, 10)
+	if resp, body := testRequest(t, srv, "bob", http.MethodDelete, path, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("DELETE %s as bob returned %d: %s", path, resp.StatusCode, body)
+	}
+	if status := use(revoked.Secret); status != http.StatusOK {
+		t.Fatalf("GET /todos with the token returned %d", status)
+	}
+	var revokedAt *time.Time
+	for i := 0; i < 2; i++ {
+		resp, body := testRequest(t, srv, "", http.MethodDelete, path, "", "Authorization", "Bearer "+admin.Secret)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("DELETE %s returned %d: %s", path, resp.StatusCode, body)
+		}
+		var got model.RevokeAPITokenResponse
+		decodeBody(t, body, &got)
+		if got.Token.RevokedAt == nil || (revokedAt != nil && !got.Token.RevokedAt.Equal(*revokedAt)) {
+			t.Errorf("unexpected revoked token %+v", got.Token)
+		}
+		revokedAt = got.Token.RevokedAt
+	}
+	if status := use(revoked.Secret); status != http.StatusUnauthorized {
+		t.Errorf("GET /todos with the revoked token returned %d", status)
+	}
+	if status := use(admin.Secret); status != http.StatusOK {
+		t.Errorf("GET /todos with another token returned %d", status)
+	}
+}
@@ -0,0 +1,93 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestUpdateTODOKeepsOmittedFields(t *testing.T) {
+	t.Parallel()
+
+	const created = `{"subject":"a","due_at":"2030-01-06T09:00:00Z","remind_at":"2030-01-06T08:00:00Z","rrule":"FREQ=WEEKLY","priority":"high"}`
+
+	cases := map[string]struct {
+		path     string
+		body     string
+		rrule    string
+		priority model.TODOPriority
+		dueAt    string
+	}{
+		"Omitted":          {path: "/todos/1", body: `{"subject":"b"}`, rrule: "FREQ=WEEKLY", priority: "high", dueAt: "2030-01-06T09:00:00Z"},
+		"Legacy omitted":   {path: "/todos", body: `{"id":1,"subject":"b"}`, rrule: "FREQ=WEEKLY", priority: "high", dueAt: "2030-01-06T09:00:00Z"},
+		"Given":            {path: "/todos/1", body: `{"subject":"b","due_at":"2030-02-03T09:00:00Z","rrule":"FREQ=DAILY","priority":"low"}`, rrule: "FREQ=DAILY", priority: "low", dueAt: "2030-02-03T09:00:00Z"},
+		"Stop recurrence":  {path: "/todos/1", body: `{"subject":"b","rrule":""}`, priority: "high", dueAt: "2030-01-06T09:00:00Z"},
+		"Remove priority":  {path: "/todos/1", body: `{"subject":"b","priority":"none"}`, rrule: "FREQ=WEEKLY", priority: "none", dueAt: "2030-01-06T09:00:00Z"},
+		"Null is omission": {path: "/todos/1", body: `{"subject":"b","due_at":null,"rrule":null,"priority":null}`, rrule: "FREQ=WEEKLY", priority: "high", dueAt: "2030-01-06T09:00:00Z"},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, d := newTestServer(t)
+			createTestUser(t, d, "alice")
+			if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos", created); resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+			}
+
+			resp, body := testRequest(t, srv, "alice", http.MethodPut, c.path, c.body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("PUT %s returned %d: %s", c.path, resp.StatusCode, body)
+			}
+			var got model.UpdateTODOResponse
+			decodeBody(t, body, &got)
+			todo := got.TODO
+			if todo.Subject != "b" || todo.RRule != c.rrule || todo.Priority != c.priority || todo.DueAt == nil || todo.DueAt.UTC().Format("2006-01-02T15:04:05Z") != c.dueAt {
+				t.Errorf("unexpected todo: %s", body)
+			}
+			if todo.RemindAt == nil {
+				t.Errorf("remind_at is cleared: %s", body)
+			}
+		})
+	}
+}
+
+func TestLegacyUpdateTODOErrors(t *testing.T) {
+	t.Parallel()
+
+	srv, d := newTestServer(t)
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "bob")
+	if resp, body := testRequest(t, srv, "bob", http.MethodPost, "/todos", `{"subject":"b"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := testRequest(t, srv, "alice", http.MethodPost, "/todos", `{"subject":"a"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /todos returned %d: %s", resp.StatusCode, body)
+	}
+
+	cases := map[string]struct {
+		body   string
+		status int
+	}{
+		"Not found":        {body: `{"id":99,"subject":"c"}`, status: http.StatusNotFound},
+		"Another owner":    {body: `{"id":1,"subject":"c"}`, status: http.StatusNotFound},
+		"Invalid priority": {body: `{"id":2,"subject":"c","priority":"urgent"}`, status: http.StatusBadRequest},
+		"Invalid rrule":    {body: `{"id":2,"subject":"c","rrule":"FREQ=SECONDLY"}`, status: http.StatusBadRequest},
+		"Unknown tag":      {body: `{"id":2,"subject":"c","attach_tag_ids":[99]}`, status: http.StatusBadRequest},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, body := testRequest(t, srv, "alice", http.MethodPut, "/todos", c.body)
+			if resp.StatusCode != c.status {
+				t.Errorf("unexpected status %d, want %d: %s", resp.StatusCode, c.status, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A SigningKeyHandler implements handling REST endpoints of the keys that sign access tokens.
+type SigningKeyHandler struct {
+	svc *service.AuthService
+}
+
+// NewSigningKeyHandler returns SigningKeyHandler based http.Handler.
+func NewSigningKeyHandler(svc *service.AuthService) *SigningKeyHandler {
+	return &SigningKeyHandler{
+		svc: svc,
+	}
+}
+
+// ServeHTTP handles HTTP requests and routes them to the appropriate method.
+// POSTで鍵をローテーションし、以降のアクセストークンは新しい鍵で署名する.
+func (h *SigningKeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/signing-keys" {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Read(ctx, &model.ReadSigningKeyRequest{})
+
+	case http.MethodPost:
+		resp, err = h.Rotate(ctx, &model.RotateSigningKeyRequest{})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Read handles the endpoint that reads the signing keys.
+func (h *SigningKeyHandler) Read(ctx context.Context, req *model.ReadSigningKeyRequest) (*model.ReadSigningKeyResponse, error) {
+	keys, err := h.svc.ReadSigningKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.SigningKey を []model.SigningKey に変換
+	resp := model.ReadSigningKeyResponse{Keys: make([]model.SigningKey, len(keys))}
+	for i, key := range keys {
+		resp.Keys[i] = *key
+	}
+	return &resp, nil
+}
+
+// Rotate handles the endpoint that rotates the signing key.
+func (h *SigningKeyHandler) Rotate(ctx context.Context, req *model.RotateSigningKeyRequest) (*model.RotateSigningKeyResponse, error) {
+	key, err := h.svc.RotateSigningKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &model.RotateSigningKeyResponse{Key: *key}, nil
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A TagHandler implements handling REST endpoints of tags.
+type TagHandler struct {
+	svc *service.TagService
+}
+
+// NewTagHandler returns TagHandler based http.Handler.
+func NewTagHandler(svc *service.TagService) *TagHandler {
+	return &TagHandler{
+		svc: svc,
+	}
+}
+
+// ServeHTTP handles HTTP requests and routes them to the appropriate method.
+func (h *TagHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/tags" {
+		h.serveCollection(w, r)
+		return
+	}
+	id, ok := parseIDPath(r.URL.Path, "/tags/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.serveItem(w, r, id)
+}
+
+// serveCollection handles requests to /tags.
+func (h *TagHandler) serveCollection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Read(ctx, &model.ReadTagRequest{})
+
+	case http.MethodPost:
+		var req model.CreateTagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err = h.Create(ctx, &req)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveItem handles requests to /tags/{id}.
+func (h *TagHandler) serveItem(w http.ResponseWriter, r *http.Request, id int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Get(ctx, &model.GetTagRequest{ID: id})
+
+	case http.MethodPut:
+		var req model.UpdateTagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.ID = id
+		resp, err = h.Update(ctx, &req)
+
+	case http.MethodDelete:
+		resp, err = h.Delete(ctx, &model.DeleteTagRequest{ID: id})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Create handles the endpoint that creates the Tag.
+func (h *TagHandler) Create(ctx context.Context, req *model.CreateTagRequest) (*model.CreateTagResponse, error) {
+	tag, err := h.svc.CreateTag(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateTagResponse{Tag: *tag}, nil
+}
+
+// Read handles the endpoint that reads the Tags.
+func (h *TagHandler) Read(ctx context.Context, req *model.ReadTagRequest) (*model.ReadTagResponse, error) {
+	tags, err := h.svc.ReadTag(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.Tag を []model.Tag に変換
+	resp := model.ReadTagResponse{Tags: make([]model.Tag, len(tags))}
+	for i, tag := range tags {
+		resp.Tags[i] = *tag
+	}
+	return &resp, nil
+}
+
+// Get handles the endpoint that reads the Tag.
+func (h *TagHandler) Get(ctx context.Context, req *model.GetTagRequest) (*model.GetTagResponse, error) {
+	tag, err := h.svc.GetTag(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetTagResponse{Tag: *tag}, nil
+}
+
+// Update handles the endpoint that renames the Tag.
+func (h *TagHandler) Update(ctx context.Context, req *model.UpdateTagRequest) (*model.UpdateTagResponse, error) {
+	tag, err := h.svc.UpdateTag(ctx, req.ID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateTagResponse{Tag: *tag}, nil
+}
+
+// Delete handles the endpoint that deletes the Tag.
+func (h *TagHandler) Delete(ctx context.Context, req *model.DeleteTagRequest) (*model.DeleteTagResponse, error) {
+	if err := h.svc.DeleteTag(ctx, req.ID); err != nil {
+		return nil, err
+	}
+	return &model.DeleteTagResponse{}, nil
+}
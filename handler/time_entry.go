@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A TimerHandler implements handling REST endpoints of timers under /todos/{id}/timer.
+type TimerHandler struct {
+	svc *service.TimeEntryService
+}
+
+// NewTimerHandler returns TimerHandler to be registered with TODOHandler.Handle.
+func NewTimerHandler(svc *service.TimeEntryService) *TimerHandler {
+	return &TimerHandler{
+		svc: svc,
+	}
+}
+
+// ServeTODO handles requests to /todos/{id}/timer/start and /todos/{id}/timer/stop.
+func (h *TimerHandler) ServeTODO(w http.ResponseWriter, r *http.Request, todoID int64, path string) {
+	if path != "start" && path != "stop" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	if path == "start" {
+		// メモを付けない場合はボディを省略できる
+		req := model.StartTimerRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TODOID = todoID
+		resp, err = h.Start(ctx, &req)
+	} else {
+		resp, err = h.Stop(ctx, &model.StopTimerRequest{TODOID: todoID})
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Start handles the endpoint that starts the timer on the TODO.
+func (h *TimerHandler) Start(ctx context.Context, req *model.StartTimerRequest) (*model.StartTimerResponse, error) {
+	entry, err := h.svc.StartTimer(ctx, req.TODOID, req.Note)
+	if err != nil {
+		return nil, err
+	}
+	return &model.StartTimerResponse{TimeEntry: *entry}, nil
+}
+
+// Stop handles the endpoint that stops the timer on the TODO.
+func (h *TimerHandler) Stop(ctx context.Context, req *model.StopTimerRequest) (*model.StopTimerResponse, error) {
+	entry, err := h.svc.StopTimer(ctx, req.TODOID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.StopTimerResponse{TimeEntry: *entry}, nil
+}
+
+// A TimeEntryHandler implements handling REST endpoints of time entries under /todos/{id}/time-entries.
+type TimeEntryHandler struct {
+	svc *service.TimeEntryService
+}
+
+// NewTimeEntryHandler returns TimeEntryHandler to be registered with TODOHandler.Handle.
+func NewTimeEntryHandler(svc *service.TimeEntryService) *TimeEntryHandler {
+	return &TimeEntryHandler{
+		svc: svc,
+	}
+}
+
+// ServeTODO handles requests to /todos/{id}/time-entries and /todos/{id}/time-entries/{entryID}.
+func (h *TimeEntryHandler) ServeTODO(w http.ResponseWriter, r *http.Request, todoID int64, path string) {
+	if path == "" {
+		h.serveCollection(w, r, todoID)
+		return
+	}
+	id, ok := parseIDPath("/"+path, "/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.serveItem(w, r, todoID, id)
+}
+
+// serveCollection handles requests to /todos/{id}/time-entries.
+func (h *TimeEntryHandler) serveCollection(w http.ResponseWriter, r *http.Request, todoID int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		req := model.ReadTimeEntryRequest{TODOID: todoID}
+		if req.PrevID, req.Size, err = parsePaging(r.URL.Query()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err = h.Read(ctx, &req)
+
+	case http.MethodPost:
+		var req model.CreateTimeEntryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TODOID = todoID
+		resp, err = h.Create(ctx, &req)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveItem handles requests to /todos/{id}/time-entries/{entryID}.
+func (h *TimeEntryHandler) serveItem(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Get(ctx, &model.GetTimeEntryRequest{TODOID: todoID, ID: id})
+
+	case http.MethodPut:
+		var req model.UpdateTimeEntryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TODOID, req.ID = todoID, id
+		resp, err = h.Update(ctx, &req)
+
+	case http.MethodDelete:
+		resp, err = h.Delete(ctx, &model.DeleteTimeEntryRequest{TODOID: todoID, ID: id})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Create handles the endpoint that records the time spent on the TODO.
+func (h *TimeEntryHandler) Create(ctx context.Context, req *model.CreateTimeEntryRequest) (*model.CreateTimeEntryResponse, error) {
+	entry, err := h.svc.CreateTimeEntry(ctx, req.TODOID, req.StartedAt, req.StoppedAt, req.Note)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateTimeEntryResponse{TimeEntry: *entry}, nil
+}
+
+// Read handles the endpoint that reads the time entries on the TODO.
+func (h *TimeEntryHandler) Read(ctx context.Context, req *model.ReadTimeEntryRequest) (*model.ReadTimeEntryResponse, error) {
+	entries, err := h.svc.ReadTimeEntry(ctx, req.TODOID, req.PrevID, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.TimeEntry を []model.TimeEntry に変換
+	resp := model.ReadTimeEntryResponse{TimeEntries: make([]model.TimeEntry, len(entries))}
+	for i, entry := range entries {
+		resp.TimeEntries[i] = *entry
+	}
+	return &resp, nil
+}
+
+// Get handles the endpoint that reads the time entry.
+func (h *TimeEntryHandler) Get(ctx context.Context, req *model.GetTimeEntryRequest) (*model.GetTimeEntryResponse, error) {
+	entry, err := h.svc.GetTimeEntry(ctx, req.TODOID, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetTimeEntryResponse{TimeEntry: *entry}, nil
+}
+
+// Update handles the endpoint that edits the time entry.
+func (h *TimeEntryHandler) Update(ctx context.Context, req *model.UpdateTimeEntryRequest) (*model.UpdateTimeEntryResponse, error) {
+	entry, err := h.svc.UpdateTimeEntry(ctx, req.TODOID, req.ID, req.StartedAt, req.StoppedAt, req.Note)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateTimeEntryResponse{TimeEntry: *entry}, nil
+}
+
+// Delete handles the endpoint that deletes the time entry.
+func (h *TimeEntryHandler) Delete(ctx context.Context, req *model.DeleteTimeEntryRequest) (*model.DeleteTimeEntryResponse, error) {
+	if err := h.svc.DeleteTimeEntry(ctx, req.TODOID, req.ID); err != nil {
+		return nil, err
+	}
+	return &model.DeleteTimeEntryResponse{}, nil
+}
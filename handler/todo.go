@@ -1,84 +1,323 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/TechBowl-japan/go-stations/model"
 	"github.com/TechBowl-japan/go-stations/service"
 )
 
+// fieldQueryPrefix is the prefix of the query parameters that filter TODOs by custom fields.
+const fieldQueryPrefix = "field."
+
 // A TODOHandler implements handling REST endpoints.
 type TODOHandler struct {
-	svc *service.TODOService
+	svc          *service.TODOService
+	cursorKey    []byte
+	subresources map[string]TODOSubresourceHandler
+}
+
+// A TODOSubresourceHandler handles requests to a subresource of a TODO such as /todos/{id}/comments.
+// pathは/todos/{id}/{name}/より後ろの部分で、/todos/{id}/{name}へのリクエストでは空文字になる.
+type TODOSubresourceHandler interface {
+	ServeTODO(w http.ResponseWriter, r *http.Request, todoID int64, path string)
 }
 
 // NewTODOHandler returns TODOHandler based http.Handler.
-func NewTODOHandler(svc *service.TODOService) *TODOHandler {
+// cursorKeyはページングのcursorの署名に使う.
+func NewTODOHandler(svc *service.TODOService, cursorKey []byte) *TODOHandler {
 	return &TODOHandler{
-		svc: svc,
+		svc:          svc,
+		cursorKey:    cursorKey,
+		subresources: make(map[string]TODOSubresourceHandler),
 	}
 }
 
+// Handle registers the handler for requests to /todos/{id}/{name} and the paths below it.
+func (h *TODOHandler) Handle(name string, sub TODOSubresourceHandler) {
+	h.subresources[name] = sub
+}
+
 // ServeHTTP handles HTTP requests and routes them to the appropriate method.
 func (h *TODOHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	switch r.Method {
-	case http.MethodPost:
-		var req model.CreateTODORequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+	switch r.URL.Path {
+	case "/todos":
+		h.serveCollection(w, r)
+	case "/todos/complete", "/todos/reopen":
+		h.serveCompletion(w, r)
+	case "/todos/search":
+		h.serveSearch(w, r)
+	case "/todos/order":
+		h.serveOrder(w, r)
+	default:
+		if id, ok := parseIDPath(r.URL.Path, "/todos/"); ok {
+			h.serveItem(w, r, id)
 			return
 		}
-		resp, err := h.Create(ctx, &req)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		id, sub, rest, ok := parseNestedPath(r.URL.Path, "/todos/")
+		if !ok {
+			http.NotFound(w, r)
 			return
 		}
-		json.NewEncoder(w).Encode(resp)
+		if handler, ok := h.subresources[sub]; ok {
+			handler.ServeTODO(w, r, id, rest)
+			return
+		}
+		if rest != "" {
+			http.NotFound(w, r)
+			return
+		}
+		switch sub {
+		case "tree":
+			h.serveTree(w, r, id)
+		case "occurrences":
+			h.serveOccurrences(w, r, id)
+		case "move":
+			h.serveMove(w, r, id)
+		case "history":
+			h.serveHistory(w, r, id)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
 
-	case http.MethodGet:
-		var req model.ReadTODORequest
-		// クエリパラメータの取得
-		query := r.URL.Query()
-		// rからクエリパラメータのprevIDとsizeを取得してreqにセット
-		// クエリパラメータがない場合は、reqのPrevIDとSizeに0をセット
-		if query.Get("prev_id") == "" {
-			req.PrevID = 0
-		} else {
-			prevID, err := strconv.Atoi(query.Get("prev_id"))
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			req.PrevID = int64(prevID)
+// maxPageSize is the largest size of a page.
+// 続きのページがあるかを判定するために1件多く読み込むので、その前に上限を確認する.
+const maxPageSize = 100
+
+// parsePaging parses the prev_id and size query parameters.
+// クエリパラメータがない場合は、prevIDに0、sizeにdefaultの5を返す.
+func parsePaging(query url.Values) (prevID, size int64, err error) {
+	if v := query.Get("prev_id"); v != "" {
+		if prevID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return 0, 0, err
 		}
+	}
+	if size, err = parsePageSize(query, 5); err != nil {
+		return 0, 0, err
+	}
+	return prevID, size, nil
+}
 
-		if query.Get("size") == "" {
-			// クエリパラメータにsizeがない場合は、defaultで5をセット
-			req.Size = 5
-		} else {
-			size, err := strconv.Atoi(query.Get("size"))
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
+// parsePageSize parses the size query parameter, or returns def if it is not given.
+// sizeは1以上maxPageSize以下でなければならない.
+func parsePageSize(query url.Values, def int64) (int64, error) {
+	v := query.Get("size")
+	if v == "" {
+		return def, nil
+	}
+	size, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || size < 1 || size > maxPageSize {
+		return 0, fmt.Errorf("size must be between 1 and %d", maxPageSize)
+	}
+	return size, nil
+}
+
+// parseReadFilter parses the sort, cursor and filter query parameters of the list endpoint.
+func (h *TODOHandler) parseReadFilter(query url.Values, req *model.ReadTODORequest) error {
+	// クエリパラメータにsortがない場合は、defaultでidの降順にする
+	req.Sort = model.TODOSort(query.Get("sort"))
+	if req.Sort == "" {
+		req.Sort = model.TODOSortIDDesc
+	}
+	if !req.Sort.Valid() {
+		return errors.New("invalid sort")
+	}
+
+	// cursorは作成時のソート順を含むので、sortと食い違う場合はエラーにする
+	if v := query.Get("cursor"); v != "" {
+		c, err := h.decodeCursor(v)
+		if err != nil {
+			return err
+		}
+		if query.Get("sort") != "" && c.Sort != req.Sort {
+			return errors.New("cursor does not match sort")
+		}
+		req.Cursor, req.Sort = c, c.Sort
+	}
+
+	req.SubjectPrefix = query.Get("subject_prefix")
+
+	if v := query.Get("priority"); v != "" {
+		req.Priority = model.TODOPriority(v)
+		if !req.Priority.Valid() {
+			return errors.New("invalid priority")
+		}
+	}
+
+	if v := query.Get("overdue"); v != "" {
+		overdue, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("overdue: %w", err)
+		}
+		req.Overdue = overdue
+	}
+
+	if v := query.Get("project_id"); v != "" {
+		projectID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("project_id: %w", err)
+		}
+		req.ProjectID = projectID
+	}
+
+	// タグはカンマ区切りのidで指定する
+	var err error
+	if req.TagsAny, err = parseIDList(query.Get("tags_any")); err != nil {
+		return fmt.Errorf("tags_any: %w", err)
+	}
+	if req.TagsAll, err = parseIDList(query.Get("tags_all")); err != nil {
+		return fmt.Errorf("tags_all: %w", err)
+	}
+	req.Fields = parseFieldFilters(query)
+
+	for _, p := range []struct {
+		name string
+		t    **time.Time
+	}{
+		{"created_after", &req.CreatedAfter},
+		{"created_before", &req.CreatedBefore},
+		{"updated_after", &req.UpdatedAfter},
+		{"updated_before", &req.UpdatedBefore},
+		{"due_before", &req.DueBefore},
+	} {
+		v := query.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.name, err)
+		}
+		*p.t = &t
+	}
+	return nil
+}
+
+// parseFieldFilters parses the query parameters that filter TODOs by custom fields.
+// field.{name}は値が一致するTODOに、field.{name}.minとfield.{name}.maxは値の範囲で絞り込む.
+func parseFieldFilters(query url.Values) []model.TODOFieldFilter {
+	keys := make([]string, 0)
+	for key := range query {
+		if strings.HasPrefix(key, fieldQueryPrefix) && len(key) > len(fieldQueryPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	// 絞り込みの順序を毎回同じにする
+	sort.Strings(keys)
+
+	var filters []model.TODOFieldFilter
+	for _, key := range keys {
+		name, op := strings.TrimPrefix(key, fieldQueryPrefix), model.TODOFieldEq
+		for _, o := range []model.TODOFieldOp{model.TODOFieldMin, model.TODOFieldMax} {
+			if trimmed := strings.TrimSuffix(name, "."+string(o)); trimmed != name && trimmed != "" {
+				name, op = trimmed, o
+				break
 			}
-			req.Size = int64(size)
 		}
+		for _, value := range query[key] {
+			filters = append(filters, model.TODOFieldFilter{Name: name, Op: op, Value: value})
+		}
+	}
+	return filters
+}
 
-		resp, err := h.Read(ctx, &req)
+// parseIDList parses a comma separated list of ids.
+func parseIDList(s string) ([]int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	fields := strings.Split(s, ",")
+	ids := make([]int64, len(fields))
+	for i, f := range fields {
+		id, err := strconv.ParseInt(strings.TrimSpace(f), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
-		json.NewEncoder(w).Encode(resp)
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// parseIDPath parses the id out of a path of the form prefix + {id}.
+func parseIDPath(path, prefix string) (int64, bool) {
+	rest := strings.TrimPrefix(path, prefix)
+	if rest == path || rest == "" || strings.Contains(rest, "/") {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// parseNestedPath parses the id, the subresource name and the rest out of a path of the form
+// prefix + {id}/{name} or prefix + {id}/{name}/{rest}.
+func parseNestedPath(path, prefix string) (id int64, name, rest string, ok bool) {
+	trimmed := strings.TrimPrefix(path, prefix)
+	parts := strings.SplitN(trimmed, "/", 3)
+	if trimmed == path || len(parts) < 2 || parts[1] == "" {
+		return 0, "", "", false
+	}
+	if id, ok = parseIDPath(prefix+parts[0], prefix); !ok {
+		return 0, "", "", false
+	}
+	if len(parts) == 3 {
+		if rest = parts[2]; rest == "" {
+			return 0, "", "", false
+		}
+	}
+	return id, parts[1], rest, true
+}
+
+// decodeTODOUpdate decodes the body of a PUT request that updates a TODO.
+// project_idを省略した場合は現在のプロジェクトのままで、nullの場合はPATCHと同じくInboxに戻す.
+func decodeTODOUpdate(r io.Reader, req *model.UpdateTODORequest) error {
+	var body json.RawMessage
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, req); err != nil {
+		return err
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return err
+	}
+	if value, ok := doc["project_id"]; ok && bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+		inbox := model.InboxProjectID
+		req.ProjectID = &inbox
+	}
+	return nil
+}
+
+// serveCollection handles requests to /todos.
+func (h *TODOHandler) serveCollection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodPost:
+		h.serveCreate(w, r, 0)
+
+	case http.MethodGet:
+		h.serveList(w, r, 0)
 
 	case http.MethodPut:
 		var req model.UpdateTODORequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if err := decodeTODOUpdate(r.Body, &req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -88,9 +327,8 @@ func (h *TODOHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		resp, err := h.Update(ctx, &req)
-		// ErrNotFound errorの場合は404を返す
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, err)
 			return
 		}
 		json.NewEncoder(w).Encode(resp)
@@ -123,11 +361,339 @@ func (h *TODOHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// serveCreate handles POST requests that create a TODO.
+// projectIDが0でない場合はそのプロジェクトにTODOを作成する.
+func (h *TODOHandler) serveCreate(w http.ResponseWriter, r *http.Request, projectID int64) {
+	var req model.CreateTODORequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if projectID != 0 {
+		// ボディのproject_idはパスのidと一致する場合のみ許可する
+		if req.ProjectID != 0 && req.ProjectID != projectID {
+			http.Error(w, "project_id mismatch", http.StatusBadRequest)
+			return
+		}
+		req.ProjectID = projectID
+	}
+	resp, err := h.Create(r.Context(), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveList handles GET requests that list TODOs.
+// projectIDが0でない場合はそのプロジェクトのTODOに絞り込む.
+func (h *TODOHandler) serveList(w http.ResponseWriter, r *http.Request, projectID int64) {
+	ctx := r.Context()
+	var req model.ReadTODORequest
+	// クエリパラメータの取得
+	query := r.URL.Query()
+	// rからクエリパラメータのprevIDとsizeを取得してreqにセット
+	var err error
+	if req.PrevID, req.Size, err = parsePaging(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// クエリパラメータにstatusがない場合は、defaultで全件を対象にする
+	req.Status = model.TODOStatus(query.Get("status"))
+	if req.Status == "" {
+		req.Status = model.TODOStatusAll
+	}
+	if !req.Status.Valid() {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	if err := h.parseReadFilter(query, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if projectID != 0 {
+		req.ProjectID = projectID
+	}
+
+	resp, err := h.Read(ctx, &req)
+	if err != nil {
+		// 存在しないカスタムフィールドの指定などはErrInvalidになる
+		writeError(w, err)
+		return
+	}
+	setPageLinks(w, r, resp)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveCompletion handles requests to /todos/complete and /todos/reopen.
+func (h *TODOHandler) serveCompletion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.URL.Path {
+	case "/todos/complete":
+		var req model.CompleteTODORequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// リクエストのidが0の場合はBadRequestを返す
+		if req.ID == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		resp, err = h.Complete(r.Context(), &req)
+
+	case "/todos/reopen":
+		var req model.ReopenTODORequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// リクエストのidが0の場合はBadRequestを返す
+		if req.ID == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		resp, err = h.Reopen(r.Context(), &req)
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveSearch handles requests to /todos/search.
+func (h *TODOHandler) serveSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	req := model.SearchTODORequest{Query: strings.TrimSpace(query.Get("q"))}
+	// 検索語が空の場合はBadRequestを返す
+	if req.Query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if req.PrevID, req.Size, err = parsePaging(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.Search(r.Context(), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveTree handles requests to /todos/{id}/tree.
+func (h *TODOHandler) serveTree(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := h.GetTree(r.Context(), &model.GetTODOTreeRequest{ID: id})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveOccurrences handles requests to /todos/{id}/occurrences.
+func (h *TODOHandler) serveOccurrences(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// sizeは既定で5件、最大100件とする
+	req := model.PreviewTODOOccurrencesRequest{ID: id}
+	var err error
+	if req.Size, err = parsePageSize(r.URL.Query(), 5); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.PreviewOccurrences(r.Context(), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveMove handles requests to /todos/{id}/move.
+func (h *TODOHandler) serveMove(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.MoveTODORequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.ID = id
+	resp, err := h.Move(r.Context(), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveOrder handles requests to /todos/order.
+func (h *TODOHandler) serveOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 並べるTODOはカンマ区切りのidで指定する
+	ids, err := parseIDList(r.URL.Query().Get("ids"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("ids: %s", err), http.StatusBadRequest)
+		return
+	}
+	resp, err := h.Order(r.Context(), &model.OrderTODORequest{IDs: ids})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveHistory handles requests to /todos/{id}/history.
+func (h *TODOHandler) serveHistory(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := model.ReadTODOHistoryRequest{ID: id}
+	var err error
+	if req.PrevID, req.Size, err = parsePaging(r.URL.Query()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := h.ReadHistory(r.Context(), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveItem handles requests to /todos/{id}.
+func (h *TODOHandler) serveItem(w http.ResponseWriter, r *http.Request, id int64) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		resp, err := h.Get(ctx, &model.GetTODORequest{ID: id})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		etag := todoETag(resp.Version)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Accept-Patch", mergePatchContentType)
+		// If-None-Matchに一致する場合は本文を返さない
+		if matchETag(strings.Join(r.Header.Values("If-None-Match"), ","), etag, true) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+
+	case http.MethodPut:
+		var req model.UpdateTODORequest
+		if err := decodeTODOUpdate(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// ボディのidはパスのidと一致する場合のみ許可する
+		if req.ID != 0 && req.ID != id {
+			http.Error(w, "id mismatch", http.StatusBadRequest)
+			return
+		}
+		req.ID = id
+		version, err := h.ifMatchVersion(ctx, r, id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		req.Version = version
+		resp, err := h.Update(ctx, &req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("ETag", todoETag(resp.Version))
+		json.NewEncoder(w).Encode(resp)
+
+	case http.MethodPatch:
+		if !isMergePatchContentType(r.Header.Get("Content-Type")) {
+			w.Header().Set("Accept-Patch", mergePatchContentType)
+			http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+			return
+		}
+		var req model.PatchTODORequest
+		if err := decodeTODOMergePatch(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.ID = id
+		version, err := h.ifMatchVersion(ctx, r, id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		req.Version = version
+		resp, err := h.Patch(ctx, &req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("ETag", todoETag(resp.Version))
+		json.NewEncoder(w).Encode(resp)
+
+	case http.MethodDelete:
+		version, err := h.ifMatchVersion(ctx, r, id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		resp, err := h.Delete(ctx, &model.DeleteTODORequest{IDs: []int64{id}, Version: version})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // Create handles the endpoint that creates the TODO.
 func (h *TODOHandler) Create(ctx context.Context, req *model.CreateTODORequest) (*model.CreateTODOResponse, error) {
-	// reqからSubjectとDescriptionを取得してsvc.CreateTODOに渡す
-	subject, description := req.Subject, req.Description
-	todo, err := h.svc.CreateTODO(ctx, subject, description)
+	todo, err := h.svc.CreateTODO(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -139,11 +705,25 @@ func (h *TODOHandler) Create(ctx context.Context, req *model.CreateTODORequest)
 
 // Read handles the endpoint that reads the TODOs.
 func (h *TODOHandler) Read(ctx context.Context, req *model.ReadTODORequest) (*model.ReadTODOResponse, error) {
-	todos, err := h.svc.ReadTODO(ctx, req.PrevID, req.Size)
+	// 続きのページがあるかを判定するため1件多く読み込む
+	sized := *req
+	sized.Size = req.Size + 1
+	todos, err := h.svc.ReadTODO(ctx, &sized)
 	if err != nil {
 		return nil, err
 	}
 
+	backward := req.Cursor != nil && req.Cursor.Backward
+	more := int64(len(todos)) > req.Size
+	if more {
+		// 余分に読んだ1件はcursorから最も遠い端にある
+		if backward {
+			todos = todos[1:]
+		} else {
+			todos = todos[:req.Size]
+		}
+	}
+
 	// []*model.TODO を []model.TODO に変換
 	todoList := make([]model.TODO, len(todos))
 	for i, todo := range todos {
@@ -152,26 +732,160 @@ func (h *TODOHandler) Read(ctx context.Context, req *model.ReadTODORequest) (*mo
 
 	var resp model.ReadTODOResponse
 	resp.TODOs = todoList
+	if len(todos) == 0 {
+		return &resp, nil
+	}
+
+	// 前のページから進んできた場合は次のページが、次のページから戻ってきた場合は前のページがある
+	hasNext, hasPrev := more, req.Cursor != nil || req.PrevID != 0
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		if resp.NextCursor, err = h.encodeCursor(cursorAt(todos[len(todos)-1], req.Sort, false)); err != nil {
+			return nil, err
+		}
+	}
+	if hasPrev {
+		if resp.PrevCursor, err = h.encodeCursor(cursorAt(todos[0], req.Sort, true)); err != nil {
+			return nil, err
+		}
+	}
+	return &resp, nil
+}
+
+// Search handles the endpoint that searches the TODOs.
+func (h *TODOHandler) Search(ctx context.Context, req *model.SearchTODORequest) (*model.SearchTODOResponse, error) {
+	results, err := h.svc.SearchTODO(ctx, req.Query, req.PrevID, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.SearchTODOResult を []model.SearchTODOResult に変換
+	resp := model.SearchTODOResponse{Results: make([]model.SearchTODOResult, len(results))}
+	for i, result := range results {
+		resp.Results[i] = *result
+	}
+	return &resp, nil
+}
+
+// Get handles the endpoint that reads the TODO.
+func (h *TODOHandler) Get(ctx context.Context, req *model.GetTODORequest) (*model.GetTODOResponse, error) {
+	todo, version, err := h.svc.GetVersionedTODO(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetTODOResponse{TODO: *todo, Version: version}, nil
+}
+
+// GetTree handles the endpoint that reads the TODO with its subtasks.
+func (h *TODOHandler) GetTree(ctx context.Context, req *model.GetTODOTreeRequest) (*model.GetTODOTreeResponse, error) {
+	tree, err := h.svc.GetTODOTree(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetTODOTreeResponse{Tree: *tree}, nil
+}
+
+// PreviewOccurrences handles the endpoint that lists the next occurrences of the recurring TODO.
+func (h *TODOHandler) PreviewOccurrences(ctx context.Context, req *model.PreviewTODOOccurrencesRequest) (*model.PreviewTODOOccurrencesResponse, error) {
+	occurrences, err := h.svc.PreviewTODOOccurrences(ctx, req.ID, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PreviewTODOOccurrencesResponse{Occurrences: occurrences}, nil
+}
+
+// ReadHistory handles the endpoint that reads the change history of the TODO.
+func (h *TODOHandler) ReadHistory(ctx context.Context, req *model.ReadTODOHistoryRequest) (*model.ReadTODOHistoryResponse, error) {
+	events, err := h.svc.ReadTODOHistory(ctx, req.ID, req.PrevID, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.TODOEvent を []model.TODOEvent に変換
+	resp := model.ReadTODOHistoryResponse{Events: make([]model.TODOEvent, len(events))}
+	for i, e := range events {
+		resp.Events[i] = *e
+	}
+	return &resp, nil
+}
+
+// Move handles the endpoint that moves the TODO between its new neighbours.
+func (h *TODOHandler) Move(ctx context.Context, req *model.MoveTODORequest) (*model.MoveTODOResponse, error) {
+	todo, err := h.svc.MoveTODO(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &model.MoveTODOResponse{TODO: *todo}, nil
+}
+
+// Order handles the endpoint that returns the TODOs in a topological order of their dependencies.
+func (h *TODOHandler) Order(ctx context.Context, req *model.OrderTODORequest) (*model.OrderTODOResponse, error) {
+	todos, err := h.svc.OrderTODO(ctx, req.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.TODO を []model.TODO に変換
+	resp := model.OrderTODOResponse{TODOs: make([]model.TODO, len(todos))}
+	for i, todo := range todos {
+		resp.TODOs[i] = *todo
+	}
 	return &resp, nil
 }
 
 // Update handles the endpoint that updates the TODO.
 func (h *TODOHandler) Update(ctx context.Context, req *model.UpdateTODORequest) (*model.UpdateTODOResponse, error) {
-	id, subject, description := req.ID, req.Subject, req.Description
-	todo, err := h.svc.UpdateTODO(ctx, id, subject, description)
+	// ETagのバージョンと本文が一致するように、更新と同じトランザクションで読んだバージョンを返す
+	todo, version, err := h.svc.UpdateVersionedTODO(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	var resp model.UpdateTODOResponse
 	resp.TODO = *todo
+	resp.Version = version
 
 	return &resp, nil
 }
 
+// Patch handles the endpoint that partially updates the TODO.
+func (h *TODOHandler) Patch(ctx context.Context, req *model.PatchTODORequest) (*model.PatchTODOResponse, error) {
+	todo, version, err := h.svc.PatchVersionedTODO(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PatchTODOResponse{TODO: *todo, Version: version}, nil
+}
+
+// Complete handles the endpoint that completes the TODO.
+func (h *TODOHandler) Complete(ctx context.Context, req *model.CompleteTODORequest) (*model.CompleteTODOResponse, error) {
+	todo, next, err := h.svc.CompleteTODO(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CompleteTODOResponse{TODO: *todo, Next: next}, nil
+}
+
+// Reopen handles the endpoint that reopens the completed TODO.
+func (h *TODOHandler) Reopen(ctx context.Context, req *model.ReopenTODORequest) (*model.ReopenTODOResponse, error) {
+	todo, err := h.svc.ReopenTODO(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ReopenTODOResponse{TODO: *todo}, nil
+}
+
 // Delete handles the endpoint that deletes the TODOs.
 func (h *TODOHandler) Delete(ctx context.Context, req *model.DeleteTODORequest) (*model.DeleteTODOResponse, error) {
 	ids := req.IDs
-	err := h.svc.DeleteTODO(ctx, ids)
+	var err error
+	// バージョンの指定がある場合は1件のみ条件付きで削除する
+	if req.Version != 0 && len(ids) == 1 {
+		err = h.svc.DeleteTODOIfMatch(ctx, ids[0], req.Version)
+	} else {
+		err = h.svc.DeleteTODO(ctx, ids)
+	}
 	if err != nil {
 		return nil, err
 	}
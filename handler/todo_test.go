@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseNestedPath(t *testing.T) {
+	t.Parallel()
+
+	type result struct {
+		ID   int64
+		Name string
+		Rest string
+		OK   bool
+	}
+
+	cases := map[string]struct {
+		path string
+		want result
+	}{
+		"Subresource":      {path: "/todos/3/comments", want: result{ID: 3, Name: "comments", OK: true}},
+		"Nested item":      {path: "/todos/3/comments/7", want: result{ID: 3, Name: "comments", Rest: "7", OK: true}},
+		"Deeply nested":    {path: "/todos/3/comments/7/x", want: result{ID: 3, Name: "comments", Rest: "7/x", OK: true}},
+		"Item":             {path: "/todos/3"},
+		"Trailing slash":   {path: "/todos/3/comments/"},
+		"Empty name":       {path: "/todos/3//7"},
+		"Invalid id":       {path: "/todos/x/comments"},
+		"Non-positive id":  {path: "/todos/0/comments"},
+		"Different prefix": {path: "/tags/3/comments"},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got result
+			got.ID, got.Name, got.Rest, got.OK = parseNestedPath(c.path, "/todos/")
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected value (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseFieldFilters(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		query string
+		want  []model.TODOFieldFilter
+	}{
+		"None":         {query: "status=open&sort=field.estimate"},
+		"Equal":        {query: "field.customer=ACME", want: []model.TODOFieldFilter{{Name: "customer", Op: model.TODOFieldEq, Value: "ACME"}}},
+		"Range":        {query: "field.estimate.max=5&field.estimate.min=2", want: []model.TODOFieldFilter{{Name: "estimate", Op: model.TODOFieldMax, Value: "5"}, {Name: "estimate", Op: model.TODOFieldMin, Value: "2"}}},
+		"Repeated":     {query: "field.size=S&field.size=M", want: []model.TODOFieldFilter{{Name: "size", Op: model.TODOFieldEq, Value: "S"}, {Name: "size", Op: model.TODOFieldEq, Value: "M"}}},
+		"Dotted name":  {query: "field.a.b=1", want: []model.TODOFieldFilter{{Name: "a.b", Op: model.TODOFieldEq, Value: "1"}}},
+		"Name is min":  {query: "field..min=1", want: []model.TODOFieldFilter{{Name: ".min", Op: model.TODOFieldEq, Value: "1"}}},
+		"Empty name":   {query: "field.=1"},
+		"Other prefix": {query: "fields.customer=ACME"},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := url.ParseQuery(c.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, parseFieldFilters(query)); diff != "" {
+				t.Errorf("unexpected value (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParsePaging(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		query  string
+		prevID int64
+		size   int64
+		err    bool
+	}{
+		"Default":      {query: "", size: 5},
+		"Given":        {query: "prev_id=3&size=10", prevID: 3, size: 10},
+		"Max":          {query: "size=100", size: 100},
+		"Zero":         {query: "size=0", err: true},
+		"Negative":     {query: "size=-1", err: true},
+		"Too large":    {query: "size=101", err: true},
+		"Not a number": {query: "size=x", err: true},
+		"Invalid prev": {query: "prev_id=x", err: true},
+		"Overflowing":  {query: "size=9223372036854775807", err: true},
+		"Empty":        {query: "size=", size: 5},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := url.ParseQuery(c.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			prevID, size, err := parsePaging(query)
+			if (err != nil) != c.err {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err == nil && (prevID != c.prevID || size != c.size) {
+				t.Errorf("unexpected paging, prev_id = %d, size = %d, want %d and %d", prevID, size, c.prevID, c.size)
+			}
+		})
+	}
+}
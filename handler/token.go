@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A TokenHandler implements handling REST endpoints of the API tokens of the authenticated user.
+type TokenHandler struct {
+	svc *service.TokenService
+}
+
+// NewTokenHandler returns TokenHandler based http.Handler.
+func NewTokenHandler(svc *service.TokenService) *TokenHandler {
+	return &TokenHandler{
+		svc: svc,
+	}
+}
+
+// ServeHTTP handles HTTP requests and routes them to the appropriate method.
+func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/tokens" {
+		h.serveCollection(w, r)
+		return
+	}
+	id, ok := parseIDPath(r.URL.Path, "/tokens/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.serveItem(w, r, id)
+}
+
+// serveCollection handles requests to /tokens.
+func (h *TokenHandler) serveCollection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = h.Read(ctx, &model.ReadAPITokenRequest{})
+
+	case http.MethodPost:
+		var req model.CreateAPITokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err = h.Create(ctx, &req)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveItem handles requests to /tokens/{id}.
+// トークンは削除せずに失効させ、一覧に残す.
+func (h *TokenHandler) serveItem(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := h.Revoke(r.Context(), &model.RevokeAPITokenRequest{ID: id})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Create handles the endpoint that creates the API token.
+func (h *TokenHandler) Create(ctx context.Context, req *model.CreateAPITokenRequest) (*model.CreateAPITokenResponse, error) {
+	token, secret, err := h.svc.CreateToken(ctx, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateAPITokenResponse{Token: *token, Secret: secret}, nil
+}
+
+// Read handles the endpoint that reads the API tokens.
+func (h *TokenHandler) Read(ctx context.Context, req *model.ReadAPITokenRequest) (*model.ReadAPITokenResponse, error) {
+	tokens, err := h.svc.ReadToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.APIToken を []model.APIToken に変換
+	resp := model.ReadAPITokenResponse{Tokens: make([]model.APIToken, len(tokens))}
+	for i, token := range tokens {
+		resp.Tokens[i] = *token
+	}
+	return &resp, nil
+}
+
+// Revoke handles the endpoint that revokes the API token.
+func (h *TokenHandler) Revoke(ctx context.Context, req *model.RevokeAPITokenRequest) (*model.RevokeAPITokenResponse, error) {
+	token, err := h.svc.RevokeToken(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.RevokeAPITokenResponse{Token: *token}, nil
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A TrashHandler implements handling REST endpoints of the trash of deleted TODOs.
+type TrashHandler struct {
+	svc *service.TrashService
+}
+
+// NewTrashHandler returns TrashHandler based http.Handler.
+func NewTrashHandler(svc *service.TrashService) *TrashHandler {
+	return &TrashHandler{
+		svc: svc,
+	}
+}
+
+// ServeHTTP handles HTTP requests and routes them to the appropriate method.
+func (h *TrashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/trash":
+		h.serveCollection(w, r)
+	case "/trash/restore":
+		h.serveRestore(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveCollection handles requests to /trash.
+func (h *TrashHandler) serveCollection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var (
+		resp interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		var req model.ReadTrashRequest
+		if req.PrevID, req.Size, err = parsePaging(r.URL.Query()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err = h.Read(ctx, &req)
+
+	case http.MethodDelete:
+		// ボディがない場合はゴミ箱を空にする
+		var req model.PurgeTrashRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err = h.Purge(ctx, &req)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveRestore handles requests to /trash/restore.
+func (h *TrashHandler) serveRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.RestoreTODORequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// リクエストのids(配列)が空の場合はBadRequestを返す
+	if len(req.IDs) == 0 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	resp, err := h.Restore(r.Context(), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Read handles the endpoint that reads the TODOs in the trash.
+func (h *TrashHandler) Read(ctx context.Context, req *model.ReadTrashRequest) (*model.ReadTrashResponse, error) {
+	todos, err := h.svc.ReadTrash(ctx, req.PrevID, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.TODO を []model.TODO に変換
+	resp := model.ReadTrashResponse{TODOs: make([]model.TODO, len(todos))}
+	for i, todo := range todos {
+		resp.TODOs[i] = *todo
+	}
+	return &resp, nil
+}
+
+// Restore handles the endpoint that restores the TODOs from the trash.
+func (h *TrashHandler) Restore(ctx context.Context, req *model.RestoreTODORequest) (*model.RestoreTODOResponse, error) {
+	todos, err := h.svc.RestoreTODO(ctx, req.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// []*model.TODO を []model.TODO に変換
+	resp := model.RestoreTODOResponse{TODOs: make([]model.TODO, len(todos))}
+	for i, todo := range todos {
+		resp.TODOs[i] = *todo
+	}
+	return &resp, nil
+}
+
+// Purge handles the endpoint that permanently deletes the TODOs in the trash.
+func (h *TrashHandler) Purge(ctx context.Context, req *model.PurgeTrashRequest) (*model.PurgeTrashResponse, error) {
+	if err := h.svc.PurgeTrash(ctx, req.IDs); err != nil {
+		return nil, err
+	}
+	return &model.PurgeTrashResponse{}, nil
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/common"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A UserHandler implements handling REST endpoints of user accounts.
+type UserHandler struct {
+	svc *service.UserService
+}
+
+// NewUserHandler returns UserHandler based http.Handler.
+func NewUserHandler(svc *service.UserService) *UserHandler {
+	return &UserHandler{
+		svc: svc,
+	}
+}
+
+// ServeHTTP handles HTTP requests and routes them to the appropriate method.
+// /users/meは認証したユーザー自身を表す.
+func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/users":
+		h.serveCollection(w, r)
+	case "/users/me":
+		h.serveMe(w, r)
+	case "/users/me/password":
+		h.servePassword(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveCollection handles requests to /users.
+// 管理者のユーザーが新しいユーザーを登録する.
+func (h *UserHandler) serveCollection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := h.Create(r.Context(), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveMe handles requests to /users/me.
+func (h *UserHandler) serveMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	resp, err := h.Get(ctx, &model.GetUserRequest{ID: common.GetUserID(ctx)})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// servePassword handles requests to /users/me/password.
+func (h *UserHandler) servePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	var req model.UpdatePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.ID = common.GetUserID(ctx)
+	resp, err := h.UpdatePassword(ctx, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Create handles the endpoint that creates the User.
+func (h *UserHandler) Create(ctx context.Context, req *model.CreateUserRequest) (*model.CreateUserResponse, error) {
+	user, err := h.svc.CreateUser(ctx, req.Name, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateUserResponse{User: *user}, nil
+}
+
+// Get handles the endpoint that reads the User.
+func (h *UserHandler) Get(ctx context.Context, req *model.GetUserRequest) (*model.GetUserResponse, error) {
+	user, err := h.svc.GetUser(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetUserResponse{User: *user}, nil
+}
+
+// UpdatePassword handles the endpoint that changes the password of the User.
+func (h *UserHandler) UpdatePassword(ctx context.Context, req *model.UpdatePasswordRequest) (*model.UpdatePasswordResponse, error) {
+	user, err := h.svc.UpdatePassword(ctx, req.ID, req.CurrentPassword, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdatePasswordResponse{User: *user}, nil
+}
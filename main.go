@@ -2,23 +2,50 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/TechBowl-japan/go-stations/db"
 	"github.com/TechBowl-japan/go-stations/handler/router"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
 	"golang.org/x/sync/errgroup"
 )
 
 // 環境変数から取得した値を使ってサーバーを起動する
 var (
-	// Basic Auth用のユーザー名とパスワードを環境変数から取得
+	// 起動時に登録する最初のユーザーの名前とパスワードを環境変数から取得
 	username = os.Getenv("BASIC_AUTH_USER_ID")
 	password = os.Getenv("BASIC_AUTH_PASSWORD")
+	// ページングのcursorの署名用の鍵を環境変数から取得
+	cursorSecret = os.Getenv("CURSOR_SECRET")
+	// 繰り返しのTODOなどを評価するタイムゾーンを環境変数から取得
+	timeZone = os.Getenv("TIME_ZONE")
+	// パスワードのハッシュを作成するbcryptのコストを環境変数から取得
+	passwordCost = os.Getenv("PASSWORD_COST")
+	// ゴミ箱のTODOを完全に削除するまでの日数を環境変数から取得
+	trashRetentionDays = os.Getenv("TRASH_RETENTION_DAYS")
+	// 添付ファイルの保存先と、ファイルごとおよびユーザーごとのサイズの上限(バイト)を環境変数から取得
+	attachmentDir     = os.Getenv("ATTACHMENT_DIR")
+	attachmentMaxSize = os.Getenv("ATTACHMENT_MAX_SIZE")
+	attachmentQuota   = os.Getenv("ATTACHMENT_QUOTA")
+	// OpenID ConnectのIdPのissuerと、IdPに登録したクライアントの情報を環境変数から取得.
+	// OIDC_ISSUERが設定されていない場合はOpenID Connectでログインできない
+	oidcIssuer        = os.Getenv("OIDC_ISSUER")
+	oidcClientID      = os.Getenv("OIDC_CLIENT_ID")
+	oidcClientSecret  = os.Getenv("OIDC_CLIENT_SECRET")
+	oidcRedirectURL   = os.Getenv("OIDC_REDIRECT_URL")
+	oidcScopes        = os.Getenv("OIDC_SCOPES")
+	oidcUsernameClaim = os.Getenv("OIDC_USERNAME_CLAIM")
 )
 
 func main() {
@@ -31,8 +58,22 @@ func main() {
 func realMain() error {
 	// config values
 	const (
-		defaultPort   = ":8080"
-		defaultDBPath = ".sqlite3/todo.db"
+		defaultPort     = ":8080"
+		defaultDBPath   = ".sqlite3/todo.db"
+		defaultTimeZone = "Asia/Tokyo"
+		// remind_atを過ぎたTODOを確認する間隔
+		reminderInterval = time.Minute
+		// ゴミ箱の期限切れのTODOを確認する間隔
+		trashPurgeInterval = time.Hour
+		// ゴミ箱のTODOを保持する日数
+		defaultTrashRetentionDays = 30
+		defaultAttachmentDir      = ".sqlite3/attachments"
+		defaultAttachmentMaxSize  = 10 << 20
+		defaultAttachmentQuota    = 100 << 20
+		// 参照されていない添付ファイルを確認する間隔
+		attachmentGCInterval = time.Hour
+		// アップロード中のファイルを削除しないように、参照されていなくても残す期間
+		attachmentGCGrace = time.Hour
 	)
 
 	port := os.Getenv("PORT")
@@ -45,9 +86,49 @@ func realMain() error {
 		dbPath = defaultDBPath
 	}
 
+	if timeZone == "" {
+		timeZone = defaultTimeZone
+	}
+
 	// set time zone
 	var err error
-	time.Local, err = time.LoadLocation("Asia/Tokyo")
+	time.Local, err = time.LoadLocation(timeZone)
+	if err != nil {
+		return err
+	}
+
+	retentionDays := defaultTrashRetentionDays
+	if trashRetentionDays != "" {
+		if retentionDays, err = strconv.Atoi(trashRetentionDays); err != nil {
+			return err
+		}
+		if retentionDays < 1 {
+			return fmt.Errorf("TRASH_RETENTION_DAYS must be positive, got %d", retentionDays)
+		}
+	}
+
+	if passwordCost != "" {
+		cost, err := strconv.Atoi(passwordCost)
+		if err != nil {
+			return fmt.Errorf("PASSWORD_COST: %w", err)
+		}
+		if err := service.SetPasswordCost(cost); err != nil {
+			return fmt.Errorf("PASSWORD_COST: %w", err)
+		}
+	}
+
+	if oidcIssuer != "" && (oidcClientID == "" || oidcRedirectURL == "") {
+		return errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER")
+	}
+
+	if attachmentDir == "" {
+		attachmentDir = defaultAttachmentDir
+	}
+	maxSize, err := parseSize("ATTACHMENT_MAX_SIZE", attachmentMaxSize, defaultAttachmentMaxSize)
+	if err != nil {
+		return err
+	}
+	quota, err := parseSize("ATTACHMENT_QUOTA", attachmentQuota, defaultAttachmentQuota)
 	if err != nil {
 		return err
 	}
@@ -59,8 +140,45 @@ func realMain() error {
 	}
 	defer todoDB.Close()
 
+	// cursorの署名用の鍵が設定されていない場合は起動ごとにランダムに生成する
+	// (再起動すると発行済みのcursorは使えなくなる)
+	cursorKey := []byte(cursorSecret)
+	if len(cursorKey) == 0 {
+		cursorKey = make([]byte, 32)
+		if _, err := rand.Read(cursorKey); err != nil {
+			return err
+		}
+	}
+
+	// 環境変数でユーザーが指定されている場合は、まだ登録されていなければ登録して管理者にする.
+	// 他のユーザーはそのユーザーでログインしてPOST /usersで登録する.
+	// 所有者のいないTODOはこのユーザーに引き継ぐ
+	if username != "" && password != "" {
+		users := service.NewUserService(todoDB)
+		user, err := users.EnsureAdmin(context.Background(), username, password)
+		if err != nil {
+			return err
+		}
+		adopted, err := users.AdoptTODOs(context.Background(), user.ID)
+		if err != nil {
+			return err
+		}
+		if adopted > 0 {
+			log.Printf("main: %d todos without an owner are adopted by %q", adopted, user.Name)
+		}
+	}
+
 	// NOTE: 新しいエンドポイントの登録はrouter.NewRouterの内部で行うようにする.
-	mux := router.NewRouter(todoDB, username, password)
+	attachments := service.NewAttachmentService(todoDB, attachmentDir, maxSize, quota)
+	oidc := service.NewOIDCService(todoDB, service.OIDCConfig{
+		Issuer:        oidcIssuer,
+		ClientID:      oidcClientID,
+		ClientSecret:  oidcClientSecret,
+		RedirectURL:   oidcRedirectURL,
+		Scopes:        strings.Fields(oidcScopes),
+		UsernameClaim: oidcUsernameClaim,
+	})
+	mux := router.NewRouter(todoDB, cursorKey, attachments, oidc)
 
 	// シグナルを受け取るためのコンテキストを作成
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt, os.Kill)
@@ -91,6 +209,23 @@ func realMain() error {
 		return nil
 	})
 
+	// remind_atを過ぎたTODOのリマインダーを発火するゴルーチンをerrgroupで実行
+	g.Go(func() error {
+		return service.NewReminderService(todoDB).Run(ctx, reminderInterval, func(e *model.ReminderEvent) {
+			log.Printf("reminder: todo %d %q, remind_at = %s", e.TODOID, e.Subject, e.RemindAt.Local().Format(time.RFC3339))
+		})
+	})
+
+	// ゴミ箱に保持期間を過ぎて残っているTODOを完全に削除するゴルーチンをerrgroupで実行
+	g.Go(func() error {
+		return service.NewTrashService(todoDB).Run(ctx, trashPurgeInterval, time.Duration(retentionDays)*24*time.Hour)
+	})
+
+	// どの添付ファイルからも参照されなくなったファイルを削除するゴルーチンをerrgroupで実行
+	g.Go(func() error {
+		return attachments.Run(ctx, attachmentGCInterval, attachmentGCGrace)
+	})
+
 	// メインの処理としてサーバーを起動し、正常に終了しない場合はエラーを返す
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return err
@@ -103,3 +238,19 @@ func realMain() error {
 
 	return nil
 }
+
+// parseSize parses the value of the environment variable name as a size in bytes.
+// 値が空の場合はdefを返し、0は制限しないことを表す.
+func parseSize(name, value string, def int64) (int64, error) {
+	if value == "" {
+		return def, nil
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("%s must not be negative, got %d", name, size)
+	}
+	return size, nil
+}
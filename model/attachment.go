@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+type (
+	// An Attachment expresses the metadata of a file attached to a TODO.
+	// 同じ内容のファイルは一つのblobを共有し、SHA256で識別する.
+	Attachment struct {
+		ID          int64     `json:"id"`
+		TODOID      int64     `json:"todo_id"`
+		Filename    string    `json:"filename"`
+		ContentType string    `json:"content_type"`
+		Size        int64     `json:"size"`
+		SHA256      string    `json:"sha256"`
+		Owner       string    `json:"owner"`
+		CreatedAt   time.Time `json:"created_at"`
+	}
+
+	// A CreateAttachmentResponse expresses ...
+	// リクエストはmultipart/form-dataのため、対応するRequestの型はない.
+	CreateAttachmentResponse struct {
+		Attachment Attachment `json:"attachment"`
+	}
+
+	// A ReadAttachmentRequest expresses ...
+	ReadAttachmentRequest struct {
+		TODOID int64 `json:"todo_id"`
+	}
+	// A ReadAttachmentResponse expresses ...
+	ReadAttachmentResponse struct {
+		Attachments []Attachment `json:"attachments"`
+	}
+
+	// A GetAttachmentRequest expresses ...
+	GetAttachmentRequest struct {
+		TODOID int64 `json:"todo_id"`
+		ID     int64 `json:"id"`
+	}
+	// A GetAttachmentResponse expresses ...
+	GetAttachmentResponse struct {
+		Attachment Attachment `json:"attachment"`
+	}
+
+	// A DeleteAttachmentRequest expresses ...
+	DeleteAttachmentRequest struct {
+		TODOID int64 `json:"todo_id"`
+		ID     int64 `json:"id"`
+	}
+	// A DeleteAttachmentResponse expresses ...
+	DeleteAttachmentResponse struct {
+	}
+)
@@ -0,0 +1,102 @@
+package model
+
+import "time"
+
+type (
+	// An AuthToken expresses the tokens issued by login and refresh.
+	// access_tokenは短命なJWTで、期限が切れたらrefresh_tokenで新しいトークンに交換する.
+	AuthToken struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// A SigningKey expresses a key that signs access tokens. 秘密鍵はレスポンスに含めない.
+	SigningKey struct {
+		KID       string     `json:"kid"`
+		CreatedAt time.Time  `json:"created_at"`
+		RetiredAt *time.Time `json:"retired_at"`
+	}
+
+	// A JWK expresses the public part of a SigningKey as a JSON Web Key.
+	JWK struct {
+		KTY string `json:"kty"`
+		CRV string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+		KID string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+	}
+
+	// A LoginRequest expresses ...
+	LoginRequest struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	// A LoginResponse expresses ...
+	LoginResponse struct {
+		AuthToken
+	}
+
+	// A RefreshRequest expresses ...
+	RefreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// A RefreshResponse expresses ...
+	RefreshResponse struct {
+		AuthToken
+	}
+
+	// A LogoutRequest expresses ...
+	LogoutRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// A LogoutResponse expresses ...
+	LogoutResponse struct{}
+
+	// A OIDCLoginRequest expresses ...
+	OIDCLoginRequest struct {
+	}
+	// A OIDCLoginResponse expresses ...
+	// StateはレスポンスのJSONではなくCookieでユーザーエージェントに渡す.
+	OIDCLoginResponse struct {
+		AuthorizationURL string `json:"authorization_url"`
+		State            string `json:"-"`
+	}
+
+	// A OIDCCallbackRequest expresses ...
+	OIDCCallbackRequest struct {
+		State string `json:"state"`
+		Code  string `json:"code"`
+	}
+	// A OIDCCallbackResponse expresses ...
+	OIDCCallbackResponse struct {
+		AuthToken
+	}
+
+	// A ReadJWKSRequest expresses ...
+	ReadJWKSRequest struct {
+	}
+	// A ReadJWKSResponse expresses ...
+	ReadJWKSResponse struct {
+		Keys []JWK `json:"keys"`
+	}
+
+	// A ReadSigningKeyRequest expresses ...
+	ReadSigningKeyRequest struct {
+	}
+	// A ReadSigningKeyResponse expresses ...
+	ReadSigningKeyResponse struct {
+		Keys []SigningKey `json:"keys"`
+	}
+
+	// A RotateSigningKeyRequest expresses ...
+	RotateSigningKeyRequest struct {
+	}
+	// A RotateSigningKeyResponse expresses ...
+	RotateSigningKeyResponse struct {
+		Key SigningKey `json:"key"`
+	}
+)
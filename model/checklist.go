@@ -0,0 +1,83 @@
+package model
+
+import "time"
+
+type (
+	// A ChecklistItem expresses a step of a TODO that is too small to be a subtask.
+	ChecklistItem struct {
+		ID        int64     `json:"id"`
+		TODOID    int64     `json:"todo_id"`
+		Body      string    `json:"body"`
+		Done      bool      `json:"done"`
+		Position  int64     `json:"position"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// A ChecklistProgress expresses the number of done items and all items in the checklist of a TODO.
+	ChecklistProgress struct {
+		Done  int64 `json:"done"`
+		Total int64 `json:"total"`
+	}
+
+	// A CreateChecklistItemRequest expresses ...
+	CreateChecklistItemRequest struct {
+		TODOID int64  `json:"todo_id"`
+		Body   string `json:"body"`
+	}
+	// A CreateChecklistItemResponse expresses ...
+	CreateChecklistItemResponse struct {
+		Item ChecklistItem `json:"item"`
+	}
+
+	// A ReadChecklistRequest expresses ...
+	ReadChecklistRequest struct {
+		TODOID int64 `json:"todo_id"`
+	}
+	// A ReadChecklistResponse expresses ...
+	ReadChecklistResponse struct {
+		Items []ChecklistItem `json:"items"`
+	}
+
+	// A UpdateChecklistItemRequest expresses ...
+	UpdateChecklistItemRequest struct {
+		TODOID int64  `json:"todo_id"`
+		ID     int64  `json:"id"`
+		Body   string `json:"body"`
+		Done   bool   `json:"done"`
+	}
+	// A UpdateChecklistItemResponse expresses ...
+	UpdateChecklistItemResponse struct {
+		Item ChecklistItem `json:"item"`
+	}
+
+	// A ToggleChecklistItemRequest expresses ...
+	ToggleChecklistItemRequest struct {
+		TODOID int64 `json:"todo_id"`
+		ID     int64 `json:"id"`
+	}
+	// A ToggleChecklistItemResponse expresses ...
+	ToggleChecklistItemResponse struct {
+		Item ChecklistItem `json:"item"`
+	}
+
+	// A ReorderChecklistRequest expresses ...
+	// IDsにはチェックリストのすべての項目を新しい順序で並べる.
+	ReorderChecklistRequest struct {
+		TODOID int64   `json:"todo_id"`
+		IDs    []int64 `json:"ids"`
+	}
+	// A ReorderChecklistResponse expresses ...
+	ReorderChecklistResponse struct {
+		Items []ChecklistItem `json:"items"`
+	}
+
+	// A DeleteChecklistItemRequest expresses ...
+	DeleteChecklistItemRequest struct {
+		TODOID int64 `json:"todo_id"`
+		ID     int64 `json:"id"`
+	}
+	// A DeleteChecklistItemResponse expresses ...
+	DeleteChecklistItemResponse struct {
+	}
+)
@@ -0,0 +1,73 @@
+package model
+
+import "time"
+
+type (
+	// A Comment expresses a comment on a TODO.
+	Comment struct {
+		ID        int64     `json:"id"`
+		TODOID    int64     `json:"todo_id"`
+		Author    string    `json:"author"`
+		Body      string    `json:"body"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// A CommentCursor expresses the position of the comment at the end of a page.
+	CommentCursor struct {
+		ID int64 `json:"i"`
+	}
+
+	// A CreateCommentRequest expresses ...
+	CreateCommentRequest struct {
+		TODOID int64  `json:"todo_id"`
+		Body   string `json:"body"`
+	}
+	// A CreateCommentResponse expresses ...
+	CreateCommentResponse struct {
+		Comment Comment `json:"comment"`
+	}
+
+	// A ReadCommentRequest expresses ...
+	ReadCommentRequest struct {
+		TODOID int64 `json:"todo_id"`
+		Size   int64 `json:"size"`
+		// Cursorがある場合はそのコメントの次から読み込む.
+		Cursor *CommentCursor `json:"-"`
+	}
+	// A ReadCommentResponse expresses ...
+	ReadCommentResponse struct {
+		Comments   []Comment `json:"comments"`
+		NextCursor string    `json:"next_cursor,omitempty"`
+	}
+
+	// A GetCommentRequest expresses ...
+	GetCommentRequest struct {
+		TODOID int64 `json:"todo_id"`
+		ID     int64 `json:"id"`
+	}
+	// A GetCommentResponse expresses ...
+	GetCommentResponse struct {
+		Comment Comment `json:"comment"`
+	}
+
+	// A UpdateCommentRequest expresses ...
+	UpdateCommentRequest struct {
+		TODOID int64  `json:"todo_id"`
+		ID     int64  `json:"id"`
+		Body   string `json:"body"`
+	}
+	// A UpdateCommentResponse expresses ...
+	UpdateCommentResponse struct {
+		Comment Comment `json:"comment"`
+	}
+
+	// A DeleteCommentRequest expresses ...
+	DeleteCommentRequest struct {
+		TODOID int64 `json:"todo_id"`
+		ID     int64 `json:"id"`
+	}
+	// A DeleteCommentResponse expresses ...
+	DeleteCommentResponse struct {
+	}
+)
@@ -0,0 +1,43 @@
+package model
+
+type (
+	// A ReadDependencyRequest expresses ...
+	ReadDependencyRequest struct {
+		TODOID int64 `json:"todo_id"`
+	}
+	// A ReadDependencyResponse expresses ...
+	// BlockedByはTODOが完了を待つTODO、BlocksはTODOの完了を待つTODO.
+	ReadDependencyResponse struct {
+		BlockedBy []TODO `json:"blocked_by"`
+		Blocks    []TODO `json:"blocks"`
+	}
+
+	// A CreateDependencyRequest expresses ...
+	CreateDependencyRequest struct {
+		TODOID      int64 `json:"todo_id"`
+		DependsOnID int64 `json:"depends_on_id"`
+	}
+	// A CreateDependencyResponse expresses ...
+	CreateDependencyResponse struct {
+		TODO TODO `json:"todo"`
+	}
+
+	// A DeleteDependencyRequest expresses ...
+	DeleteDependencyRequest struct {
+		TODOID      int64 `json:"todo_id"`
+		DependsOnID int64 `json:"depends_on_id"`
+	}
+	// A DeleteDependencyResponse expresses ...
+	DeleteDependencyResponse struct {
+		TODO TODO `json:"todo"`
+	}
+
+	// A OrderTODORequest expresses ...
+	OrderTODORequest struct {
+		IDs []int64 `json:"ids"`
+	}
+	// A OrderTODOResponse expresses ...
+	OrderTODOResponse struct {
+		TODOs []TODO `json:"todos"`
+	}
+)
@@ -7,3 +7,63 @@ type ErrNotFound struct {
 func (e *ErrNotFound) Error() string {
 	return "not found"
 }
+
+// ErrForbidden
+type ErrForbidden struct {
+}
+
+func (e *ErrForbidden) Error() string {
+	return "forbidden"
+}
+
+// ErrConflict
+type ErrConflict struct {
+	Reason string
+}
+
+func (e *ErrConflict) Error() string {
+	return e.Reason
+}
+
+// ErrPreconditionFailed
+type ErrPreconditionFailed struct {
+}
+
+func (e *ErrPreconditionFailed) Error() string {
+	return "precondition failed"
+}
+
+// ErrUnavailable
+type ErrUnavailable struct {
+	Feature string
+}
+
+func (e *ErrUnavailable) Error() string {
+	return e.Feature + " is not available"
+}
+
+// ErrTooLarge
+type ErrTooLarge struct {
+	Reason string
+}
+
+func (e *ErrTooLarge) Error() string {
+	return e.Reason
+}
+
+// ErrInvalid
+type ErrInvalid struct {
+	Reason string
+}
+
+func (e *ErrInvalid) Error() string {
+	return e.Reason
+}
+
+// ErrUnauthorized
+type ErrUnauthorized struct {
+}
+
+func (e *ErrUnauthorized) Error() string {
+	return "unauthorized"
+}
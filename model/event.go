@@ -0,0 +1,51 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// A TODOEventAction expresses the kind of change recorded in the history of a TODO.
+type TODOEventAction string
+
+const (
+	// TODOEventCreate is recorded when a TODO is created.
+	TODOEventCreate TODOEventAction = "create"
+	// TODOEventUpdate is recorded when the fields of a TODO are updated.
+	TODOEventUpdate TODOEventAction = "update"
+	// TODOEventComplete is recorded when a TODO is completed.
+	TODOEventComplete TODOEventAction = "complete"
+	// TODOEventReopen is recorded when a completed TODO is reopened.
+	TODOEventReopen TODOEventAction = "reopen"
+	// TODOEventMove is recorded when a TODO is moved in the manual order.
+	TODOEventMove TODOEventAction = "move"
+	// TODOEventDelete is recorded when a TODO is moved to the trash.
+	TODOEventDelete TODOEventAction = "delete"
+	// TODOEventRestore is recorded when a TODO is restored from the trash.
+	TODOEventRestore TODOEventAction = "restore"
+)
+
+type (
+	// A TODOEvent expresses a change of a TODO.
+	// BeforeとAfterは変更前後のTODOで、作成や復元の前と削除の後はnullになる.
+	TODOEvent struct {
+		ID        int64           `json:"id"`
+		TODOID    int64           `json:"todo_id"`
+		Action    TODOEventAction `json:"action"`
+		Actor     string          `json:"actor"`
+		Before    json.RawMessage `json:"before"`
+		After     json.RawMessage `json:"after"`
+		CreatedAt time.Time       `json:"created_at"`
+	}
+
+	// A ReadTODOHistoryRequest expresses ...
+	ReadTODOHistoryRequest struct {
+		ID     int64 `json:"id"`
+		PrevID int64 `json:"prev_id"`
+		Size   int64 `json:"size"`
+	}
+	// A ReadTODOHistoryResponse expresses ...
+	ReadTODOHistoryResponse struct {
+		Events []TODOEvent `json:"events"`
+	}
+)
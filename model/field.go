@@ -0,0 +1,112 @@
+package model
+
+import "time"
+
+// A CustomFieldType expresses the type of the values of a custom field.
+type CustomFieldType string
+
+const (
+	// CustomFieldText holds any string.
+	CustomFieldText CustomFieldType = "text"
+	// CustomFieldNumber holds a JSON number.
+	CustomFieldNumber CustomFieldType = "number"
+	// CustomFieldDate holds a date in the form of YYYY-MM-DD.
+	CustomFieldDate CustomFieldType = "date"
+	// CustomFieldEnum holds one of the options of the field.
+	CustomFieldEnum CustomFieldType = "enum"
+)
+
+// Valid reports whether t is a known CustomFieldType.
+func (t CustomFieldType) Valid() bool {
+	switch t {
+	case CustomFieldText, CustomFieldNumber, CustomFieldDate, CustomFieldEnum:
+		return true
+	}
+	return false
+}
+
+// A TODOFieldOp expresses how a TODOFieldFilter compares the values of a custom field.
+type TODOFieldOp string
+
+const (
+	// TODOFieldEq matches values equal to the given value.
+	TODOFieldEq TODOFieldOp = "eq"
+	// TODOFieldMin matches values greater than or equal to the given value.
+	TODOFieldMin TODOFieldOp = "min"
+	// TODOFieldMax matches values less than or equal to the given value.
+	TODOFieldMax TODOFieldOp = "max"
+)
+
+type (
+	// A CustomField expresses an extra field that the TODOs of a project can have.
+	// Optionsはenumの場合のみ使い、値はそのいずれかにする.
+	CustomField struct {
+		ID        int64           `json:"id"`
+		ProjectID int64           `json:"project_id"`
+		Name      string          `json:"name"`
+		Type      CustomFieldType `json:"type"`
+		Options   []string        `json:"options"`
+		CreatedAt time.Time       `json:"created_at"`
+		UpdatedAt time.Time       `json:"updated_at"`
+	}
+
+	// A TODOFieldFilter expresses a condition on the value of a custom field to filter TODOs.
+	TODOFieldFilter struct {
+		Name  string      `json:"name"`
+		Op    TODOFieldOp `json:"op"`
+		Value string      `json:"value"`
+	}
+
+	// A CreateCustomFieldRequest expresses ...
+	CreateCustomFieldRequest struct {
+		ProjectID int64           `json:"project_id"`
+		Name      string          `json:"name"`
+		Type      CustomFieldType `json:"type"`
+		Options   []string        `json:"options"`
+	}
+	// A CreateCustomFieldResponse expresses ...
+	CreateCustomFieldResponse struct {
+		Field CustomField `json:"field"`
+	}
+
+	// A ReadCustomFieldRequest expresses ...
+	ReadCustomFieldRequest struct {
+		ProjectID int64 `json:"project_id"`
+	}
+	// A ReadCustomFieldResponse expresses ...
+	ReadCustomFieldResponse struct {
+		Fields []CustomField `json:"fields"`
+	}
+
+	// A GetCustomFieldRequest expresses ...
+	GetCustomFieldRequest struct {
+		ProjectID int64 `json:"project_id"`
+		ID        int64 `json:"id"`
+	}
+	// A GetCustomFieldResponse expresses ...
+	GetCustomFieldResponse struct {
+		Field CustomField `json:"field"`
+	}
+
+	// A UpdateCustomFieldRequest expresses ...
+	// 既存の値が不正にならないように、型は変更できない.
+	UpdateCustomFieldRequest struct {
+		ProjectID int64    `json:"project_id"`
+		ID        int64    `json:"id"`
+		Name      string   `json:"name"`
+		Options   []string `json:"options"`
+	}
+	// A UpdateCustomFieldResponse expresses ...
+	UpdateCustomFieldResponse struct {
+		Field CustomField `json:"field"`
+	}
+
+	// A DeleteCustomFieldRequest expresses ...
+	DeleteCustomFieldRequest struct {
+		ProjectID int64 `json:"project_id"`
+		ID        int64 `json:"id"`
+	}
+	// A DeleteCustomFieldResponse expresses ...
+	DeleteCustomFieldResponse struct {
+	}
+)
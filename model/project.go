@@ -0,0 +1,72 @@
+package model
+
+import "time"
+
+// InboxProjectID is the id of the project that TODOs belong to unless another project is given.
+const InboxProjectID int64 = 1
+
+// A ProjectDeleteMode expresses what happens to the TODOs of a deleted project.
+type ProjectDeleteMode string
+
+const (
+	// ProjectDeleteCascade deletes the TODOs together with the project.
+	ProjectDeleteCascade ProjectDeleteMode = "cascade"
+	// ProjectDeleteMoveToInbox moves the TODOs to the inbox project.
+	ProjectDeleteMoveToInbox ProjectDeleteMode = "inbox"
+)
+
+type (
+	// A Project expresses a list that groups TODOs.
+	Project struct {
+		ID        int64     `json:"id"`
+		Name      string    `json:"name"`
+		Inbox     bool      `json:"inbox"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// A CreateProjectRequest expresses ...
+	CreateProjectRequest struct {
+		Name string `json:"name"`
+	}
+	// A CreateProjectResponse expresses ...
+	CreateProjectResponse struct {
+		Project Project `json:"project"`
+	}
+
+	// A ReadProjectRequest expresses ...
+	ReadProjectRequest struct {
+	}
+	// A ReadProjectResponse expresses ...
+	ReadProjectResponse struct {
+		Projects []Project `json:"projects"`
+	}
+
+	// A GetProjectRequest expresses ...
+	GetProjectRequest struct {
+		ID int64 `json:"id"`
+	}
+	// A GetProjectResponse expresses ...
+	GetProjectResponse struct {
+		Project Project `json:"project"`
+	}
+
+	// A UpdateProjectRequest expresses ...
+	UpdateProjectRequest struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	// A UpdateProjectResponse expresses ...
+	UpdateProjectResponse struct {
+		Project Project `json:"project"`
+	}
+
+	// A DeleteProjectRequest expresses ...
+	DeleteProjectRequest struct {
+		ID   int64             `json:"id"`
+		Mode ProjectDeleteMode `json:"todos"`
+	}
+	// A DeleteProjectResponse expresses ...
+	DeleteProjectResponse struct {
+	}
+)
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// A ReminderEvent expresses a reminder fired for a TODO when its remind_at passed.
+type ReminderEvent struct {
+	ID       int64     `json:"id"`
+	TODOID   int64     `json:"todo_id"`
+	Subject  string    `json:"subject"`
+	RemindAt time.Time `json:"remind_at"`
+	FiredAt  time.Time `json:"fired_at"`
+}
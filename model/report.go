@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+// A TimeReportPeriod is the length of the periods that a time report aggregates time entries by.
+type TimeReportPeriod string
+
+const (
+	TimeReportPeriodDay  TimeReportPeriod = "day"
+	TimeReportPeriodWeek TimeReportPeriod = "week"
+)
+
+// Valid reports whether p is a known period.
+func (p TimeReportPeriod) Valid() bool {
+	switch p {
+	case TimeReportPeriodDay, TimeReportPeriodWeek:
+		return true
+	}
+	return false
+}
+
+type (
+	// A TimeReportRequest expresses ...
+	// FromとToはサーバーのタイムゾーンの日付で、Toの日も含む.
+	TimeReportRequest struct {
+		From   time.Time        `json:"from"`
+		To     time.Time        `json:"to"`
+		Period TimeReportPeriod `json:"period"`
+		// Ownerが空の場合はすべてのユーザーの時間を集計する.
+		Owner string `json:"owner"`
+	}
+	// A TimeReportResponse expresses ...
+	TimeReportResponse struct {
+		TimeReport
+	}
+
+	// A TimeReport expresses time spent on TODOs aggregated by period and by tag.
+	// 複数のタグが付いたTODOの時間はそれぞれのタグに数えるため、タグごとの合計はTotalを超える場合がある.
+	TimeReport struct {
+		From     string           `json:"from"`
+		To       string           `json:"to"`
+		TimeZone string           `json:"time_zone"`
+		Period   TimeReportPeriod `json:"period"`
+		Total    int64            `json:"total_seconds"`
+		Periods  []TimeReportRow  `json:"periods"`
+		Tags     []TimeReportTag  `json:"tags"`
+	}
+
+	// A TimeReportRow expresses the time spent in a period starting at Start.
+	TimeReportRow struct {
+		Start   string          `json:"start"`
+		Seconds int64           `json:"seconds"`
+		Tags    []TimeReportTag `json:"tags"`
+	}
+
+	// A TimeReportTag expresses the time spent on TODOs with a tag.
+	// タグのないTODOの時間はTagがnilになる.
+	TimeReportTag struct {
+		Tag     *Tag  `json:"tag"`
+		Seconds int64 `json:"seconds"`
+	}
+)
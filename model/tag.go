@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+type (
+	// A Tag expresses a label attached to TODOs.
+	Tag struct {
+		ID        int64     `json:"id"`
+		Name      string    `json:"name"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// A CreateTagRequest expresses ...
+	CreateTagRequest struct {
+		Name string `json:"name"`
+	}
+	// A CreateTagResponse expresses ...
+	CreateTagResponse struct {
+		Tag Tag `json:"tag"`
+	}
+
+	// A ReadTagRequest expresses ...
+	ReadTagRequest struct {
+	}
+	// A ReadTagResponse expresses ...
+	ReadTagResponse struct {
+		Tags []Tag `json:"tags"`
+	}
+
+	// A GetTagRequest expresses ...
+	GetTagRequest struct {
+		ID int64 `json:"id"`
+	}
+	// A GetTagResponse expresses ...
+	GetTagResponse struct {
+		Tag Tag `json:"tag"`
+	}
+
+	// A UpdateTagRequest expresses ...
+	UpdateTagRequest struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	// A UpdateTagResponse expresses ...
+	UpdateTagResponse struct {
+		Tag Tag `json:"tag"`
+	}
+
+	// A DeleteTagRequest expresses ...
+	DeleteTagRequest struct {
+		ID int64 `json:"id"`
+	}
+	// A DeleteTagResponse expresses ...
+	DeleteTagResponse struct {
+	}
+)
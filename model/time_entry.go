@@ -0,0 +1,94 @@
+package model
+
+import "time"
+
+type (
+	// A TimeEntry expresses time spent on a TODO by a user.
+	// StoppedAtがnilの場合は計測中のタイマーで、Durationは現在までの時間になる.
+	TimeEntry struct {
+		ID        int64      `json:"id"`
+		TODOID    int64      `json:"todo_id"`
+		Owner     string     `json:"owner"`
+		StartedAt time.Time  `json:"started_at"`
+		StoppedAt *time.Time `json:"stopped_at"`
+		Duration  int64      `json:"duration_seconds"`
+		Note      string     `json:"note"`
+		CreatedAt time.Time  `json:"created_at"`
+		UpdatedAt time.Time  `json:"updated_at"`
+	}
+
+	// A StartTimerRequest expresses ...
+	StartTimerRequest struct {
+		TODOID int64  `json:"todo_id"`
+		Note   string `json:"note"`
+	}
+	// A StartTimerResponse expresses ...
+	StartTimerResponse struct {
+		TimeEntry TimeEntry `json:"time_entry"`
+	}
+
+	// A StopTimerRequest expresses ...
+	StopTimerRequest struct {
+		TODOID int64 `json:"todo_id"`
+	}
+	// A StopTimerResponse expresses ...
+	StopTimerResponse struct {
+		TimeEntry TimeEntry `json:"time_entry"`
+	}
+
+	// A CreateTimeEntryRequest expresses ...
+	CreateTimeEntryRequest struct {
+		TODOID    int64      `json:"todo_id"`
+		StartedAt time.Time  `json:"started_at"`
+		StoppedAt *time.Time `json:"stopped_at"`
+		Note      string     `json:"note"`
+	}
+	// A CreateTimeEntryResponse expresses ...
+	CreateTimeEntryResponse struct {
+		TimeEntry TimeEntry `json:"time_entry"`
+	}
+
+	// A ReadTimeEntryRequest expresses ...
+	ReadTimeEntryRequest struct {
+		TODOID int64 `json:"todo_id"`
+		PrevID int64 `json:"prev_id"`
+		Size   int64 `json:"size"`
+	}
+	// A ReadTimeEntryResponse expresses ...
+	ReadTimeEntryResponse struct {
+		TimeEntries []TimeEntry `json:"time_entries"`
+	}
+
+	// A GetTimeEntryRequest expresses ...
+	GetTimeEntryRequest struct {
+		TODOID int64 `json:"todo_id"`
+		ID     int64 `json:"id"`
+	}
+	// A GetTimeEntryResponse expresses ...
+	GetTimeEntryResponse struct {
+		TimeEntry TimeEntry `json:"time_entry"`
+	}
+
+	// A UpdateTimeEntryRequest expresses ...
+	// StoppedAtは計測中のタイマーの場合のみ省略できる.
+	UpdateTimeEntryRequest struct {
+		TODOID    int64      `json:"todo_id"`
+		ID        int64      `json:"id"`
+		StartedAt time.Time  `json:"started_at"`
+		StoppedAt *time.Time `json:"stopped_at"`
+		Note      string     `json:"note"`
+	}
+	// A UpdateTimeEntryResponse expresses ...
+	UpdateTimeEntryResponse struct {
+		TimeEntry TimeEntry `json:"time_entry"`
+	}
+
+	// A DeleteTimeEntryRequest expresses ...
+	DeleteTimeEntryRequest struct {
+		TODOID int64 `json:"todo_id"`
+		ID     int64 `json:"id"`
+	}
+	// A DeleteTimeEntryResponse expresses ...
+	DeleteTimeEntryResponse struct {
+	}
+)
@@ -1,21 +1,206 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// A TODOStatus expresses the completion state used to filter TODOs.
+type TODOStatus string
+
+const (
+	// TODOStatusAll matches every TODO.
+	TODOStatusAll TODOStatus = "all"
+	// TODOStatusOpen matches TODOs that are not completed yet.
+	TODOStatusOpen TODOStatus = "open"
+	// TODOStatusCompleted matches completed TODOs.
+	TODOStatusCompleted TODOStatus = "completed"
+)
+
+// Valid reports whether s is a known TODOStatus.
+func (s TODOStatus) Valid() bool {
+	switch s {
+	case TODOStatusAll, TODOStatusOpen, TODOStatusCompleted:
+		return true
+	}
+	return false
+}
+
+// A TODOSort expresses the order of listed TODOs.
+// 先頭に-が付いている場合は降順を表す.
+type TODOSort string
+
+const (
+	// TODOSortIDAsc orders TODOs by id ascending.
+	TODOSortIDAsc TODOSort = "id"
+	// TODOSortIDDesc orders TODOs by id descending. It is the default order.
+	TODOSortIDDesc TODOSort = "-id"
+	// TODOSortCreatedAtAsc orders TODOs by created_at ascending.
+	TODOSortCreatedAtAsc TODOSort = "created_at"
+	// TODOSortCreatedAtDesc orders TODOs by created_at descending.
+	TODOSortCreatedAtDesc TODOSort = "-created_at"
+	// TODOSortUpdatedAtAsc orders TODOs by updated_at ascending.
+	TODOSortUpdatedAtAsc TODOSort = "updated_at"
+	// TODOSortUpdatedAtDesc orders TODOs by updated_at descending.
+	TODOSortUpdatedAtDesc TODOSort = "-updated_at"
+	// TODOSortPriorityAsc orders TODOs by priority ascending.
+	TODOSortPriorityAsc TODOSort = "priority"
+	// TODOSortPriorityDesc orders TODOs by priority descending.
+	TODOSortPriorityDesc TODOSort = "-priority"
+	// TODOSortPositionAsc orders TODOs by the user-controlled position.
+	TODOSortPositionAsc TODOSort = "position"
+	// TODOSortPositionDesc orders TODOs by the user-controlled position in reverse.
+	TODOSortPositionDesc TODOSort = "-position"
+)
+
+// todoSortFieldPrefix is the prefix of TODOSorts that order TODOs by a custom field, such as "field.estimate".
+const todoSortFieldPrefix = "field."
+
+// Valid reports whether s is a known TODOSort.
+func (s TODOSort) Valid() bool {
+	switch s {
+	case TODOSortIDAsc, TODOSortIDDesc, TODOSortCreatedAtAsc, TODOSortCreatedAtDesc, TODOSortUpdatedAtAsc, TODOSortUpdatedAtDesc,
+		TODOSortPriorityAsc, TODOSortPriorityDesc, TODOSortPositionAsc, TODOSortPositionDesc:
+		return true
+	}
+	_, ok := s.Field()
+	return ok
+}
+
+// Field returns the name of the custom field to sort by, or false if s does not sort by a custom field.
+func (s TODOSort) Field() (string, bool) {
+	name := strings.TrimPrefix(s.Column(), todoSortFieldPrefix)
+	if name == s.Column() || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// Column returns the column name to sort by.
+func (s TODOSort) Column() string {
+	return strings.TrimPrefix(string(s), "-")
+}
+
+// Desc reports whether s is a descending order.
+func (s TODOSort) Desc() bool {
+	return strings.HasPrefix(string(s), "-")
+}
+
+// A TODOPriority expresses the priority of a TODO.
+// 空文字は優先度のないTODOを表し、JSONではnoneになる.
+type TODOPriority string
+
+const (
+	// TODOPriorityNone is the priority of a TODO without any priority.
+	TODOPriorityNone TODOPriority = "none"
+	// TODOPriorityLow is the lowest priority.
+	TODOPriorityLow TODOPriority = "low"
+	// TODOPriorityMedium is the middle priority.
+	TODOPriorityMedium TODOPriority = "medium"
+	// TODOPriorityHigh is the highest priority.
+	TODOPriorityHigh TODOPriority = "high"
+)
+
+// todoPriorities lists the TODOPriority values in ascending order of their levels.
+var todoPriorities = []TODOPriority{TODOPriorityNone, TODOPriorityLow, TODOPriorityMedium, TODOPriorityHigh}
+
+// Level returns the level stored in DB. 優先度が高いほど大きく、未知の値の場合はfalseを返す.
+func (p TODOPriority) Level() (int, bool) {
+	for level, priority := range todoPriorities {
+		if priority == p {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// Valid reports whether p is a known TODOPriority.
+func (p TODOPriority) Valid() bool {
+	_, ok := p.Level()
+	return ok
+}
+
+// TODOPriorityOf returns the TODOPriority stored as level. 優先度がない場合は空文字を返す.
+func TODOPriorityOf(level int) TODOPriority {
+	if level <= 0 || level >= len(todoPriorities) {
+		return ""
+	}
+	return todoPriorities[level]
+}
+
+// MarshalJSON encodes the empty TODOPriority as TODOPriorityNone.
+func (p TODOPriority) MarshalJSON() ([]byte, error) {
+	if p == "" {
+		p = TODOPriorityNone
+	}
+	return json.Marshal(string(p))
+}
+
+// TODOTags is the list of the Tags attached to a TODO.
+// nilの場合もJSONではnullではなく空の配列になる.
+type TODOTags []Tag
+
+// MarshalJSON encodes nil TODOTags as an empty array.
+func (t TODOTags) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Tag(t))
+}
+
+// TODOFields is the values of the custom fields of a TODO by the field name.
+// nilの場合もJSONではnullではなく空のオブジェクトになる.
+type TODOFields map[string]interface{}
+
+// MarshalJSON encodes nil TODOFields as an empty object.
+func (f TODOFields) MarshalJSON() ([]byte, error) {
+	if f == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(map[string]interface{}(f))
+}
 
 type (
 	// A TODO expresses ...
 	TODO struct {
-		ID          int64     `json:"id"`
-		Subject     string    `json:"subject"`
-		Description string    `json:"description"`
-		CreatedAt   time.Time `json:"created_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
+		ID          int64  `json:"id"`
+		OwnerID     int64  `json:"owner_id"`
+		Subject     string `json:"subject"`
+		Description string `json:"description"`
+		// ProjectIDはInboxのTODOではnilになる.
+		ProjectID   *int64            `json:"project_id"`
+		ParentID    *int64            `json:"parent_id"`
+		DueAt       *time.Time        `json:"due_at"`
+		RemindAt    *time.Time        `json:"remind_at"`
+		RRule       string            `json:"rrule"`
+		Priority    TODOPriority      `json:"priority"`
+		Position    float64           `json:"position"`
+		Completed   bool              `json:"completed"`
+		CompletedAt *time.Time        `json:"completed_at"`
+		Blocked     bool              `json:"blocked"`
+		Checklist   ChecklistProgress `json:"checklist"`
+		// Fieldsはプロジェクトのカスタムフィールドの名前をキーにした値で、値のないフィールドは含めない.
+		Fields    TODOFields `json:"fields"`
+		DeletedAt *time.Time `json:"deleted_at,omitempty"`
+		Tags      TODOTags   `json:"tags"`
+		CreatedAt time.Time  `json:"created_at"`
+		UpdatedAt time.Time  `json:"updated_at"`
 	}
 
 	// A CreateTODORequest expresses ...
 	CreateTODORequest struct {
-		Subject     string `json:"subject"`
-		Description string `json:"description"`
+		Subject     string       `json:"subject"`
+		Description string       `json:"description"`
+		ProjectID   int64        `json:"project_id"`
+		ParentID    int64        `json:"parent_id"`
+		DueAt       *time.Time   `json:"due_at"`
+		RemindAt    *time.Time   `json:"remind_at"`
+		RRule       string       `json:"rrule"`
+		Priority    TODOPriority `json:"priority"`
+		TagIDs      []int64      `json:"tag_ids"`
+		// Fieldsはカスタムフィールドの名前をキーにした値で、プロジェクトのフィールドの型に合わせる.
+		Fields map[string]interface{} `json:"fields"`
 	}
 	// A CreateTODOResponse expresses ...
 	CreateTODOResponse struct {
@@ -24,12 +209,90 @@ type (
 
 	// A ReadTODORequest expresses ...
 	ReadTODORequest struct {
-		PrevID int64 `json:"prev_id"`
-		Size   int64 `json:"size"`
+		PrevID        int64        `json:"prev_id"`
+		Size          int64        `json:"size"`
+		Status        TODOStatus   `json:"status"`
+		Sort          TODOSort     `json:"sort"`
+		ProjectID     int64        `json:"project_id"`
+		Priority      TODOPriority `json:"priority"`
+		DueBefore     *time.Time   `json:"due_before"`
+		Overdue       bool         `json:"overdue"`
+		SubjectPrefix string       `json:"subject_prefix"`
+		CreatedAfter  *time.Time   `json:"created_after"`
+		CreatedBefore *time.Time   `json:"created_before"`
+		UpdatedAfter  *time.Time   `json:"updated_after"`
+		UpdatedBefore *time.Time   `json:"updated_before"`
+		TagsAny       []int64      `json:"tags_any"`
+		TagsAll       []int64      `json:"tags_all"`
+		// カスタムフィールドでの絞り込みや並び替えにはProjectIDが必要になる.
+		Fields []TODOFieldFilter `json:"fields"`
+		// Cursorがある場合はそのTODOの次から読み込む.
+		Cursor *TODOCursor `json:"-"`
 	}
 	// A ReadTODOResponse expresses ...
 	ReadTODOResponse struct {
-		TODOs []TODO `json:"todos"`
+		TODOs      []TODO `json:"todos"`
+		NextCursor string `json:"next_cursor,omitempty"`
+		PrevCursor string `json:"prev_cursor,omitempty"`
+	}
+
+	// A TODOCursor expresses the position of the TODO at the edge of a page.
+	// Keyはソートに使った列の値で、同じ値のTODOはIDで並べる.
+	// 数値の列で並べる場合はKeyの代わりにNumを、文字列の列で並べる場合はStrを使う.
+	// カスタムフィールドで並べる場合に値がなければ、Key、NumとStrはすべてnilになる.
+	// Backwardがtrueの場合はそのTODOより前のページを表す.
+	TODOCursor struct {
+		Sort     TODOSort   `json:"s"`
+		Key      *time.Time `json:"k,omitempty"`
+		Num      *float64   `json:"n,omitempty"`
+		Str      *string    `json:"t,omitempty"`
+		ID       int64      `json:"i"`
+		Backward bool       `json:"b,omitempty"`
+	}
+
+	// A GetTODORequest expresses ...
+	GetTODORequest struct {
+		ID int64 `json:"id"`
+	}
+	// A GetTODOResponse expresses ...
+	GetTODOResponse struct {
+		TODO TODO `json:"todo"`
+		// VersionはTODOのバージョンで、本文には含めずETagヘッダーで返す.
+		Version int64 `json:"-"`
+	}
+
+	// A TODOTree expresses a TODO with its nested subtasks.
+	TODOTree struct {
+		TODO
+		Children []*TODOTree `json:"children"`
+	}
+
+	// A GetTODOTreeRequest expresses ...
+	GetTODOTreeRequest struct {
+		ID int64 `json:"id"`
+	}
+	// A GetTODOTreeResponse expresses ...
+	GetTODOTreeResponse struct {
+		Tree TODOTree `json:"tree"`
+	}
+
+	// A SearchTODORequest expresses ...
+	SearchTODORequest struct {
+		Query  string `json:"q"`
+		PrevID int64  `json:"prev_id"`
+		Size   int64  `json:"size"`
+	}
+	// A SearchTODOResult expresses a TODO matched by full-text search.
+	// SubjectHighlightとDescriptionSnippetは一致した語を<mark>で囲む.
+	SearchTODOResult struct {
+		TODO               TODO    `json:"todo"`
+		Rank               float64 `json:"rank"`
+		SubjectHighlight   string  `json:"subject_highlight"`
+		DescriptionSnippet string  `json:"description_snippet"`
+	}
+	// A SearchTODOResponse expresses ...
+	SearchTODOResponse struct {
+		Results []SearchTODOResult `json:"results"`
 	}
 
 	// A UpdateTODORequest expresses ...
@@ -37,15 +300,102 @@ type (
 		ID          int64  `json:"id"`
 		Subject     string `json:"subject"`
 		Description string `json:"description"`
+		// ProjectIDはnilの場合は現在のプロジェクトのまま残し、0の場合はInboxに移動する.
+		ProjectID *int64 `json:"project_id"`
+		// DueAt、RemindAt、RRuleとPriorityはnilの場合は現在の値のまま残す.
+		DueAt        *time.Time    `json:"due_at"`
+		RemindAt     *time.Time    `json:"remind_at"`
+		RRule        *string       `json:"rrule"`
+		Priority     *TODOPriority `json:"priority"`
+		AttachTagIDs []int64       `json:"attach_tag_ids"`
+		DetachTagIDs []int64       `json:"detach_tag_ids"`
+		// Fieldsに含まれるカスタムフィールドのみ更新し、nullの値は削除する.
+		Fields map[string]interface{} `json:"fields"`
+		// Versionが0でない場合はそのバージョンのTODOのみ更新する(If-Match).
+		Version int64 `json:"-"`
 	}
 	// A UpdateTODOResponse expresses ...
 	UpdateTODOResponse struct {
 		TODO TODO `json:"todo"`
+		// VersionはTODOのバージョンで、本文には含めずETagヘッダーで返す.
+		Version int64 `json:"-"`
+	}
+
+	// A PatchTODORequest expresses a JSON Merge Patch (RFC 7396) applied to a TODO.
+	// nilのフィールドは更新しない.
+	PatchTODORequest struct {
+		ID          int64   `json:"id"`
+		Subject     *string `json:"subject"`
+		Description *string `json:"description"`
+		ProjectID   *int64  `json:"project_id"`
+		// ParentIDが0を指す場合は親から外してトップレベルのTODOにする.
+		ParentID *int64 `json:"parent_id"`
+		// DueAtとRemindAtがゼロ値を指す場合はその日時を削除する.
+		DueAt    *time.Time `json:"due_at"`
+		RemindAt *time.Time `json:"remind_at"`
+		// RRuleが空文字を指す場合は繰り返しをやめる.
+		RRule    *string       `json:"rrule"`
+		Priority *TODOPriority `json:"priority"`
+		// Fieldsに含まれるカスタムフィールドのみ更新し、nullの値は削除する.
+		Fields map[string]interface{} `json:"fields"`
+		// Versionが0でない場合はそのバージョンのTODOのみ更新する(If-Match).
+		Version int64 `json:"-"`
+	}
+	// A PatchTODOResponse expresses ...
+	PatchTODOResponse struct {
+		TODO TODO `json:"todo"`
+		// VersionはTODOのバージョンで、本文には含めずETagヘッダーで返す.
+		Version int64 `json:"-"`
+	}
+
+	// A CompleteTODORequest expresses ...
+	CompleteTODORequest struct {
+		ID int64 `json:"id"`
+	}
+	// A CompleteTODOResponse expresses ...
+	// 繰り返しのTODOを完了した場合はNextに次の繰り返しが入る.
+	CompleteTODOResponse struct {
+		TODO TODO  `json:"todo"`
+		Next *TODO `json:"next,omitempty"`
+	}
+
+	// A MoveTODORequest expresses a drag-and-drop move of a TODO.
+	// AfterIDのTODOの直後、BeforeIDのTODOの直前に移動する. どちらか一方のみでもよい.
+	MoveTODORequest struct {
+		ID       int64 `json:"id"`
+		AfterID  int64 `json:"after_id"`
+		BeforeID int64 `json:"before_id"`
+	}
+	// A MoveTODOResponse expresses ...
+	MoveTODOResponse struct {
+		TODO TODO `json:"todo"`
+	}
+
+	// A PreviewTODOOccurrencesRequest expresses ...
+	PreviewTODOOccurrencesRequest struct {
+		ID   int64 `json:"id"`
+		Size int64 `json:"size"`
+	}
+	// A PreviewTODOOccurrencesResponse expresses ...
+	PreviewTODOOccurrencesResponse struct {
+		Occurrences []time.Time `json:"occurrences"`
+	}
+
+	// A ReopenTODORequest expresses ...
+	ReopenTODORequest struct {
+		ID int64 `json:"id"`
+	}
+	// A ReopenTODOResponse expresses ...
+	ReopenTODOResponse struct {
+		TODO TODO `json:"todo"`
 	}
 
 	// A DeleteTODORequest expresses ...
 	DeleteTODORequest struct {
 		IDs []int64 `json:"ids"`
+		// Versionが0でない場合はそのバージョンのTODOのみ削除する(If-Match).
+		// IDsが1件の場合のみ有効.
+		Version int64 `json:"-"`
 	}
 	// A DeleteTODOResponse expresses ...
 	DeleteTODOResponse struct {
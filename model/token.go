@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+// A TokenScope expresses what an API token is allowed to do.
+type TokenScope string
+
+const (
+	// TokenScopeTODOsRead allows reading TODOs and the resources around them.
+	TokenScopeTODOsRead TokenScope = "todos:read"
+	// TokenScopeTODOsWrite allows changing TODOs and the resources around them.
+	TokenScopeTODOsWrite TokenScope = "todos:write"
+	// TokenScopeAdmin allows everything including the management of users and API tokens.
+	TokenScopeAdmin TokenScope = "admin"
+)
+
+// Valid reports whether s is a known TokenScope.
+func (s TokenScope) Valid() bool {
+	switch s {
+	case TokenScopeTODOsRead, TokenScopeTODOsWrite, TokenScopeAdmin:
+		return true
+	}
+	return false
+}
+
+type (
+	// A APIToken expresses a personal token that authenticates scripts as its user.
+	// トークン自体は作成時のレスポンスでしか返さず、Prefixで見分ける.
+	APIToken struct {
+		ID         int64        `json:"id"`
+		Name       string       `json:"name"`
+		Prefix     string       `json:"prefix"`
+		Scopes     []TokenScope `json:"scopes"`
+		ExpiresAt  *time.Time   `json:"expires_at"`
+		LastUsedAt *time.Time   `json:"last_used_at"`
+		RevokedAt  *time.Time   `json:"revoked_at"`
+		CreatedAt  time.Time    `json:"created_at"`
+	}
+
+	// A CreateAPITokenRequest expresses ...
+	CreateAPITokenRequest struct {
+		Name      string       `json:"name"`
+		Scopes    []TokenScope `json:"scopes"`
+		ExpiresAt *time.Time   `json:"expires_at"`
+	}
+	// A CreateAPITokenResponse expresses ...
+	CreateAPITokenResponse struct {
+		Token  APIToken `json:"token"`
+		Secret string   `json:"secret"`
+	}
+
+	// A ReadAPITokenRequest expresses ...
+	ReadAPITokenRequest struct {
+	}
+	// A ReadAPITokenResponse expresses ...
+	ReadAPITokenResponse struct {
+		Tokens []APIToken `json:"tokens"`
+	}
+
+	// A RevokeAPITokenRequest expresses ...
+	RevokeAPITokenRequest struct {
+		ID int64 `json:"id"`
+	}
+	// A RevokeAPITokenResponse expresses ...
+	RevokeAPITokenResponse struct {
+		Token APIToken `json:"token"`
+	}
+)
@@ -0,0 +1,31 @@
+package model
+
+type (
+	// A ReadTrashRequest expresses ...
+	ReadTrashRequest struct {
+		PrevID int64 `json:"prev_id"`
+		Size   int64 `json:"size"`
+	}
+	// A ReadTrashResponse expresses ...
+	ReadTrashResponse struct {
+		TODOs []TODO `json:"todos"`
+	}
+
+	// A RestoreTODORequest expresses ...
+	RestoreTODORequest struct {
+		IDs []int64 `json:"ids"`
+	}
+	// A RestoreTODOResponse expresses ...
+	RestoreTODOResponse struct {
+		TODOs []TODO `json:"todos"`
+	}
+
+	// A PurgeTrashRequest expresses ...
+	// IDsが空の場合はゴミ箱のすべてのTODOを完全に削除する.
+	PurgeTrashRequest struct {
+		IDs []int64 `json:"ids"`
+	}
+	// A PurgeTrashResponse expresses ...
+	PurgeTrashResponse struct {
+	}
+)
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+type (
+	// A User expresses an account that owns TODOs.
+	// パスワードのハッシュはレスポンスに含めない. Adminのユーザーだけがユーザーの登録と署名鍵の管理を行える.
+	User struct {
+		ID        int64     `json:"id"`
+		Name      string    `json:"name"`
+		Admin     bool      `json:"admin"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// A CreateUserRequest expresses ...
+	CreateUserRequest struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	// A CreateUserResponse expresses ...
+	CreateUserResponse struct {
+		User User `json:"user"`
+	}
+
+	// A GetUserRequest expresses ...
+	GetUserRequest struct {
+		ID int64 `json:"id"`
+	}
+	// A GetUserResponse expresses ...
+	GetUserResponse struct {
+		User User `json:"user"`
+	}
+
+	// A UpdatePasswordRequest expresses ...
+	UpdatePasswordRequest struct {
+		ID              int64  `json:"id"`
+		CurrentPassword string `json:"current_password"`
+		Password        string `json:"password"`
+	}
+	// A UpdatePasswordResponse expresses ...
+	UpdatePasswordResponse struct {
+		User User `json:"user"`
+	}
+)
@@ -0,0 +1,458 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/common"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// attachmentColumns is the column list shared by every query that scans an Attachment with scanAttachment.
+const attachmentColumns = `id, todo_id, filename, content_type, size, sha256, owner, created_at`
+
+// uploadPrefix is the prefix of the temporary files that uploads are written to before they are hashed.
+const uploadPrefix = "upload-"
+
+// scanAttachment scans a row selected with attachmentColumns into an Attachment.
+func scanAttachment(row rowScanner) (*model.Attachment, error) {
+	var a model.Attachment
+	if err := row.Scan(&a.ID, &a.TODOID, &a.Filename, &a.ContentType, &a.Size, &a.SHA256, &a.Owner, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// An AttachmentService implements files attached to TODOs.
+// ファイルの内容はdirの下にSHA256をキーにして保存し、メタデータはDBに保存する.
+// ゴミ箱のTODOの添付ファイルは見つからないものとして扱う.
+type AttachmentService struct {
+	db      *sql.DB
+	dir     string
+	maxSize int64
+	quota   int64
+	// muはblobを保存してから参照を追加するまでと、ガベージコレクションで参照を確認してからblobを削除するまでを排他する
+	mu sync.Mutex
+}
+
+// NewAttachmentService returns new AttachmentService that stores the contents of files under dir.
+// maxSizeは一つのファイルのサイズの上限、quotaはユーザーごとの合計サイズの上限で、0の場合は制限しない.
+func NewAttachmentService(db *sql.DB, dir string, maxSize, quota int64) *AttachmentService {
+	return &AttachmentService{
+		db:      db,
+		dir:     dir,
+		maxSize: maxSize,
+		quota:   quota,
+	}
+}
+
+// MaxSize returns the largest size of a file that can be attached, or 0 if it is unlimited.
+func (s *AttachmentService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// CreateAttachment stores the content read from r and attaches it to the TODO on DB as filename.
+// Content-Typeはファイル名やクライアントの申告ではなく内容から判定する.
+// 添付したユーザーはcontextから取得する.
+func (s *AttachmentService) CreateAttachment(ctx context.Context, todoID int64, filename string, r io.Reader) (*model.Attachment, error) {
+	const insert = `INSERT INTO attachments(todo_id, owner_id, filename, content_type, size, sha256, owner) VALUES(?, ?, ?, ?, ?, ?, ?)`
+
+	if filename == "" {
+		return nil, &model.ErrInvalid{Reason: "filename is required"}
+	}
+	// 制限を超えることが明らかなファイルはディスクに書き込む前に拒否する
+	if err := checkTODO(ctx, s.db, todoID); err != nil {
+		return nil, err
+	}
+	limit, err := s.sizeLimit(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	upload, err := s.writeUpload(r, limit)
+	if err != nil {
+		return nil, err
+	}
+	// 保存先に移動した後はRemoveは失敗するだけなので、エラーは無視する
+	defer os.Remove(upload.path)
+
+	// 参照を追加する前のblobをガベージコレクションが削除しないように、保存から追加までロックする.
+	// 失敗した場合に保存済みのblobは、参照されていなければガベージコレクションで削除される
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.storeBlob(upload); err != nil {
+		return nil, err
+	}
+	sum, size, contentType := upload.sum, upload.size, upload.contentType
+
+	var attachment *model.Attachment
+	err = withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkTODO(ctx, tx, todoID); err != nil {
+			return err
+		}
+		// 同時にアップロードされたファイルで容量を超えないように、トランザクション内で確認し直す
+		limit, err := s.sizeLimit(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if size > limit.size {
+			return limit.err()
+		}
+
+		// execute insert query
+		result, err := tx.ExecContext(ctx, insert, todoID, ownerID(ctx), filename, contentType, size, sum, common.GetActor(ctx))
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// execute confirm query
+		attachment, err = getAttachment(ctx, tx, todoID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// An uploadLimit is the largest size of a file that a user can attach and the limit that determines it.
+type uploadLimit struct {
+	size  int64
+	quota bool
+	value int64
+}
+
+// err returns the error for a file larger than l.
+func (l uploadLimit) err() error {
+	if l.quota {
+		return &model.ErrTooLarge{Reason: fmt.Sprintf("attachments exceed the quota of %d bytes", l.value)}
+	}
+	return &model.ErrTooLarge{Reason: fmt.Sprintf("file size exceeds the limit of %d bytes", l.value)}
+}
+
+// sizeLimit returns the largest size of a file that the user in ctx can attach using q.
+// 表示名は他のユーザーと重なることがあるので、容量はユーザーのIDごとに数える.
+func (s *AttachmentService) sizeLimit(ctx context.Context, q queryer) (uploadLimit, error) {
+	const usage = `SELECT COALESCE(SUM(size), 0) FROM attachments WHERE owner_id IS ?`
+
+	limit := uploadLimit{size: math.MaxInt64}
+	if s.maxSize > 0 {
+		limit = uploadLimit{size: s.maxSize, value: s.maxSize}
+	}
+	if s.quota > 0 {
+		var used int64
+		if err := q.QueryRowContext(ctx, usage, ownerID(ctx)).Scan(&used); err != nil {
+			return uploadLimit{}, err
+		}
+		remaining := s.quota - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		if remaining < limit.size {
+			limit = uploadLimit{size: remaining, quota: true, value: s.quota}
+		}
+	}
+	return limit, nil
+}
+
+// An upload is the content of a file written to a temporary file before it is stored as a blob.
+type upload struct {
+	path        string
+	sum         string
+	size        int64
+	contentType string
+}
+
+// writeUpload writes the content read from r to a temporary file under the directory
+// and returns it with its SHA256, size and sniffed content type.
+// 内容がlimitより大きい場合はErrTooLargeを返す. 呼び出し元は一時ファイルを削除する必要がある.
+func (s *AttachmentService) writeUpload(r io.Reader, limit uploadLimit) (*upload, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(s.dir, uploadPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+	u, err := writeUploadTo(tmp, r, limit)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return u, nil
+}
+
+// writeUploadTo writes the content read from r to tmp. writeUploadの一時ファイルへの書き込みを行う.
+func writeUploadTo(tmp *os.File, r io.Reader, limit uploadLimit) (*upload, error) {
+	lr := &io.LimitedReader{R: r, N: limit.size}
+	h := sha256.New()
+	w := io.MultiWriter(tmp, h)
+
+	// Content-Typeの判定には先頭の512バイトまでを使う
+	head := make([]byte, 512)
+	n, err := io.ReadFull(lr, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+	if _, err := w.Write(head); err != nil {
+		return nil, err
+	}
+	m, err := io.Copy(w, lr)
+	if err != nil {
+		return nil, err
+	}
+	if lr.N == 0 {
+		// 上限ちょうどで終わっているかを確認するため、1バイト余分に読む
+		if k, _ := io.ReadFull(r, make([]byte, 1)); k > 0 {
+			return nil, limit.err()
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	return &upload{
+		path:        tmp.Name(),
+		sum:         hex.EncodeToString(h.Sum(nil)),
+		size:        int64(n) + m,
+		contentType: http.DetectContentType(head),
+	}, nil
+}
+
+// storeBlob moves the upload to the path of its blob unless the same content is already stored.
+// 呼び出し元はmuをロックし、参照を追加するまでロックしたままにする.
+func (s *AttachmentService) storeBlob(u *upload) error {
+	path := s.blobPath(u.sum)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		// 同じ内容のblobは再利用する
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(u.path, path)
+}
+
+// blobPath returns the path of the blob with the SHA256 sum.
+// 一つのディレクトリにファイルが集中しないように、先頭の2文字のディレクトリに分ける.
+func (s *AttachmentService) blobPath(sum string) string {
+	return filepath.Join(s.dir, sum[:2], sum)
+}
+
+// ReadAttachment reads the attachments of the TODO on DB in ascending order of id.
+func (s *AttachmentService) ReadAttachment(ctx context.Context, todoID int64) ([]*model.Attachment, error) {
+	const read = `SELECT ` + attachmentColumns + ` FROM attachments WHERE todo_id = ? ORDER BY id`
+
+	if err := checkTODO(ctx, s.db, todoID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, read, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make([]*model.Attachment, 0)
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// GetAttachment reads the attachment of the TODO on DB by id.
+func (s *AttachmentService) GetAttachment(ctx context.Context, todoID, id int64) (*model.Attachment, error) {
+	return getAttachment(ctx, s.db, todoID, id)
+}
+
+// OpenAttachment reads the attachment of the TODO on DB by id and opens its content.
+// 呼び出し元はファイルを閉じる必要がある.
+func (s *AttachmentService) OpenAttachment(ctx context.Context, todoID, id int64) (*model.Attachment, *os.File, error) {
+	attachment, err := getAttachment(ctx, s.db, todoID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(s.blobPath(attachment.SHA256))
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, f, nil
+}
+
+// getAttachment reads the attachment of the TODO by id using q.
+func getAttachment(ctx context.Context, q queryer, todoID, id int64) (*model.Attachment, error) {
+	const read = `SELECT ` + attachmentColumns + ` FROM attachments
+		WHERE id = ? AND todo_id = ? AND EXISTS(SELECT 1 FROM todos WHERE todos.id = attachments.todo_id AND owner_id IS ? AND deleted_at IS NULL)`
+
+	attachment, err := scanAttachment(q.QueryRowContext(ctx, read, id, todoID, ownerID(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	return attachment, err
+}
+
+// DeleteAttachment deletes the attachment on DB.
+// 添付したユーザー以外は削除できない. 内容のblobは参照がなくなった後にガベージコレクションで削除される.
+func (s *AttachmentService) DeleteAttachment(ctx context.Context, todoID, id int64) error {
+	const (
+		owned            = `SELECT owner_id IS ? FROM attachments WHERE id = ?`
+		deleteAttachment = `DELETE FROM attachments WHERE id = ?`
+	)
+
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if _, err := getAttachment(ctx, tx, todoID, id); err != nil {
+			return err
+		}
+		var ok bool
+		if err := tx.QueryRowContext(ctx, owned, ownerID(ctx), id).Scan(&ok); err != nil {
+			return err
+		}
+		if !ok {
+			return &model.ErrForbidden{}
+		}
+
+		// execute delete query
+		_, err := tx.ExecContext(ctx, deleteAttachment, id)
+		return err
+	})
+}
+
+// CollectGarbage deletes the blobs that no attachment refers to and the temporary files of failed uploads
+// last modified before the given time, and returns the number of deleted files.
+// アップロード中のファイルを削除しないように、最近書き込まれたファイルは参照がなくても残す.
+func (s *AttachmentService) CollectGarbage(ctx context.Context, before time.Time) (int, error) {
+	if _, err := os.Stat(s.dir); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	deleted := 0
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if os.IsNotExist(err) {
+			// 一覧を読んでから、アップロードが一時ファイルを移動した場合
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.ModTime().Before(before) {
+			return nil
+		}
+
+		if !strings.HasPrefix(d.Name(), uploadPrefix) {
+			// blob以外のファイルには触れない
+			if !isSHA256(d.Name()) || path != s.blobPath(d.Name()) {
+				return nil
+			}
+			removed, err := s.removeBlob(ctx, d.Name())
+			if removed {
+				deleted++
+			}
+			return err
+		}
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		deleted++
+		return nil
+	})
+	return deleted, err
+}
+
+// removeBlob deletes the blob with the SHA256 sum if no attachment refers to it, and reports whether it is deleted.
+// 確認してから削除するまでに同じ内容のファイルが添付されないように、storeBlobと同じmuでロックする.
+func (s *AttachmentService) removeBlob(ctx context.Context, sum string) (bool, error) {
+	const referenced = `SELECT EXISTS(SELECT 1 FROM attachments WHERE sha256 = ?)`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found bool
+	if err := s.db.QueryRowContext(ctx, referenced, sum).Scan(&found); err != nil {
+		return false, err
+	}
+	if found {
+		return false, nil
+	}
+	if err := os.Remove(s.blobPath(sum)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// isSHA256 reports whether s is a SHA256 sum in lowercase hex.
+func isSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Run deletes the unreferenced blobs older than grace every interval until ctx is done.
+// 起動時にも一度実行する.
+func (s *AttachmentService) Run(ctx context.Context, interval, grace time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.CollectGarbage(ctx, time.Now().Add(-grace))
+		switch {
+		case err != nil && ctx.Err() == nil:
+			// 一時的なエラーでサーバーを止めないように、ログに出して次の実行を待つ
+			log.Println("attachment: failed to collect garbage, err =", err)
+		case deleted > 0:
+			log.Printf("attachment: deleted %d unreferenced files", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
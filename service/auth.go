@@ -0,0 +1,426 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// accessTokenTTL is how long an access token issued by login or refresh is valid.
+	accessTokenTTL = 15 * time.Minute
+	// refreshTokenTTL is how long a refresh token is valid. 交換するたびに期限は延びる.
+	refreshTokenTTL = 30 * 24 * time.Hour
+	// refreshTokenPrefix is put at the head of every refresh token to tell it from API tokens.
+	refreshTokenPrefix = "rt_"
+	// accessTokenIssuer is the iss claim of access tokens.
+	accessTokenIssuer = "go-stations"
+	// signingKeyIDLength is the length of the kid of a signing key.
+	signingKeyIDLength = 16
+	// signingKeyColumns is the column list shared by every query that scans a SigningKey with scanSigningKey.
+	signingKeyColumns = `kid, created_at, retired_at`
+)
+
+// scanSigningKey scans a row selected with signingKeyColumns into a SigningKey.
+func scanSigningKey(row rowScanner, extra ...interface{}) (*model.SigningKey, error) {
+	var (
+		key       model.SigningKey
+		retiredAt sql.NullTime
+	)
+	dest := []interface{}{&key.KID, &key.CreatedAt, &retiredAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if retiredAt.Valid {
+		key.RetiredAt = &retiredAt.Time
+	}
+	return &key, nil
+}
+
+// An AuthService implements the login flow with JWT access tokens and rotating refresh tokens.
+// アクセストークンはsigning_keysの鍵で署名し、リフレッシュトークンは使うたびに新しいトークンに交換する.
+type AuthService struct {
+	db    *sql.DB
+	users *UserService
+}
+
+// NewAuthService returns new AuthService.
+func NewAuthService(db *sql.DB) *AuthService {
+	return &AuthService{
+		db:    db,
+		users: NewUserService(db),
+	}
+}
+
+// Login authenticates the user with the password and issues a new pair of tokens.
+func (s *AuthService) Login(ctx context.Context, name, password string) (*model.AuthToken, error) {
+	user, err := s.users.Authenticate(ctx, name, password)
+	if err != nil {
+		return nil, err
+	}
+	return s.IssueToken(ctx, user)
+}
+
+// IssueToken issues a new pair of tokens for the user authenticated by other means, such as OpenID Connect.
+// ログインごとに新しいリフレッシュトークンのfamilyを作る.
+func (s *AuthService) IssueToken(ctx context.Context, user *model.User) (*model.AuthToken, error) {
+	family, err := newSecret("")
+	if err != nil {
+		return nil, err
+	}
+
+	var token *model.AuthToken
+	err = withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var err error
+		token, err = issueAuthToken(ctx, tx, user, family)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Refresh exchanges the refresh token for a new pair of tokens.
+// 一度使われたリフレッシュトークンが再び使われた場合は漏洩したとみなし、同じfamilyのトークンをすべて失効させる.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*model.AuthToken, error) {
+	const (
+		read = `SELECT r.id, r.family_id, r.expires_at, r.used_at IS NOT NULL, r.revoked_at IS NOT NULL,
+			u.id, u.name, u.is_admin, u.created_at, u.updated_at FROM refresh_tokens r JOIN users u ON u.id = r.user_id WHERE r.token_hash = ?`
+		use          = `UPDATE refresh_tokens SET used_at = DATETIME('now') WHERE id = ? AND used_at IS NULL`
+		revokeFamily = `UPDATE refresh_tokens SET revoked_at = DATETIME('now') WHERE family_id = ? AND revoked_at IS NULL`
+	)
+
+	var (
+		token  *model.AuthToken
+		reused bool
+		user   model.User
+		family string
+	)
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var (
+			id            int64
+			expiresAt     time.Time
+			used, revoked bool
+		)
+		err := tx.QueryRowContext(ctx, read, hashSecret(refreshToken)).
+			Scan(&id, &family, &expiresAt, &used, &revoked, &user.ID, &user.Name, &user.Admin, &user.CreatedAt, &user.UpdatedAt)
+		if err == sql.ErrNoRows {
+			return &model.ErrUnauthorized{}
+		}
+		if err != nil {
+			return err
+		}
+		if revoked || !expiresAt.After(time.Now()) {
+			return &model.ErrUnauthorized{}
+		}
+
+		// 同時に交換された場合も、先に交換した方だけを有効にする
+		if !used {
+			result, err := tx.ExecContext(ctx, use, id)
+			if err != nil {
+				return err
+			}
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			used = affected == 0
+		}
+		if used {
+			// 失効を確定させるため、エラーを返さずにコミットする
+			reused = true
+			_, err := tx.ExecContext(ctx, revokeFamily, family)
+			return err
+		}
+
+		token, err = issueAuthToken(ctx, tx, &user, family)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		log.Printf("auth: refresh token reused, revoked the family of user %d", user.ID)
+		return nil, &model.ErrUnauthorized{}
+	}
+	return token, nil
+}
+
+// Logout revokes the refresh token and every token exchanged from the same login.
+// 存在しないトークンや失効済みのトークンでもエラーにしない.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	const revoke = `UPDATE refresh_tokens SET revoked_at = DATETIME('now')
+		WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = ?) AND revoked_at IS NULL`
+
+	_, err := s.db.ExecContext(ctx, revoke, hashSecret(refreshToken))
+	return err
+}
+
+// Authenticate returns the user of the access token, or ErrUnauthorized if the token is not signed
+// by a published signing key or has expired.
+func (s *AuthService) Authenticate(ctx context.Context, accessToken string) (*model.User, error) {
+	// 鍵の読み込みに失敗した場合は、不正なトークンではなくサーバーのエラーとして扱う
+	var keyErr error
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := s.verificationKey(ctx, kid)
+		if err != nil && !errors.As(err, new(*model.ErrUnauthorized)) {
+			keyErr = err
+		}
+		return key, err
+	}
+
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(accessToken, &claims, keyFunc, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	if err != nil || !claims.VerifyIssuer(accessTokenIssuer, true) {
+		return nil, &model.ErrUnauthorized{}
+	}
+
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, &model.ErrUnauthorized{}
+	}
+	user, err := s.users.GetUser(ctx, id)
+	if errors.As(err, new(*model.ErrNotFound)) {
+		return nil, &model.ErrUnauthorized{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// verificationKey returns the public key of the published signing key kid.
+func (s *AuthService) verificationKey(ctx context.Context, kid string) (*ecdsa.PublicKey, error) {
+	const read = `SELECT private_key FROM signing_keys WHERE kid = ? AND (retired_at IS NULL OR retired_at > ?)`
+
+	var der []byte
+	err := s.db.QueryRowContext(ctx, read, kid, sqliteTime(time.Now().Add(-accessTokenTTL))).Scan(&der)
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrUnauthorized{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	return &key.PublicKey, nil
+}
+
+// ReadJWKS reads the public keys that verify access tokens as JSON Web Keys.
+// 退役した鍵も、その鍵で署名したトークンが期限切れになるまでは公開する.
+func (s *AuthService) ReadJWKS(ctx context.Context) ([]*model.JWK, error) {
+	const read = `SELECT kid, private_key FROM signing_keys WHERE retired_at IS NULL OR retired_at > ? ORDER BY id DESC`
+
+	rows, err := s.db.QueryContext(ctx, read, sqliteTime(time.Now().Add(-accessTokenTTL)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*model.JWK, 0)
+	for rows.Next() {
+		var (
+			kid string
+			der []byte
+		)
+		if err := rows.Scan(&kid, &der); err != nil {
+			return nil, err
+		}
+		key, err := x509.ParseECPrivateKey(der)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &model.JWK{
+			KTY: "EC",
+			CRV: "P-256",
+			X:   encodeCoordinate(key.X.FillBytes(make([]byte, 32))),
+			Y:   encodeCoordinate(key.Y.FillBytes(make([]byte, 32))),
+			KID: kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodES256.Alg(),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ReadSigningKey reads all signing keys on DB in descending order of creation.
+func (s *AuthService) ReadSigningKey(ctx context.Context) ([]*model.SigningKey, error) {
+	const read = `SELECT ` + signingKeyColumns + ` FROM signing_keys ORDER BY id DESC`
+
+	rows, err := s.db.QueryContext(ctx, read)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*model.SigningKey, 0)
+	for rows.Next() {
+		key, err := scanSigningKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RotateSigningKey retires the current signing key and creates a new one that signs access tokens from now on.
+// 退役してからアクセストークンの有効期間を過ぎた鍵は削除する.
+func (s *AuthService) RotateSigningKey(ctx context.Context) (*model.SigningKey, error) {
+	const (
+		retire = `UPDATE signing_keys SET retired_at = DATETIME('now') WHERE retired_at IS NULL`
+		purge  = `DELETE FROM signing_keys WHERE retired_at <= ?`
+	)
+
+	var key *model.SigningKey
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, retire); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, purge, sqliteTime(time.Now().Add(-accessTokenTTL))); err != nil {
+			return err
+		}
+		var err error
+		key, _, err = createSigningKey(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// issueAuthToken signs a new access token for the user and creates a new refresh token in the family using q.
+func issueAuthToken(ctx context.Context, q queryer, user *model.User, family string) (*model.AuthToken, error) {
+	const insert = `INSERT INTO refresh_tokens(user_id, family_id, token_hash, expires_at) VALUES(?, ?, ?, ?)`
+
+	kid, key, err := currentSigningKey(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	jti, err := newSecret("")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{
+		Issuer:    accessTokenIssuer,
+		Subject:   strconv.FormatInt(user.ID, 10),
+		ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        jti[:32],
+	})
+	token.Header["kid"] = kid
+	accessToken, err := token.SignedString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := newSecret(refreshTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := q.ExecContext(ctx, insert, user.ID, family, hashSecret(refreshToken), sqliteTime(now.Add(refreshTokenTTL))); err != nil {
+		return nil, err
+	}
+
+	return &model.AuthToken{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenTTL / time.Second),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// currentSigningKey returns the kid and the private key that sign access tokens using q.
+// 鍵がまだない場合は作成する.
+func currentSigningKey(ctx context.Context, q queryer) (string, *ecdsa.PrivateKey, error) {
+	const read = `SELECT kid, private_key FROM signing_keys WHERE retired_at IS NULL ORDER BY id DESC LIMIT 1`
+
+	var (
+		kid string
+		der []byte
+	)
+	err := q.QueryRowContext(ctx, read).Scan(&kid, &der)
+	if err == sql.ErrNoRows {
+		key, private, err := createSigningKey(ctx, q)
+		if err != nil {
+			return "", nil, err
+		}
+		return key.KID, private, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	private, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		return "", nil, err
+	}
+	return kid, private, nil
+}
+
+// createSigningKey generates a new ECDSA P-256 key and stores it on DB using q.
+func createSigningKey(ctx context.Context, q queryer) (*model.SigningKey, *ecdsa.PrivateKey, error) {
+	const (
+		insert = `INSERT INTO signing_keys(kid, private_key) VALUES(?, ?)`
+		read   = `SELECT ` + signingKeyColumns + ` FROM signing_keys WHERE id = ?`
+	)
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(private)
+	if err != nil {
+		return nil, nil, err
+	}
+	kid, err := newSecret("")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := q.ExecContext(ctx, insert, kid[:signingKeyIDLength], der)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := scanSigningKey(q.QueryRowContext(ctx, read, id))
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, private, nil
+}
+
+// encodeCoordinate encodes a coordinate of a public key as a JWK member.
+func encodeCoordinate(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// checklistItemColumns is the column list shared by every query that scans a ChecklistItem with scanChecklistItem.
+const checklistItemColumns = `id, todo_id, body, done, position, created_at, updated_at`
+
+// scanChecklistItem scans a row selected with checklistItemColumns into a ChecklistItem.
+func scanChecklistItem(row rowScanner) (*model.ChecklistItem, error) {
+	var item model.ChecklistItem
+	if err := row.Scan(&item.ID, &item.TODOID, &item.Body, &item.Done, &item.Position, &item.CreatedAt, &item.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// A ChecklistService implements the checklists of TODOs.
+// ゴミ箱のTODOのチェックリストは見つからないものとして扱う.
+type ChecklistService struct {
+	db *sql.DB
+}
+
+// NewChecklistService returns new ChecklistService.
+func NewChecklistService(db *sql.DB) *ChecklistService {
+	return &ChecklistService{
+		db: db,
+	}
+}
+
+// CreateChecklistItem appends an item to the end of the checklist of the TODO on DB.
+func (s *ChecklistService) CreateChecklistItem(ctx context.Context, todoID int64, body string) (*model.ChecklistItem, error) {
+	const insert = `INSERT INTO checklist_items(todo_id, body, position)
+		VALUES(?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM checklist_items WHERE todo_id = ?))`
+
+	var item *model.ChecklistItem
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkTODO(ctx, tx, todoID); err != nil {
+			return err
+		}
+
+		// execute insert query
+		result, err := tx.ExecContext(ctx, insert, todoID, body, todoID)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// execute confirm query
+		item, err = getChecklistItem(ctx, tx, todoID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// ReadChecklist reads the checklist of the TODO on DB in order of position.
+func (s *ChecklistService) ReadChecklist(ctx context.Context, todoID int64) ([]*model.ChecklistItem, error) {
+	if err := checkTODO(ctx, s.db, todoID); err != nil {
+		return nil, err
+	}
+	return readChecklist(ctx, s.db, todoID)
+}
+
+// readChecklist reads the checklist of the TODO in order of position using q.
+func readChecklist(ctx context.Context, q queryer, todoID int64) ([]*model.ChecklistItem, error) {
+	const read = `SELECT ` + checklistItemColumns + ` FROM checklist_items WHERE todo_id = ? ORDER BY position, id`
+
+	rows, err := q.QueryContext(ctx, read, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*model.ChecklistItem, 0)
+	for rows.Next() {
+		item, err := scanChecklistItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// getChecklistItem reads the item in the checklist of the TODO by id using q.
+func getChecklistItem(ctx context.Context, q queryer, todoID, id int64) (*model.ChecklistItem, error) {
+	const read = `SELECT ` + checklistItemColumns + ` FROM checklist_items
+		WHERE id = ? AND todo_id = ? AND EXISTS(SELECT 1 FROM todos WHERE todos.id = checklist_items.todo_id AND owner_id IS ? AND deleted_at IS NULL)`
+
+	item, err := scanChecklistItem(q.QueryRowContext(ctx, read, id, todoID, ownerID(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	return item, err
+}
+
+// UpdateChecklistItem edits the body and the state of the item on DB.
+func (s *ChecklistService) UpdateChecklistItem(ctx context.Context, todoID, id int64, body string, done bool) (*model.ChecklistItem, error) {
+	const update = `UPDATE checklist_items SET body = ?, done = ? WHERE id = ?`
+
+	return s.updateChecklistItem(ctx, todoID, id, update, body, done, id)
+}
+
+// ToggleChecklistItem marks the item on DB done if it is not done, or not done otherwise.
+func (s *ChecklistService) ToggleChecklistItem(ctx context.Context, todoID, id int64) (*model.ChecklistItem, error) {
+	const toggle = `UPDATE checklist_items SET done = NOT done WHERE id = ?`
+
+	return s.updateChecklistItem(ctx, todoID, id, toggle, id)
+}
+
+// updateChecklistItem executes update with args on the item in the checklist of the TODO and returns the item.
+func (s *ChecklistService) updateChecklistItem(ctx context.Context, todoID, id int64, update string, args ...interface{}) (*model.ChecklistItem, error) {
+	var item *model.ChecklistItem
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if _, err := getChecklistItem(ctx, tx, todoID, id); err != nil {
+			return err
+		}
+
+		// execute update query
+		if _, err := tx.ExecContext(ctx, update, args...); err != nil {
+			return err
+		}
+
+		// execute confirm query
+		var err error
+		item, err = getChecklistItem(ctx, tx, todoID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// ReorderChecklist sorts the checklist of the TODO on DB in the order of ids.
+// idsにはチェックリストのすべての項目をちょうど一度ずつ含める必要がある.
+func (s *ChecklistService) ReorderChecklist(ctx context.Context, todoID int64, ids []int64) ([]*model.ChecklistItem, error) {
+	const move = `UPDATE checklist_items SET position = ? WHERE id = ?`
+
+	var items []*model.ChecklistItem
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkTODO(ctx, tx, todoID); err != nil {
+			return err
+		}
+		current, err := readChecklist(ctx, tx, todoID)
+		if err != nil {
+			return err
+		}
+
+		// 項目の漏れや重複があると順序が決まらないので、リクエストの内容が不正
+		if len(uniqueIDs(ids)) != len(ids) || len(ids) != len(current) {
+			return &model.ErrInvalid{Reason: "ids must contain every item of the checklist exactly once"}
+		}
+		known := make(map[int64]bool, len(current))
+		for _, item := range current {
+			known[item.ID] = true
+		}
+		for i, id := range ids {
+			if !known[id] {
+				return &model.ErrInvalid{Reason: fmt.Sprintf("item %d is not in the checklist", id)}
+			}
+			if _, err := tx.ExecContext(ctx, move, i+1, id); err != nil {
+				return err
+			}
+		}
+
+		items, err = readChecklist(ctx, tx, todoID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// DeleteChecklistItem removes the item from the checklist of the TODO on DB.
+func (s *ChecklistService) DeleteChecklistItem(ctx context.Context, todoID, id int64) error {
+	const deleteItem = `DELETE FROM checklist_items WHERE id = ?`
+
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if _, err := getChecklistItem(ctx, tx, todoID, id); err != nil {
+			return err
+		}
+
+		// execute delete query
+		_, err := tx.ExecContext(ctx, deleteItem, id)
+		return err
+	})
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/TechBowl-japan/go-stations/common"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// commentColumns is the column list shared by every query that scans a Comment with scanComment.
+const commentColumns = `id, todo_id, author, body, created_at, updated_at`
+
+// scanComment scans a row selected with commentColumns into a Comment.
+func scanComment(row rowScanner) (*model.Comment, error) {
+	var comment model.Comment
+	if err := row.Scan(&comment.ID, &comment.TODOID, &comment.Author, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// A CommentService implements CRUD of comments on TODOs.
+// ゴミ箱のTODOのコメントは見つからないものとして扱う.
+type CommentService struct {
+	db *sql.DB
+}
+
+// NewCommentService returns new CommentService.
+func NewCommentService(db *sql.DB) *CommentService {
+	return &CommentService{
+		db: db,
+	}
+}
+
+// CreateComment creates a comment on the TODO on DB.
+// コメントを書いたユーザーはcontextから取得する.
+func (s *CommentService) CreateComment(ctx context.Context, todoID int64, body string) (*model.Comment, error) {
+	const insert = `INSERT INTO comments(todo_id, author, body) VALUES(?, ?, ?)`
+
+	var comment *model.Comment
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkTODO(ctx, tx, todoID); err != nil {
+			return err
+		}
+
+		// execute insert query
+		result, err := tx.ExecContext(ctx, insert, todoID, common.GetActor(ctx), body)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// execute confirm query
+		comment, err = getComment(ctx, tx, todoID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// ReadComment reads at most size comments on the TODO on DB after afterID in ascending order of id.
+func (s *CommentService) ReadComment(ctx context.Context, todoID, afterID, size int64) ([]*model.Comment, error) {
+	const read = `SELECT ` + commentColumns + ` FROM comments WHERE todo_id = ? AND id > ? ORDER BY id LIMIT ?`
+
+	if err := checkTODO(ctx, s.db, todoID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, read, todoID, afterID, size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make([]*model.Comment, 0)
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// GetComment reads the comment on the TODO on DB by id.
+func (s *CommentService) GetComment(ctx context.Context, todoID, id int64) (*model.Comment, error) {
+	return getComment(ctx, s.db, todoID, id)
+}
+
+// getComment reads the comment on the TODO by id using q.
+func getComment(ctx context.Context, q queryer, todoID, id int64) (*model.Comment, error) {
+	const read = `SELECT ` + commentColumns + ` FROM comments
+		WHERE id = ? AND todo_id = ? AND EXISTS(SELECT 1 FROM todos WHERE todos.id = comments.todo_id AND owner_id IS ? AND deleted_at IS NULL)`
+
+	comment, err := scanComment(q.QueryRowContext(ctx, read, id, todoID, ownerID(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	return comment, err
+}
+
+// UpdateComment edits the body of the comment on DB.
+// コメントを書いたユーザー以外は編集できない.
+func (s *CommentService) UpdateComment(ctx context.Context, todoID, id int64, body string) (*model.Comment, error) {
+	const update = `UPDATE comments SET body = ? WHERE id = ?`
+
+	var comment *model.Comment
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkAuthor(ctx, tx, todoID, id); err != nil {
+			return err
+		}
+
+		// execute update query
+		if _, err := tx.ExecContext(ctx, update, body, id); err != nil {
+			return err
+		}
+
+		// execute confirm query
+		var err error
+		comment, err = getComment(ctx, tx, todoID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// DeleteComment deletes the comment on DB.
+// コメントを書いたユーザー以外は削除できない.
+func (s *CommentService) DeleteComment(ctx context.Context, todoID, id int64) error {
+	const deleteComment = `DELETE FROM comments WHERE id = ?`
+
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkAuthor(ctx, tx, todoID, id); err != nil {
+			return err
+		}
+
+		// execute delete query
+		_, err := tx.ExecContext(ctx, deleteComment, id)
+		return err
+	})
+}
+
+// checkAuthor returns ErrNotFound if the comment does not exist,
+// or ErrForbidden if the user in ctx is not its author.
+func checkAuthor(ctx context.Context, q queryer, todoID, id int64) error {
+	comment, err := getComment(ctx, q, todoID, id)
+	if err != nil {
+		return err
+	}
+	if comment.Author != common.GetActor(ctx) {
+		return &model.ErrForbidden{}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/common"
+)
+
+// A queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// withTx runs fn in a transaction and commits it if fn returns nil.
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// inPlaceholders returns the placeholders and the arguments for an IN clause of ids.
+func inPlaceholders(ids []int64) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return "?" + strings.Repeat(",?", len(ids)-1), args
+}
+
+// ownerID returns the owner_id of the rows that the user of ctx can access. 比較にはIS ?を使う.
+// ユーザーのいないcontextでは所有者のいない(owner_idがNULLの)行を扱う.
+func ownerID(ctx context.Context) interface{} {
+	if id := common.GetUserID(ctx); id != 0 {
+		return id
+	}
+	return nil
+}
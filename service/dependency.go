@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// ReadDependency reads the TODOs that the TODO on DB is blocked by and the TODOs that it blocks
+// in the order of position. ゴミ箱のTODOは含めない.
+func (s *TODOService) ReadDependency(ctx context.Context, id int64) (blockedBy, blocks []*model.TODO, err error) {
+	const (
+		readBlockedBy = `SELECT ` + todoColumns + ` FROM todos WHERE deleted_at IS NULL
+			AND id IN (SELECT depends_on_id FROM todo_dependencies WHERE todo_id = ?) ORDER BY position, id`
+		readBlocks = `SELECT ` + todoColumns + ` FROM todos WHERE deleted_at IS NULL
+			AND id IN (SELECT todo_id FROM todo_dependencies WHERE depends_on_id = ?) ORDER BY position, id`
+	)
+
+	if err := checkTODO(ctx, s.db, id); err != nil {
+		return nil, nil, err
+	}
+	if blockedBy, err = queryTODOs(ctx, s.db, readBlockedBy, id); err != nil {
+		return nil, nil, err
+	}
+	if blocks, err = queryTODOs(ctx, s.db, readBlocks, id); err != nil {
+		return nil, nil, err
+	}
+	return blockedBy, blocks, nil
+}
+
+// CreateDependency makes the TODO on DB wait for the completion of the TODO dependsOnID.
+// 依存関係が循環する場合はErrInvalidを返す.
+func (s *TODOService) CreateDependency(ctx context.Context, id, dependsOnID int64) (*model.TODO, error) {
+	const (
+		// dependsOnIDから依存先をたどってidに戻る場合は循環する
+		cycle = `WITH RECURSIVE deps(id) AS (
+			SELECT ?
+			UNION
+			SELECT d.depends_on_id FROM todo_dependencies d JOIN deps ON d.todo_id = deps.id
+		) SELECT EXISTS(SELECT 1 FROM deps WHERE id = ?)`
+		insert = `INSERT INTO todo_dependencies(todo_id, depends_on_id) VALUES(?, ?)`
+	)
+
+	if id == dependsOnID {
+		return nil, &model.ErrInvalid{Reason: "a TODO cannot depend on itself"}
+	}
+
+	var todo *model.TODO
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkTODO(ctx, tx, id); err != nil {
+			return err
+		}
+		if err := checkTODO(ctx, tx, dependsOnID); err != nil {
+			// 依存先に指定したTODOが存在しない場合はリクエストの内容が不正
+			var notFound *model.ErrNotFound
+			if errors.As(err, &notFound) {
+				return &model.ErrInvalid{Reason: "depends_on_id not found"}
+			}
+			return err
+		}
+
+		var cyclic bool
+		if err := tx.QueryRowContext(ctx, cycle, dependsOnID, id).Scan(&cyclic); err != nil {
+			return err
+		}
+		if cyclic {
+			return &model.ErrInvalid{Reason: "the dependency would create a cycle"}
+		}
+
+		// 同じ依存関係がすでにある場合はUNIQUE制約に違反する
+		if _, err := tx.ExecContext(ctx, insert, id, dependsOnID); err != nil {
+			return err
+		}
+
+		var err error
+		todo, err = getTODO(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// DeleteDependency removes the dependency of the TODO on DB on the TODO dependsOnID.
+func (s *TODOService) DeleteDependency(ctx context.Context, id, dependsOnID int64) (*model.TODO, error) {
+	const deleteDependency = `DELETE FROM todo_dependencies WHERE todo_id = ? AND depends_on_id = ?`
+
+	var todo *model.TODO
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkTODO(ctx, tx, id); err != nil {
+			return err
+		}
+
+		// execute delete query
+		result, err := tx.ExecContext(ctx, deleteDependency, id, dependsOnID)
+		if err != nil {
+			return err
+		}
+		// rows affected is 0, return ErrNotFound
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return &model.ErrNotFound{}
+		}
+
+		todo, err = getTODO(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// OrderTODO returns the TODOs on DB by ids in a topological order, where every TODO comes after
+// the TODOs it depends on. 集合の外のTODOを経由する間接的な依存関係も考慮する.
+// 順序が決まらないTODOどうしはpositionの順に並べる.
+func (s *TODOService) OrderTODO(ctx context.Context, ids []int64) ([]*model.TODO, error) {
+	const (
+		readFmt  = `SELECT ` + todoColumns + ` FROM todos WHERE owner_id IS ? AND deleted_at IS NULL AND id IN (%s)`
+		reachFmt = `WITH RECURSIVE reach(start, id) AS (
+			SELECT todo_id, depends_on_id FROM todo_dependencies WHERE todo_id IN (%[1]s)
+			UNION
+			SELECT reach.start, d.depends_on_id FROM todo_dependencies d JOIN reach ON d.todo_id = reach.id
+		) SELECT start, id FROM reach WHERE id IN (%[1]s)`
+	)
+
+	ids = uniqueIDs(ids)
+	if len(ids) == 0 {
+		return nil, &model.ErrInvalid{Reason: "ids is required"}
+	}
+	placeholders, args := inPlaceholders(ids)
+
+	todos, err := queryTODOs(ctx, s.db, fmt.Sprintf(readFmt, placeholders), append([]interface{}{ownerID(ctx)}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(todos) != len(ids) {
+		return nil, &model.ErrNotFound{}
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(reachFmt, placeholders), append(args, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// 依存先から依存元への辺と、依存元ごとの未処理の依存先の数
+	dependents := make(map[int64][]int64)
+	waiting := make(map[int64]int)
+	for rows.Next() {
+		var start, id int64
+		if err := rows.Scan(&start, &id); err != nil {
+			return nil, err
+		}
+		dependents[id] = append(dependents[id], start)
+		waiting[start]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Kahnのアルゴリズムで、依存先がすべて処理済みのTODOからpositionが最小のものを選ぶ
+	byID := make(map[int64]*model.TODO, len(todos))
+	ready := make([]*model.TODO, 0, len(todos))
+	for _, todo := range todos {
+		byID[todo.ID] = todo
+		if waiting[todo.ID] == 0 {
+			ready = append(ready, todo)
+		}
+	}
+	ordered := make([]*model.TODO, 0, len(todos))
+	for len(ready) > 0 {
+		next := 0
+		for i, todo := range ready {
+			if todo.Position < ready[next].Position || (todo.Position == ready[next].Position && todo.ID < ready[next].ID) {
+				next = i
+			}
+		}
+		todo := ready[next]
+		ready = append(ready[:next], ready[next+1:]...)
+		ordered = append(ordered, todo)
+
+		for _, id := range dependents[todo.ID] {
+			if waiting[id]--; waiting[id] == 0 {
+				ready = append(ready, byID[id])
+			}
+		}
+	}
+	if len(ordered) != len(todos) {
+		// 追加時に循環を検出しているので、通常は起こらない
+		return nil, errors.New("dependencies of TODOs contain a cycle")
+	}
+	return ordered, nil
+}
+
+// queryTODOs reads the TODOs selected with todoColumns by query using q together with their tags and custom fields.
+func queryTODOs(ctx context.Context, q queryer, query string, args ...interface{}) ([]*model.TODO, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := make([]*model.TODO, 0)
+	for rows.Next() {
+		todo, err := scanTODO(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadTags(ctx, q, todos); err != nil {
+		return nil, err
+	}
+	if err := loadFields(ctx, q, todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/TechBowl-japan/go-stations/common"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// recordEvent records the change of the TODO from before to after in its history using q.
+// 変更と同じトランザクションで記録し、履歴とデータがずれないようにする.
+// 変更したユーザーはcontextから取得し、TODOの所有者も記録する. 所有者のいないTODOの所有者は0になる.
+func recordEvent(ctx context.Context, q queryer, action model.TODOEventAction, id int64, before, after *model.TODO) error {
+	const insert = `INSERT INTO todo_events(todo_id, owner_id, action, actor, before_json, after_json) VALUES(?, ?, ?, ?, ?, ?)`
+
+	var owner int64
+	snapshots := make([]interface{}, 2)
+	for i, todo := range []*model.TODO{before, after} {
+		if todo == nil {
+			continue
+		}
+		owner = todo.OwnerID
+		b, err := json.Marshal(todo)
+		if err != nil {
+			return err
+		}
+		snapshots[i] = string(b)
+	}
+
+	_, err := q.ExecContext(ctx, insert, id, owner, action, common.GetActor(ctx), snapshots[0], snapshots[1])
+	return err
+}
+
+// ReadTODOHistory reads the history of the TODO on DB in descending order of id.
+// 履歴は削除されたTODOについても読めるが、他のユーザーのTODOの履歴は読めない.
+func (s *TODOService) ReadTODOHistory(ctx context.Context, id, prevID, size int64) ([]*model.TODOEvent, error) {
+	const (
+		read = `SELECT id, todo_id, action, actor, before_json, after_json, created_at FROM todo_events
+			WHERE todo_id = ? AND owner_id = ? AND (? = 0 OR id < ?) ORDER BY id DESC LIMIT ?`
+		exists = `SELECT EXISTS(SELECT 1 FROM todos WHERE id = ? AND IFNULL(owner_id, 0) = ?) OR EXISTS(SELECT 1 FROM todo_events WHERE todo_id = ? AND owner_id = ?)`
+	)
+
+	owner := common.GetUserID(ctx)
+	rows, err := s.db.QueryContext(ctx, read, id, owner, prevID, prevID, size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*model.TODOEvent, 0)
+	for rows.Next() {
+		var (
+			e             model.TODOEvent
+			before, after sql.NullString
+		)
+		if err := rows.Scan(&e.ID, &e.TODOID, &e.Action, &e.Actor, &before, &after, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if before.Valid {
+			e.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			e.After = json.RawMessage(after.String)
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 履歴がない場合はTODOが存在するかを確認する
+	if len(events) == 0 {
+		var found bool
+		if err := s.db.QueryRowContext(ctx, exists, id, owner, id, owner).Scan(&found); err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, &model.ErrNotFound{}
+		}
+	}
+	return events, nil
+}
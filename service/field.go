@@ -0,0 +1,396 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// customFieldColumns is the column list shared by every query that scans a CustomField with scanCustomField.
+const customFieldColumns = `id, project_id, name, type, options, created_at, updated_at`
+
+// fieldDateLayout is the layout of the values of date fields. 文字列のまま比較すると日付の順になる.
+const fieldDateLayout = "2006-01-02"
+
+// scanCustomField scans a row selected with customFieldColumns into a CustomField.
+func scanCustomField(row rowScanner) (*model.CustomField, error) {
+	var (
+		field   model.CustomField
+		options string
+	)
+	if err := row.Scan(&field.ID, &field.ProjectID, &field.Name, &field.Type, &options, &field.CreatedAt, &field.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(options), &field.Options); err != nil {
+		return nil, err
+	}
+	return &field, nil
+}
+
+// A CustomFieldService implements CRUD of the custom fields of projects.
+type CustomFieldService struct {
+	db *sql.DB
+}
+
+// NewCustomFieldService returns new CustomFieldService.
+func NewCustomFieldService(db *sql.DB) *CustomFieldService {
+	return &CustomFieldService{
+		db: db,
+	}
+}
+
+// CreateCustomField creates a custom field of the project on DB. 所有者はcontextのユーザーになる.
+// ユーザーの同じプロジェクトに同じ名前のフィールドがある場合はUNIQUE制約に違反する.
+func (s *CustomFieldService) CreateCustomField(ctx context.Context, projectID int64, name string, typ model.CustomFieldType, options []string) (*model.CustomField, error) {
+	const insert = `INSERT INTO custom_fields(owner_id, project_id, name, type, options) VALUES(?, ?, ?, ?, ?)`
+
+	if !typ.Valid() {
+		return nil, &model.ErrInvalid{Reason: fmt.Sprintf("invalid type %q", typ)}
+	}
+	encoded, err := encodeFieldOptions(typ, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var field *model.CustomField
+	err = withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkProject(ctx, tx, projectID); err != nil {
+			return err
+		}
+
+		// execute insert query
+		result, err := tx.ExecContext(ctx, insert, ownerID(ctx), projectID, name, typ, encoded)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// execute confirm query
+		field, err = getCustomField(ctx, tx, projectID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// ReadCustomField reads the custom fields of the user of ctx in the project on DB in order of id.
+func (s *CustomFieldService) ReadCustomField(ctx context.Context, projectID int64) ([]*model.CustomField, error) {
+	const read = `SELECT ` + customFieldColumns + ` FROM custom_fields WHERE project_id = ? AND owner_id IS ? ORDER BY id`
+
+	if err := checkProject(ctx, s.db, projectID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, read, projectID, ownerID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := make([]*model.CustomField, 0)
+	for rows.Next() {
+		field, err := scanCustomField(rows)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// GetCustomField reads the custom field of the project on DB by id.
+func (s *CustomFieldService) GetCustomField(ctx context.Context, projectID, id int64) (*model.CustomField, error) {
+	return getCustomField(ctx, s.db, projectID, id)
+}
+
+// getCustomField reads the custom field of the project by id using q. 他のユーザーのフィールドは存在しないものとして扱う.
+func getCustomField(ctx context.Context, q queryer, projectID, id int64) (*model.CustomField, error) {
+	const read = `SELECT ` + customFieldColumns + ` FROM custom_fields WHERE id = ? AND project_id = ? AND owner_id IS ?`
+
+	field, err := scanCustomField(q.QueryRowContext(ctx, read, id, projectID, ownerID(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	return field, err
+}
+
+// UpdateCustomField renames the custom field on DB and replaces its options.
+// enumの選択肢は、TODOの値として使われているものを削除できない.
+func (s *CustomFieldService) UpdateCustomField(ctx context.Context, projectID, id int64, name string, options []string) (*model.CustomField, error) {
+	const (
+		used   = `SELECT DISTINCT value FROM todo_field_values WHERE field_id = ?`
+		update = `UPDATE custom_fields SET name = ?, options = ? WHERE id = ?`
+	)
+
+	var field *model.CustomField
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := getCustomField(ctx, tx, projectID, id)
+		if err != nil {
+			return err
+		}
+		encoded, err := encodeFieldOptions(before.Type, options)
+		if err != nil {
+			return err
+		}
+
+		if before.Type == model.CustomFieldEnum {
+			rows, err := tx.QueryContext(ctx, used, id)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var value string
+				if err := rows.Scan(&value); err != nil {
+					return err
+				}
+				if !containsString(options, value) {
+					return &model.ErrConflict{Reason: fmt.Sprintf("option %q is in use", value)}
+				}
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			rows.Close()
+		}
+
+		// execute update query
+		if _, err := tx.ExecContext(ctx, update, name, encoded, id); err != nil {
+			return err
+		}
+
+		// execute confirm query
+		field, err = getCustomField(ctx, tx, projectID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// DeleteCustomField deletes the custom field on DB together with its values on TODOs.
+func (s *CustomFieldService) DeleteCustomField(ctx context.Context, projectID, id int64) error {
+	const deleteField = `DELETE FROM custom_fields WHERE id = ? AND project_id = ? AND owner_id IS ?`
+
+	// execute delete query
+	result, err := s.db.ExecContext(ctx, deleteField, id, projectID, ownerID(ctx))
+	if err != nil {
+		return err
+	}
+
+	// rows affected is 0, return ErrNotFound
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &model.ErrNotFound{}
+	}
+	return nil
+}
+
+// encodeFieldOptions validates the options of a field of the type and encodes them in JSON.
+// enumには重複のない空でない選択肢が必要で、それ以外の型には選択肢を指定できない.
+func encodeFieldOptions(typ model.CustomFieldType, options []string) (string, error) {
+	if typ != model.CustomFieldEnum {
+		if len(options) > 0 {
+			return "", &model.ErrInvalid{Reason: "options can only be given to enum fields"}
+		}
+		return "[]", nil
+	}
+
+	if len(options) == 0 {
+		return "", &model.ErrInvalid{Reason: "options is required for enum fields"}
+	}
+	for i, option := range options {
+		if option == "" {
+			return "", &model.ErrInvalid{Reason: "options must not be empty"}
+		}
+		if containsString(options[:i], option) {
+			return "", &model.ErrInvalid{Reason: fmt.Sprintf("duplicate option %q", option)}
+		}
+	}
+	b, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// containsString reports whether ss contains s.
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// getFieldByName reads the custom field of the project by name using q.
+// TODOの値や絞り込みで指定されるので、存在しない場合はErrInvalidを返す.
+func getFieldByName(ctx context.Context, q queryer, projectID int64, name string) (*model.CustomField, error) {
+	const read = `SELECT ` + customFieldColumns + ` FROM custom_fields WHERE project_id = ? AND owner_id IS ? AND name = ?`
+
+	field, err := scanCustomField(q.QueryRowContext(ctx, read, projectID, ownerID(ctx), name))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrInvalid{Reason: fmt.Sprintf("unknown field %q", name)}
+	}
+	return field, err
+}
+
+// fieldValue validates v decoded from JSON as a value of the field and returns the value stored in DB.
+func fieldValue(field *model.CustomField, v interface{}) (interface{}, error) {
+	invalid := &model.ErrInvalid{Reason: fmt.Sprintf("field %q must be a %s", field.Name, field.Type)}
+	if field.Type == model.CustomFieldNumber {
+		n, ok := v.(float64)
+		if !ok {
+			return nil, invalid
+		}
+		return n, nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return nil, invalid
+	}
+	return parseFieldValue(field, s)
+}
+
+// parseFieldValue parses s as a value of the field and returns the value stored in DB.
+// 絞り込みの値はクエリパラメータの文字列で指定されるので、数値もここで変換する.
+func parseFieldValue(field *model.CustomField, s string) (interface{}, error) {
+	switch field.Type {
+	case model.CustomFieldNumber:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, &model.ErrInvalid{Reason: fmt.Sprintf("field %q must be a number", field.Name)}
+		}
+		return n, nil
+	case model.CustomFieldDate:
+		if _, err := time.Parse(fieldDateLayout, s); err != nil {
+			return nil, &model.ErrInvalid{Reason: fmt.Sprintf("field %q must be a date in the form of YYYY-MM-DD", field.Name)}
+		}
+	case model.CustomFieldEnum:
+		if !containsString(field.Options, s) {
+			return nil, &model.ErrInvalid{Reason: fmt.Sprintf("field %q must be one of its options", field.Name)}
+		}
+	}
+	return s, nil
+}
+
+// setFields sets the values of the custom fields of the TODO by name using q. nilの値は削除する.
+// フィールドはTODOの現在のプロジェクトから探す.
+func setFields(ctx context.Context, q queryer, todoID int64, fields map[string]interface{}) error {
+	const (
+		readProject = `SELECT project_id FROM todos WHERE id = ?`
+		upsert      = `INSERT INTO todo_field_values(todo_id, field_id, value) VALUES(?, ?, ?)
+			ON CONFLICT(todo_id, field_id) DO UPDATE SET value = excluded.value`
+		remove = `DELETE FROM todo_field_values WHERE todo_id = ? AND field_id = ?`
+	)
+
+	if len(fields) == 0 {
+		return nil
+	}
+	var projectID int64
+	if err := q.QueryRowContext(ctx, readProject, todoID).Scan(&projectID); err != nil {
+		return err
+	}
+
+	// エラーになるフィールドが毎回同じになるように名前の順に処理する
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field, err := getFieldByName(ctx, q, projectID, name)
+		if err != nil {
+			return err
+		}
+		if fields[name] == nil {
+			if _, err := q.ExecContext(ctx, remove, todoID, field.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := fieldValue(field, fields[name])
+		if err != nil {
+			return err
+		}
+		if _, err := q.ExecContext(ctx, upsert, todoID, field.ID, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneFields removes the values of the TODO for the custom fields of other projects using q.
+// TODOを別のプロジェクトに移動した後に呼び出す. Inboxのフィールドはユーザーごとなので、TODOの所有者のフィールドだけを残す.
+func pruneFields(ctx context.Context, q queryer, todoID int64) error {
+	const prune = `DELETE FROM todo_field_values WHERE todo_id = ?
+		AND field_id NOT IN (SELECT f.id FROM custom_fields f JOIN todos t ON f.project_id = t.project_id AND f.owner_id IS t.owner_id WHERE t.id = ?)`
+
+	_, err := q.ExecContext(ctx, prune, todoID, todoID)
+	return err
+}
+
+// loadFields sets Fields of the todos with a single query.
+func loadFields(ctx context.Context, q queryer, todos []*model.TODO) error {
+	const readFmt = `SELECT v.todo_id, f.name, v.value FROM todo_field_values v JOIN custom_fields f ON f.id = v.field_id
+		WHERE v.todo_id IN (%s)`
+
+	if len(todos) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*model.TODO, len(todos))
+	ids := make([]int64, len(todos))
+	for i, todo := range todos {
+		todo.Fields = nil
+		byID[todo.ID] = todo
+		ids[i] = todo.ID
+	}
+
+	placeholders, args := inPlaceholders(ids)
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(readFmt, placeholders), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			todoID int64
+			name   string
+			value  interface{}
+		)
+		if err := rows.Scan(&todoID, &name, &value); err != nil {
+			return err
+		}
+		// 整数として保存された数値もJSONでは同じ数値になるようにfloat64にそろえる
+		switch v := value.(type) {
+		case int64:
+			value = float64(v)
+		case []byte:
+			value = string(v)
+		}
+		todo := byID[todoID]
+		if todo.Fields == nil {
+			todo.Fields = model.TODOFields{}
+		}
+		todo.Fields[name] = value
+	}
+	return rows.Err()
+}
@@ -0,0 +1,514 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// oidcStateTTL is how long a login may take between the redirect to the identity provider and the callback.
+	oidcStateTTL = 10 * time.Minute
+	// oidcDefaultUsernameClaim is the claim used as the local user name if OIDCConfig.UsernameClaim is empty.
+	oidcDefaultUsernameClaim = "preferred_username"
+	// oidcHTTPTimeout is the timeout of requests to the identity provider if OIDCConfig.HTTPClient is nil.
+	oidcHTTPTimeout = 10 * time.Second
+	// oidcMaxResponseSize is the maximum size of a response from the identity provider.
+	oidcMaxResponseSize = 1 << 20
+)
+
+// oidcSigningMethods are the algorithms accepted for ID tokens.
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// An OIDCConfig expresses the identity provider and the client registered to it.
+type OIDCConfig struct {
+	// Issuer is the issuer URL of the identity provider. 空の場合はOpenID Connectでログインできない.
+	Issuer string
+	// ClientID and ClientSecret are the credentials of this server registered to the identity provider.
+	// ClientSecretが空の場合はpublic clientとして、PKCEだけで認可コードを交換する.
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the URL of the callback endpoint registered to the identity provider.
+	RedirectURL string
+	// Scopes are requested in addition to openid.
+	Scopes []string
+	// UsernameClaim is the claim of the ID token used as the name of the local user.
+	UsernameClaim string
+	// HTTPClient is used to talk to the identity provider.
+	HTTPClient *http.Client
+}
+
+// oidcProvider is the part of the discovery document of the identity provider used by OIDCService.
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcJWK is a JSON Web Key published by the identity provider.
+type oidcJWK struct {
+	KTY string `json:"kty"`
+	Use string `json:"use"`
+	KID string `json:"kid"`
+	CRV string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// publicKey returns the RSA or ECDSA public key of k.
+func (k *oidcJWK) publicKey() (interface{}, error) {
+	switch k.KTY {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("oidc: invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.CRV {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("oidc: unsupported curve %q", k.CRV)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("oidc: invalid EC point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("oidc: unsupported key type %q", k.KTY)
+	}
+}
+
+// An OIDCService implements the login with an OpenID Connect identity provider using the
+// authorization code flow with PKCE. IDトークンのissuerとsubjectでローカルのユーザーに対応付け、
+// AuthServiceと同じアクセストークンとリフレッシュトークンを発行する.
+type OIDCService struct {
+	db       *sql.DB
+	sessions *AuthService
+	config   OIDCConfig
+	client   *http.Client
+
+	// mu guards the discovery document and the keys of the identity provider, fetched on first use.
+	mu       sync.Mutex
+	provider *oidcProvider
+	keys     map[string]interface{}
+}
+
+// NewOIDCService returns new OIDCService.
+func NewOIDCService(db *sql.DB, config OIDCConfig) *OIDCService {
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = oidcDefaultUsernameClaim
+	}
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: oidcHTTPTimeout}
+	}
+	return &OIDCService{
+		db:       db,
+		sessions: NewAuthService(db),
+		config:   config,
+		client:   client,
+	}
+}
+
+// AuthCodeURL starts a login and returns the URL of the identity provider to redirect the user agent to,
+// together with the state that the callback must present.
+func (s *OIDCService) AuthCodeURL(ctx context.Context) (string, string, error) {
+	const (
+		purge  = `DELETE FROM oidc_states WHERE expires_at <= DATETIME('now')`
+		insert = `INSERT INTO oidc_states(state_hash, nonce, code_verifier, expires_at) VALUES(?, ?, ?, ?)`
+	)
+
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	u, err := url.Parse(provider.AuthorizationEndpoint)
+	if err != nil {
+		return "", "", err
+	}
+
+	// stateとnonceとcode_verifierはいずれも推測できないランダムな値にする
+	var state, nonce, verifier string
+	for _, v := range []*string{&state, &nonce, &verifier} {
+		if *v, err = newSecret(""); err != nil {
+			return "", "", err
+		}
+	}
+
+	err = withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, purge); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, insert, hashSecret(state), nonce, verifier, sqliteTime(time.Now().Add(oidcStateTTL)))
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	scopes := []string{"openid"}
+	for _, scope := range s.config.Scopes {
+		if !containsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", s.config.ClientID)
+	query.Set("redirect_uri", s.config.RedirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+	return u.String(), state, nil
+}
+
+// Exchange completes the login started by AuthCodeURL with the state and the authorization code
+// given to the callback, and issues a new pair of tokens for the user of the ID token.
+// stateは一度しか使えず、期限切れや不明なstateはErrUnauthorizedを返す.
+func (s *OIDCService) Exchange(ctx context.Context, state, code string) (*model.AuthToken, error) {
+	const (
+		read    = `SELECT nonce, code_verifier FROM oidc_states WHERE state_hash = ? AND expires_at > DATETIME('now')`
+		consume = `DELETE FROM oidc_states WHERE state_hash = ?`
+	)
+
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if state == "" || code == "" {
+		return nil, &model.ErrUnauthorized{}
+	}
+
+	var nonce, verifier string
+	err = withTx(ctx, s.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, read, hashSecret(state)).Scan(&nonce, &verifier)
+		if err == sql.ErrNoRows {
+			return &model.ErrUnauthorized{}
+		}
+		if err != nil {
+			return err
+		}
+		// 同時に使われた場合も、先に削除した方だけを有効にする
+		result, err := tx.ExecContext(ctx, consume, hashSecret(state))
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return &model.ErrUnauthorized{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := s.exchangeCode(ctx, provider, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.verifyIDToken(ctx, provider, raw, nonce)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.mapUser(ctx, provider.Issuer, claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.sessions.IssueToken(ctx, user)
+}
+
+// discover returns the discovery document of the identity provider, fetching it on first use.
+func (s *OIDCService) discover(ctx context.Context) (*oidcProvider, error) {
+	if s.config.Issuer == "" {
+		return nil, &model.ErrUnavailable{Feature: "OpenID Connect login"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.provider != nil {
+		return s.provider, nil
+	}
+
+	var provider oidcProvider
+	if err := s.getJSON(ctx, strings.TrimSuffix(s.config.Issuer, "/")+"/.well-known/openid-configuration", &provider); err != nil {
+		return nil, err
+	}
+	// なりすましを防ぐため、discoveryのissuerは設定と完全に一致しなければならない
+	if provider.Issuer != s.config.Issuer {
+		return nil, fmt.Errorf("oidc: issuer of the discovery document is %q, want %q", provider.Issuer, s.config.Issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document lacks an endpoint")
+	}
+	s.provider = &provider
+	return s.provider, nil
+}
+
+// exchangeCode exchanges the authorization code for the ID token at the token endpoint.
+func (s *OIDCService) exchangeCode(ctx context.Context, provider *oidcProvider, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {s.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if s.config.ClientSecret == "" {
+		form.Set("client_id", s.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.config.ClientSecret != "" {
+		// client_secret_basicではクライアントの認証情報をURLエンコードしてから送る
+		req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("oidc: failed to decode token response: %w", err)
+	}
+	// 認可コードが不正、期限切れ、またはcode_verifierが一致しない場合はログインできない
+	if body.Error == "invalid_grant" {
+		return "", &model.ErrUnauthorized{}
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("oidc: token endpoint returned %d %q", resp.StatusCode, body.Error)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("oidc: token response lacks id_token")
+	}
+	return body.IDToken, nil
+}
+
+// verifyIDToken verifies the signature and the claims of the ID token and returns its claims.
+func (s *OIDCService) verifyIDToken(ctx context.Context, provider *oidcProvider, raw, nonce string) (jwt.MapClaims, error) {
+	// 鍵の取得に失敗した場合は、不正なトークンではなくサーバーのエラーとして扱う
+	var keyErr error
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := s.verificationKey(ctx, provider, kid)
+		if err != nil && !errors.As(err, new(*model.ErrUnauthorized)) {
+			keyErr = err
+		}
+		return key, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, keyFunc, jwt.WithValidMethods(oidcSigningMethods))
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	if err != nil {
+		return nil, &model.ErrUnauthorized{}
+	}
+
+	// issとaudとexpは必須で、azpがある場合はこのクライアントでなければならない
+	azp, _ := claims["azp"].(string)
+	got, _ := claims["nonce"].(string)
+	sub, _ := claims["sub"].(string)
+	switch {
+	case !claims.VerifyIssuer(provider.Issuer, true),
+		!claims.VerifyAudience(s.config.ClientID, true),
+		!claims.VerifyExpiresAt(time.Now().Unix(), true),
+		azp != "" && azp != s.config.ClientID,
+		subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1,
+		sub == "":
+		return nil, &model.ErrUnauthorized{}
+	}
+	return claims, nil
+}
+
+// verificationKey returns the public key kid of the identity provider.
+// 知らないkidの場合は、IdPが鍵をローテーションしたとみなしてJWKSを取得し直す.
+func (s *OIDCService) verificationKey(ctx context.Context, provider *oidcProvider, kid string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := s.getJSON(ctx, provider.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// 対応していない種類の鍵は無視する
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.KID] = key
+	}
+	s.keys = keys
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, &model.ErrUnauthorized{}
+	}
+	return key, nil
+}
+
+// mapUser returns the local user of the issuer and the subject of the ID token.
+// 初めてログインした場合は、UsernameClaimの名前で新しいユーザーを作成する. 既存のユーザーは名前が同じでも
+// 別人の可能性があるので対応付けず、名前が使われている場合は"-2"から順に番号を付ける.
+// 作成したユーザーはパスワードを持たず、Basic認証やPOST /auth/loginではログインできない.
+func (s *OIDCService) mapUser(ctx context.Context, issuer string, claims jwt.MapClaims) (*model.User, error) {
+	const (
+		readIdentity = `SELECT u.id, u.name, u.is_admin, u.created_at, u.updated_at FROM user_identities i JOIN users u ON u.id = i.user_id
+			WHERE i.issuer = ? AND i.subject = ?`
+		nameUsed       = `SELECT EXISTS(SELECT 1 FROM users WHERE name = ?)`
+		readCreated    = `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+		insertUser     = `INSERT INTO users(name, password_hash) VALUES(?, '')`
+		insertIdentity = `INSERT INTO user_identities(user_id, issuer, subject) VALUES(?, ?, ?)`
+	)
+
+	subject := claims["sub"].(string)
+	name, _ := claims[s.config.UsernameClaim].(string)
+
+	var user *model.User
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var err error
+		user, err = scanUser(tx.QueryRowContext(ctx, readIdentity, issuer, subject))
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		if name == "" {
+			return &model.ErrInvalid{Reason: fmt.Sprintf("the ID token has no %q claim", s.config.UsernameClaim)}
+		}
+		unique := name
+		for i := 2; ; i++ {
+			var used bool
+			if err := tx.QueryRowContext(ctx, nameUsed, unique).Scan(&used); err != nil {
+				return err
+			}
+			if !used {
+				break
+			}
+			unique = fmt.Sprintf("%s-%d", name, i)
+		}
+
+		result, err := tx.ExecContext(ctx, insertUser, unique)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if user, err = scanUser(tx.QueryRowContext(ctx, readCreated, id)); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, insertIdentity, user.ID, issuer, subject)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// getJSON gets the JSON document at u from the identity provider and decodes it into v.
+func (s *OIDCService) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s returned %d", u, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("oidc: failed to decode %s: %w", u, err)
+	}
+	return nil
+}
+
+// decodeBigInt decodes a base64url encoded unsigned integer of a JSON Web Key.
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("oidc: empty integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
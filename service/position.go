@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+const (
+	// positionGap is the distance between neighbouring positions of moved or rebalanced TODOs.
+	positionGap = 1024
+	// positionMinGap is the smallest distance between neighbours that a move can still split.
+	// これより狭くなった場合は全体の並び順を振り直す.
+	positionMinGap = 1e-6
+)
+
+// MoveTODO moves the TODO between the TODOs given by req.AfterID and req.BeforeID.
+// 通常は移動するTODOのpositionを隣り合うTODOの中間にするだけで、隣が並び替えていないTODOや
+// 同じpositionのTODOの場合はそのpositionのTODOだけを、間隔が詰まった場合のみユーザーのTODO全体を振り直す.
+// positionのみの更新ではTODOのバージョンと更新日時は変わらない.
+func (s *TODOService) MoveTODO(ctx context.Context, req *model.MoveTODORequest) (*model.TODO, error) {
+	const move = `UPDATE todos SET position = ? WHERE id = ?`
+
+	if req.AfterID == 0 && req.BeforeID == 0 {
+		return nil, &model.ErrInvalid{Reason: "after_id or before_id is required"}
+	}
+	if req.AfterID == req.ID || req.BeforeID == req.ID {
+		return nil, &model.ErrInvalid{Reason: "a TODO cannot be moved next to itself"}
+	}
+
+	var todo *model.TODO
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := getTODO(ctx, tx, req.ID)
+		if err != nil {
+			return err
+		}
+
+		lo, hi, err := neighbourPositions(ctx, tx, req)
+		if err != nil {
+			return err
+		}
+		// 並び替えていないTODOはpositionが0で重なっているので、その隣に移動する場合や同じpositionのTODOの間に
+		// 移動する場合は、中間の値を決められるようにそのpositionのTODOだけを先に振り直す
+		var tied sql.NullFloat64
+		switch {
+		case lo.Valid && hi.Valid && lo.Float64 == hi.Float64:
+			tied = lo
+		case lo.Valid && lo.Float64 == 0, hi.Valid && hi.Float64 == 0:
+			tied = sql.NullFloat64{Valid: true}
+		}
+		if tied.Valid {
+			if err := spreadPositions(ctx, tx, tied.Float64, req.ID); err != nil {
+				return err
+			}
+			if lo, hi, err = neighbourPositions(ctx, tx, req); err != nil {
+				return err
+			}
+		}
+
+		position, ok := positionBetween(lo, hi)
+		if !ok {
+			if err := rebalancePositions(ctx, tx); err != nil {
+				return err
+			}
+			if lo, hi, err = neighbourPositions(ctx, tx, req); err != nil {
+				return err
+			}
+			position, _ = positionBetween(lo, hi)
+		}
+
+		if _, err := tx.ExecContext(ctx, move, position, req.ID); err != nil {
+			return err
+		}
+		if todo, err = getTODO(ctx, tx, req.ID); err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, model.TODOEventMove, req.ID, before, todo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// positionOf returns the position of the TODO. 他のユーザーのTODOとゴミ箱のTODOは見つからないものとして扱う.
+func positionOf(ctx context.Context, q queryer, id int64) (float64, error) {
+	const read = `SELECT position FROM todos WHERE id = ? AND owner_id IS ? AND deleted_at IS NULL`
+
+	var position float64
+	err := q.QueryRowContext(ctx, read, id, ownerID(ctx)).Scan(&position)
+	if err == sql.ErrNoRows {
+		return 0, &model.ErrNotFound{}
+	}
+	return position, err
+}
+
+// neighbourPositions returns the positions of the neighbours given by req.
+// 片方の隣だけが指定された場合は、もう片方の隣を移動するTODO以外の自分のTODOからpositionとidの順で探す.
+func neighbourPositions(ctx context.Context, q queryer, req *model.MoveTODORequest) (lo, hi sql.NullFloat64, err error) {
+	const (
+		next = `SELECT position FROM todos WHERE (position > ? OR (position = ? AND id > ?)) AND id <> ? AND owner_id IS ? AND deleted_at IS NULL
+			ORDER BY position, id LIMIT 1`
+		prev = `SELECT position FROM todos WHERE (position < ? OR (position = ? AND id < ?)) AND id <> ? AND owner_id IS ? AND deleted_at IS NULL
+			ORDER BY position DESC, id DESC LIMIT 1`
+	)
+
+	for _, n := range []struct {
+		id       int64
+		position *sql.NullFloat64
+		name     string
+	}{
+		{req.AfterID, &lo, "after_id"},
+		{req.BeforeID, &hi, "before_id"},
+	} {
+		if n.id == 0 {
+			continue
+		}
+		p, err := positionOf(ctx, q, n.id)
+		if err != nil {
+			// 隣に指定したTODOが存在しない場合はリクエストの内容が不正
+			var notFound *model.ErrNotFound
+			if errors.As(err, &notFound) {
+				return lo, hi, &model.ErrInvalid{Reason: n.name + " not found"}
+			}
+			return lo, hi, err
+		}
+		*n.position = sql.NullFloat64{Float64: p, Valid: true}
+	}
+
+	var neighbour sql.NullFloat64
+	switch {
+	case lo.Valid && hi.Valid:
+		if lo.Float64 > hi.Float64 || (lo.Float64 == hi.Float64 && req.AfterID > req.BeforeID) {
+			return lo, hi, &model.ErrInvalid{Reason: "after_id must come before before_id"}
+		}
+		return lo, hi, nil
+	case lo.Valid:
+		err = q.QueryRowContext(ctx, next, lo.Float64, lo.Float64, req.AfterID, req.ID, ownerID(ctx)).Scan(&neighbour)
+		hi = neighbour
+	default:
+		err = q.QueryRowContext(ctx, prev, hi.Float64, hi.Float64, req.BeforeID, req.ID, ownerID(ctx)).Scan(&neighbour)
+		lo = neighbour
+	}
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return lo, hi, err
+}
+
+// positionBetween returns the position between the neighbours lo and hi.
+// 隣との間隔が狭すぎる場合はokにfalseを返す.
+func positionBetween(lo, hi sql.NullFloat64) (position float64, ok bool) {
+	switch {
+	case !hi.Valid:
+		return lo.Float64 + positionGap, true
+	case !lo.Valid && hi.Float64 > 0 && hi.Float64 <= positionGap:
+		// 並び替えていないTODOのposition(0)と重ならないように、0との中間にする
+		lo = sql.NullFloat64{Valid: true}
+	case !lo.Valid:
+		return hi.Float64 - positionGap, true
+	}
+	return (lo.Float64 + hi.Float64) / 2, hi.Float64-lo.Float64 >= positionMinGap
+}
+
+// spreadPositions renumbers the TODOs of the user at the position except the moved TODO id
+// between the position and the next position keeping their order.
+// 振り直すのは同じpositionで重なっているTODOだけで、他のTODOは変えない.
+func spreadPositions(ctx context.Context, tx *sql.Tx, position float64, id int64) error {
+	const (
+		next   = `SELECT MIN(position) FROM todos WHERE position > ? AND id <> ? AND owner_id IS ? AND deleted_at IS NULL`
+		count  = `SELECT COUNT(*) FROM todos WHERE position = ? AND id <> ? AND owner_id IS ? AND deleted_at IS NULL`
+		spread = `WITH ranked AS (SELECT id, ROW_NUMBER() OVER (ORDER BY id) AS n FROM todos
+				WHERE position = ? AND id <> ? AND owner_id IS ? AND deleted_at IS NULL)
+			UPDATE todos SET position = ? + ranked.n * ? FROM ranked WHERE ranked.id = todos.id`
+	)
+
+	var (
+		hi sql.NullFloat64
+		n  int64
+	)
+	if err := tx.QueryRowContext(ctx, next, position, id, ownerID(ctx)).Scan(&hi); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, count, position, id, ownerID(ctx)).Scan(&n); err != nil {
+		return err
+	}
+
+	// 後ろにTODOがない場合はpositionGapの間隔で並べ、ある場合はその手前までに均等に並べる
+	step := float64(positionGap)
+	if hi.Valid {
+		step = (hi.Float64 - position) / float64(n+1)
+	}
+	_, err := tx.ExecContext(ctx, spread, position, id, ownerID(ctx), position, step)
+	return err
+}
+
+// rebalancePositions renumbers the positions of every TODO of the user at intervals of positionGap keeping their order.
+// ゴミ箱のTODOと他のユーザーのTODOは変えない.
+func rebalancePositions(ctx context.Context, tx *sql.Tx) error {
+	const rebalance = `WITH ranked AS (SELECT id, ROW_NUMBER() OVER (ORDER BY position, id) AS n FROM todos
+			WHERE owner_id IS ? AND deleted_at IS NULL)
+		UPDATE todos SET position = ranked.n * ? FROM ranked WHERE ranked.id = todos.id AND todos.owner_id IS ? AND todos.deleted_at IS NULL`
+
+	owner := ownerID(ctx)
+	_, err := tx.ExecContext(ctx, rebalance, owner, positionGap, owner)
+	return err
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// projectColumns is the column list shared by every query that scans a Project with scanProject.
+const projectColumns = `id, name, created_at, updated_at`
+
+// scanProject scans a row selected with projectColumns into a Project.
+func scanProject(row rowScanner) (*model.Project, error) {
+	var project model.Project
+	if err := row.Scan(&project.ID, &project.Name, &project.CreatedAt, &project.UpdatedAt); err != nil {
+		return nil, err
+	}
+	project.Inbox = project.ID == model.InboxProjectID
+	return &project, nil
+}
+
+// A ProjectService implements CRUD of Project entities.
+type ProjectService struct {
+	db *sql.DB
+}
+
+// NewProjectService returns new ProjectService.
+func NewProjectService(db *sql.DB) *ProjectService {
+	return &ProjectService{
+		db: db,
+	}
+}
+
+// CreateProject creates a Project on DB. 所有者はcontextのユーザーになる.
+func (s *ProjectService) CreateProject(ctx context.Context, name string) (*model.Project, error) {
+	const insert = `INSERT INTO projects(owner_id, name) VALUES(?, ?)`
+
+	// execute insert query
+	result, err := s.db.ExecContext(ctx, insert, ownerID(ctx), name)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProject(ctx, id)
+}
+
+// ReadProject reads the Projects of the user of ctx on DB. 共有のInboxが先頭になる.
+func (s *ProjectService) ReadProject(ctx context.Context) ([]*model.Project, error) {
+	const read = `SELECT ` + projectColumns + ` FROM projects WHERE id = ? OR owner_id IS ? ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, read, model.InboxProjectID, ownerID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := make([]*model.Project, 0)
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+// GetProject reads the Project on DB by id. 他のユーザーのプロジェクトは存在しないものとして扱う.
+func (s *ProjectService) GetProject(ctx context.Context, id int64) (*model.Project, error) {
+	const read = `SELECT ` + projectColumns + ` FROM projects WHERE id = ? AND (id = ? OR owner_id IS ?)`
+
+	project, err := scanProject(s.db.QueryRowContext(ctx, read, id, model.InboxProjectID, ownerID(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// UpdateProject renames the Project on DB. Inboxは全員で共有するので名前を変更できない.
+func (s *ProjectService) UpdateProject(ctx context.Context, id int64, name string) (*model.Project, error) {
+	const update = `UPDATE projects SET name = ? WHERE id = ? AND owner_id IS ?`
+
+	if id == model.InboxProjectID {
+		return nil, &model.ErrInvalid{Reason: "the inbox project cannot be renamed"}
+	}
+
+	// execute update query
+	row, err := s.db.ExecContext(ctx, update, name, id, ownerID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	// row affected is 0, return ErrNotFound
+	if affected, _ := row.RowsAffected(); affected == 0 {
+		return nil, &model.ErrNotFound{}
+	}
+
+	return s.GetProject(ctx, id)
+}
+
+// DeleteProject deletes the Project on DB.
+// modeに応じて、プロジェクトのTODOをサブタスクと一緒にゴミ箱に移動するかInboxに移動する.
+// ゴミ箱に移動したTODOも、復元できるようにプロジェクトを削除する前にInboxに移す.
+// 所有者を追加する前に他のユーザーが作成したTODOは、ゴミ箱に移動せずにInboxに移動する.
+func (s *ProjectService) DeleteProject(ctx context.Context, id int64, mode model.ProjectDeleteMode) error {
+	const (
+		moveTODOs     = `UPDATE todos SET project_id = ? WHERE project_id = ?`
+		deleteProject = `DELETE FROM projects WHERE id = ? AND owner_id IS ?`
+	)
+
+	if id == model.InboxProjectID {
+		return &model.ErrInvalid{Reason: "the inbox project cannot be deleted"}
+	}
+
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		// 他のユーザーのプロジェクトのTODOを移動しないように、先に所有者を確認する
+		if err := checkProject(ctx, tx, id); err != nil {
+			return err
+		}
+
+		var err error
+		switch mode {
+		case model.ProjectDeleteCascade:
+			_, err = trashTODOs(ctx, tx, "project_id = ?", id)
+		case model.ProjectDeleteMoveToInbox:
+		default:
+			err = &model.ErrInvalid{Reason: `todos must be "cascade" or "inbox"`}
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, moveTODOs, model.InboxProjectID, id); err != nil {
+			return err
+		}
+
+		// execute delete query
+		rows, err := tx.ExecContext(ctx, deleteProject, id, ownerID(ctx))
+		if err != nil {
+			return err
+		}
+
+		// rows affected is 0, return ErrNotFound
+		if affected, _ := rows.RowsAffected(); affected == 0 {
+			return &model.ErrNotFound{}
+		}
+		return nil
+	})
+}
+
+// checkProject returns ErrNotFound if the project does not exist or belongs to another user.
+func checkProject(ctx context.Context, q queryer, id int64) error {
+	const exists = `SELECT EXISTS(SELECT 1 FROM projects WHERE id = ? AND (id = ? OR owner_id IS ?))`
+
+	var found bool
+	if err := q.QueryRowContext(ctx, exists, id, model.InboxProjectID, ownerID(ctx)).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return &model.ErrNotFound{}
+	}
+	return nil
+}
+
+// checkTODOProject returns ErrInvalid if a TODO cannot be put in the project.
+// 存在しないプロジェクトと同じく、他のユーザーのプロジェクトも外部キー制約の違反と同じ400にする.
+func checkTODOProject(ctx context.Context, q queryer, id int64) error {
+	err := checkProject(ctx, q, id)
+	if errors.As(err, new(*model.ErrNotFound)) {
+		return &model.ErrInvalid{Reason: fmt.Sprintf("project %d not found", id)}
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A ReminderService fires reminders of TODOs whose remind_at has passed.
+type ReminderService struct {
+	db *sql.DB
+}
+
+// NewReminderService returns new ReminderService.
+func NewReminderService(db *sql.DB) *ReminderService {
+	return &ReminderService{
+		db: db,
+	}
+}
+
+// FireDueReminders records a reminder event for every open TODO outside the trash whose remind_at is not after now
+// and returns the events fired by this call.
+// reminder_eventsの(todo_id, remind_at)の一意制約により、再起動や複数プロセスでも同じリマインダーは一度しか発火しない.
+func (s *ReminderService) FireDueReminders(ctx context.Context, now time.Time) ([]*model.ReminderEvent, error) {
+	const (
+		due = `SELECT id, subject, remind_at FROM todos
+			WHERE remind_at <= ? AND completed = FALSE AND deleted_at IS NULL
+			AND NOT EXISTS(SELECT 1 FROM reminder_events e WHERE e.todo_id = todos.id AND e.remind_at = todos.remind_at)
+			ORDER BY remind_at, id`
+		insert  = `INSERT OR IGNORE INTO reminder_events(todo_id, remind_at) VALUES(?, ?)`
+		confirm = `SELECT fired_at FROM reminder_events WHERE id = ?`
+	)
+
+	events := make([]*model.ReminderEvent, 0)
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, due, sqliteTime(now))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var candidates []*model.ReminderEvent
+		for rows.Next() {
+			var e model.ReminderEvent
+			if err := rows.Scan(&e.TODOID, &e.Subject, &e.RemindAt); err != nil {
+				return err
+			}
+			candidates = append(candidates, &e)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		for _, e := range candidates {
+			result, err := tx.ExecContext(ctx, insert, e.TODOID, sqliteTime(e.RemindAt))
+			if err != nil {
+				return err
+			}
+			// 既に他で記録されている場合は発火しない
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				continue
+			}
+			if e.ID, err = result.LastInsertId(); err != nil {
+				return err
+			}
+			if err := tx.QueryRowContext(ctx, confirm, e.ID).Scan(&e.FiredAt); err != nil {
+				return err
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Run fires due reminders every interval until ctx is done and passes each fired event to notify.
+// 起動時にも一度実行し、停止中に過ぎたリマインダーを発火する.
+func (s *ReminderService) Run(ctx context.Context, interval time.Duration, notify func(*model.ReminderEvent)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		events, err := s.FireDueReminders(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			// 一時的なエラーでサーバーを止めないように、ログに出して次の実行を待つ
+			log.Println("reminder: failed to fire reminders, err =", err)
+		}
+		for _, e := range events {
+			notify(e)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// reportMaxDays is the largest number of days that a time report can cover.
+const reportMaxDays = 366
+
+// TimeReport aggregates the time entries on DB from the date from to the date to, including the day to,
+// by period and by tag. If owner is empty, the time of every user is aggregated.
+// 集計するのはcontextのユーザーが所有するTODOの記録だけになる.
+// 日や週の区切りはサーバーのタイムゾーン(time.Local)で判定し、区切りをまたぐ記録は分割して数える.
+// 計測中のタイマーは現在までの時間を数える.
+func (s *TimeEntryService) TimeReport(ctx context.Context, from, to time.Time, period model.TimeReportPeriod, owner string) (*model.TimeReport, error) {
+	const read = `SELECT e.todo_id, e.started_at, e.stopped_at FROM time_entries e JOIN todos ON todos.id = e.todo_id
+		WHERE todos.owner_id IS ? AND todos.deleted_at IS NULL AND e.started_at < ? AND (e.stopped_at IS NULL OR e.stopped_at > ?) AND (? = '' OR e.owner = ?)`
+
+	if !period.Valid() {
+		return nil, &model.ErrInvalid{Reason: "invalid period"}
+	}
+	start, end := startOfDay(from), startOfDay(to).AddDate(0, 0, 1)
+	if !start.Before(end) {
+		return nil, &model.ErrInvalid{Reason: "from must not be after to"}
+	}
+	if start.AddDate(0, 0, reportMaxDays).Before(end) {
+		return nil, &model.ErrInvalid{Reason: "a report cannot cover more than 366 days"}
+	}
+
+	rows, err := s.db.QueryContext(ctx, read, ownerID(ctx), sqliteTime(end), sqliteTime(start), owner, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type span struct {
+		todoID     int64
+		start, end time.Time
+	}
+	now := time.Now()
+	var spans []span
+	todos := make(map[int64]*model.TODO)
+	for rows.Next() {
+		var (
+			sp        span
+			stoppedAt sql.NullTime
+		)
+		if err := rows.Scan(&sp.todoID, &sp.start, &stoppedAt); err != nil {
+			return nil, err
+		}
+		sp.end = now
+		if stoppedAt.Valid {
+			sp.end = stoppedAt.Time
+		}
+		// 集計する期間の外の部分は数えない
+		if sp.start.Before(start) {
+			sp.start = start
+		}
+		if sp.end.After(end) {
+			sp.end = end
+		}
+		spans = append(spans, sp)
+		todos[sp.todoID] = &model.TODO{ID: sp.todoID}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// TODOごとのタグはloadTagsで読み込み、タグのないTODOの時間はタグのidを0として数える
+	list := make([]*model.TODO, 0, len(todos))
+	for _, todo := range todos {
+		list = append(list, todo)
+	}
+	if err := loadTags(ctx, s.db, list); err != nil {
+		return nil, err
+	}
+	tagIDs := make(map[int64][]int64, len(todos))
+	tagByID := make(map[int64]*model.Tag)
+	for _, todo := range list {
+		if len(todo.Tags) == 0 {
+			tagIDs[todo.ID] = []int64{0}
+			continue
+		}
+		for i := range todo.Tags {
+			tagIDs[todo.ID] = append(tagIDs[todo.ID], todo.Tags[i].ID)
+			tagByID[todo.Tags[i].ID] = &todo.Tags[i]
+		}
+	}
+
+	// 期間はUnix時間の開始時刻で識別する
+	var (
+		starts   []time.Time
+		total    time.Duration
+		totals   = make(map[int64]time.Duration)
+		byTag    = make(map[int64]map[int64]time.Duration)
+		allByTag = make(map[int64]time.Duration)
+	)
+	for p := periodStart(start, period); p.Before(end); p = nextPeriod(p, period) {
+		starts = append(starts, p)
+		byTag[p.Unix()] = make(map[int64]time.Duration)
+	}
+	for _, sp := range spans {
+		for t := sp.start; t.Before(sp.end); {
+			p := periodStart(t, period)
+			next := nextPeriod(p, period)
+			if next.After(sp.end) {
+				next = sp.end
+			}
+			d := next.Sub(t)
+			total += d
+			totals[p.Unix()] += d
+			for _, id := range tagIDs[sp.todoID] {
+				byTag[p.Unix()][id] += d
+				allByTag[id] += d
+			}
+			t = next
+		}
+	}
+
+	report := &model.TimeReport{
+		From:     start.Format("2006-01-02"),
+		To:       startOfDay(to).Format("2006-01-02"),
+		TimeZone: time.Local.String(),
+		Period:   period,
+		Total:    int64(total / time.Second),
+		Periods:  make([]model.TimeReportRow, len(starts)),
+		Tags:     reportTags(allByTag, tagByID),
+	}
+	for i, p := range starts {
+		report.Periods[i] = model.TimeReportRow{
+			Start:   p.Format("2006-01-02"),
+			Seconds: int64(totals[p.Unix()] / time.Second),
+			Tags:    reportTags(byTag[p.Unix()], tagByID),
+		}
+	}
+	return report, nil
+}
+
+// reportTags converts the durations by tag id into TimeReportTags in order of the tag name.
+// タグのない時間は最後に置く.
+func reportTags(durations map[int64]time.Duration, tagByID map[int64]*model.Tag) []model.TimeReportTag {
+	tags := make([]model.TimeReportTag, 0, len(durations))
+	for id, d := range durations {
+		tags = append(tags, model.TimeReportTag{Tag: tagByID[id], Seconds: int64(d / time.Second)})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		a, b := tags[i].Tag, tags[j].Tag
+		switch {
+		case a == nil || b == nil:
+			return b == nil && a != nil
+		case a.Name != b.Name:
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	return tags
+}
+
+// startOfDay returns the beginning of the day of t in the server's time zone.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// periodStart returns the beginning of the period containing t. 週は月曜日から始まる.
+func periodStart(t time.Time, period model.TimeReportPeriod) time.Time {
+	day := startOfDay(t)
+	if period == model.TimeReportPeriodWeek {
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return day
+}
+
+// nextPeriod returns the beginning of the period after the period starting at p.
+// AddDateで日付を進めるので、夏時間の切り替えがあっても0時になる.
+func nextPeriod(p time.Time, period model.TimeReportPeriod) time.Time {
+	if period == model.TimeReportPeriodWeek {
+		return p.AddDate(0, 0, 7)
+	}
+	return p.AddDate(0, 0, 1)
+}
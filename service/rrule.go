@@ -0,0 +1,363 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An rrule is a parsed RFC 5545 recurrence rule.
+// FREQ(DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTHと
+// WKST=MOのみ対応している.
+type rrule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	byDay      []rruleDay
+	byMonthDay []int
+	byMonth    []time.Month
+}
+
+// An rruleDay is an element of BYDAY such as MO, 1MO or -1FR.
+// nが0の場合は期間内のすべての該当曜日を表す.
+type rruleDay struct {
+	n       int
+	weekday time.Weekday
+}
+
+// rruleWeekdays maps the weekday names of RFC 5545 to time.Weekday.
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// rruleMaxEmptyPeriods is the number of consecutive periods without an occurrence after which
+// the rule is treated as exhausted. FREQ=MONTHLY;BYMONTH=2;BYMONTHDAY=30のように発生しない規則で止まらないようにする.
+const rruleMaxEmptyPeriods = 1000
+
+// parseRRule parses the value of an RRULE property such as "FREQ=WEEKLY;BYDAY=MO,WE".
+// 先頭の"RRULE:"は省略できる.
+func parseRRule(s string) (*rrule, error) {
+	r := &rrule{interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "RRULE:"), ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("rrule: invalid part %q", part)
+		}
+		name, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		if seen[name] {
+			return nil, fmt.Errorf("rrule: duplicate %s", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				r.freq = value
+			default:
+				err = fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			r.interval, err = parseRRuleInt(value, 1, 1<<16)
+		case "COUNT":
+			r.count, err = parseRRuleInt(value, 1, 1<<16)
+		case "UNTIL":
+			r.until, err = parseRRuleUntil(value)
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				d, err := parseRRuleDay(v)
+				if err != nil {
+					return nil, fmt.Errorf("rrule: BYDAY: %w", err)
+				}
+				r.byDay = append(r.byDay, d)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				d, err := parseRRuleInt(v, -31, 31)
+				if err != nil || d == 0 {
+					return nil, fmt.Errorf("rrule: invalid BYMONTHDAY %q", v)
+				}
+				r.byMonthDay = append(r.byMonthDay, d)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				m, err := parseRRuleInt(v, 1, 12)
+				if err != nil {
+					return nil, fmt.Errorf("rrule: BYMONTH: %w", err)
+				}
+				r.byMonth = append(r.byMonth, time.Month(m))
+			}
+			sort.Slice(r.byMonth, func(i, j int) bool { return r.byMonth[i] < r.byMonth[j] })
+		case "WKST":
+			if value != "MO" {
+				err = fmt.Errorf("unsupported WKST %q", value)
+			}
+		default:
+			err = fmt.Errorf("unsupported part %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rrule: %s: %w", name, err)
+		}
+	}
+
+	if r.freq == "" {
+		return nil, fmt.Errorf("rrule: FREQ is required")
+	}
+	if r.count > 0 && !r.until.IsZero() {
+		return nil, fmt.Errorf("rrule: COUNT and UNTIL cannot be used together")
+	}
+	for _, d := range r.byDay {
+		// 第n曜日の指定は月または年の中でのみ意味を持つ
+		if d.n != 0 && r.freq != "MONTHLY" && r.freq != "YEARLY" {
+			return nil, fmt.Errorf("rrule: BYDAY with a position requires FREQ=MONTHLY or YEARLY")
+		}
+	}
+	if r.freq == "YEARLY" && len(r.byDay) > 0 && len(r.byMonth) == 0 {
+		return nil, fmt.Errorf("rrule: BYDAY with FREQ=YEARLY requires BYMONTH")
+	}
+	return r, nil
+}
+
+// parseRRuleInt parses an integer value in [lo, hi].
+func parseRRuleInt(s string, lo, hi int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n < lo || n > hi {
+		return 0, fmt.Errorf("%d is out of range", n)
+	}
+	return n, nil
+}
+
+// parseRRuleUntil parses UNTIL in the UTC, local date-time or date form.
+// 日付のみの場合はその日の終わりまでを含む.
+func parseRRuleUntil(s string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("20060102", s, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.AddDate(0, 0, 1).Add(-time.Second), nil
+}
+
+// parseRRuleDay parses an element of BYDAY.
+func parseRRuleDay(s string) (rruleDay, error) {
+	if len(s) < 2 {
+		return rruleDay{}, fmt.Errorf("invalid weekday %q", s)
+	}
+	weekday, ok := rruleWeekdays[s[len(s)-2:]]
+	if !ok {
+		return rruleDay{}, fmt.Errorf("invalid weekday %q", s)
+	}
+	d := rruleDay{weekday: weekday}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return rruleDay{}, fmt.Errorf("invalid position %q", s)
+		}
+		d.n = n
+	}
+	return d, nil
+}
+
+// next returns up to n occurrences of r starting at dtstart that are after t.
+// 発生日時はdtstartのタイムゾーンで評価し、時刻はdtstartの時刻を使う.
+func (r *rrule) next(dtstart, t time.Time, n int) []time.Time {
+	occurrences := make([]time.Time, 0, n)
+	if n <= 0 {
+		return occurrences
+	}
+	r.each(dtstart, func(o time.Time) bool {
+		if o.After(t) {
+			occurrences = append(occurrences, o)
+		}
+		return len(occurrences) < n
+	})
+	return occurrences
+}
+
+// each calls fn with the occurrences of r starting at dtstart in order until fn returns false
+// or the rule is exhausted.
+func (r *rrule) each(dtstart time.Time, fn func(time.Time) bool) {
+	count, empty := 0, 0
+	for period := 0; ; period += r.interval {
+		candidates := r.expand(dtstart, period)
+		if len(candidates) == 0 {
+			if empty++; empty > rruleMaxEmptyPeriods {
+				return
+			}
+			continue
+		}
+		empty = 0
+		for _, o := range candidates {
+			if o.Before(dtstart) {
+				continue
+			}
+			if !r.until.IsZero() && o.After(r.until) {
+				return
+			}
+			count++
+			if !fn(o) || (r.count > 0 && count >= r.count) {
+				return
+			}
+		}
+	}
+}
+
+// expand returns the candidates of the period that is the given number of FREQ units after dtstart in order.
+func (r *rrule) expand(dtstart time.Time, period int) []time.Time {
+	y, m, d := dtstart.Date()
+	var days []time.Time
+	switch r.freq {
+	case "DAILY":
+		day := r.date(dtstart, y, m, d+period)
+		if r.matchMonth(day.Month()) && r.matchMonthDay(day) && r.matchWeekday(day) {
+			days = append(days, day)
+		}
+	case "WEEKLY":
+		// WKST=MOなので、dtstartを含む週の月曜日から数える
+		monday := d - (int(dtstart.Weekday())+6)%7 + 7*period
+		for i := 0; i < 7; i++ {
+			day := r.date(dtstart, y, m, monday+i)
+			if len(r.byDay) == 0 && day.Weekday() != dtstart.Weekday() {
+				continue
+			}
+			if r.matchMonth(day.Month()) && r.matchWeekday(day) {
+				days = append(days, day)
+			}
+		}
+	case "MONTHLY":
+		first := r.date(dtstart, y, m+time.Month(period), 1)
+		if r.matchMonth(first.Month()) {
+			days = r.expandMonth(dtstart, first.Year(), first.Month())
+		}
+	case "YEARLY":
+		months := r.byMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+		for _, month := range months {
+			days = append(days, r.expandMonth(dtstart, y+period, month)...)
+		}
+	}
+	return days
+}
+
+// expandMonth returns the candidates in the month in order.
+func (r *rrule) expandMonth(dtstart time.Time, y int, m time.Month) []time.Time {
+	last := r.date(dtstart, y, m+1, 0).Day()
+	var days []int
+	switch {
+	case len(r.byMonthDay) > 0:
+		for _, d := range r.byMonthDay {
+			if d < 0 {
+				d = last + d + 1
+			}
+			if d >= 1 && d <= last && (len(r.byDay) == 0 || r.matchDayInMonth(r.date(dtstart, y, m, d), last)) {
+				days = append(days, d)
+			}
+		}
+	case len(r.byDay) > 0:
+		for d := 1; d <= last; d++ {
+			if r.matchDayInMonth(r.date(dtstart, y, m, d), last) {
+				days = append(days, d)
+			}
+		}
+	default:
+		// 31日など、その月に存在しない日はスキップする
+		if dtstart.Day() <= last {
+			days = append(days, dtstart.Day())
+		}
+	}
+
+	sort.Ints(days)
+	times := make([]time.Time, 0, len(days))
+	for i, d := range days {
+		if i > 0 && days[i-1] == d {
+			continue
+		}
+		times = append(times, r.date(dtstart, y, m, d))
+	}
+	return times
+}
+
+// date returns the date at the clock time of dtstart in its location. 範囲外の日は正規化される.
+func (r *rrule) date(dtstart time.Time, y int, m time.Month, d int) time.Time {
+	hour, minute, sec := dtstart.Clock()
+	return time.Date(y, m, d, hour, minute, sec, 0, dtstart.Location())
+}
+
+// matchMonth reports whether m satisfies BYMONTH.
+func (r *rrule) matchMonth(m time.Month) bool {
+	if len(r.byMonth) == 0 {
+		return true
+	}
+	for _, month := range r.byMonth {
+		if month == m {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMonthDay reports whether the day of t satisfies BYMONTHDAY.
+func (r *rrule) matchMonthDay(t time.Time) bool {
+	if len(r.byMonthDay) == 0 {
+		return true
+	}
+	last := r.date(t, t.Year(), t.Month()+1, 0).Day()
+	for _, d := range r.byMonthDay {
+		if d == t.Day() || last+d+1 == t.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekday reports whether the weekday of t satisfies BYDAY without positions.
+func (r *rrule) matchWeekday(t time.Time) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+	for _, d := range r.byDay {
+		if d.weekday == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDayInMonth reports whether t satisfies BYDAY, counting positions within its month of last days.
+func (r *rrule) matchDayInMonth(t time.Time, last int) bool {
+	for _, d := range r.byDay {
+		if d.weekday != t.Weekday() {
+			continue
+		}
+		switch {
+		case d.n == 0:
+			return true
+		case d.n > 0 && (t.Day()-1)/7+1 == d.n:
+			return true
+		case d.n < 0 && (last-t.Day())/7+1 == -d.n:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRRuleNext(t *testing.T) {
+	t.Parallel()
+
+	jst := time.FixedZone("JST", 9*60*60)
+	at := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 9, 30, 0, 0, jst) }
+	// 2021-03-03は水曜日
+	dtstart := at(2021, 3, 3)
+
+	cases := map[string]struct {
+		rule  string
+		after time.Time
+		n     int
+		want  []time.Time
+	}{
+		"Daily": {
+			rule: "FREQ=DAILY", after: dtstart, n: 2,
+			want: []time.Time{at(2021, 3, 4), at(2021, 3, 5)},
+		},
+		"Including dtstart": {
+			rule: "FREQ=DAILY;INTERVAL=2", after: dtstart.Add(-time.Second), n: 3,
+			want: []time.Time{at(2021, 3, 3), at(2021, 3, 5), at(2021, 3, 7)},
+		},
+		"Weekly by day": {
+			rule: "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR", after: dtstart, n: 4,
+			want: []time.Time{at(2021, 3, 5), at(2021, 3, 8), at(2021, 3, 10), at(2021, 3, 12)},
+		},
+		"Biweekly": {
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", after: dtstart, n: 2,
+			want: []time.Time{at(2021, 3, 15), at(2021, 3, 29)},
+		},
+		"Monthly skips missing days": {
+			rule: "FREQ=MONTHLY;BYMONTHDAY=31", after: dtstart, n: 3,
+			want: []time.Time{at(2021, 3, 31), at(2021, 5, 31), at(2021, 7, 31)},
+		},
+		"Monthly last day": {
+			rule: "FREQ=MONTHLY;BYMONTHDAY=-1", after: dtstart, n: 2,
+			want: []time.Time{at(2021, 3, 31), at(2021, 4, 30)},
+		},
+		"Monthly last friday": {
+			rule: "FREQ=MONTHLY;BYDAY=-1FR", after: dtstart, n: 2,
+			want: []time.Time{at(2021, 3, 26), at(2021, 4, 30)},
+		},
+		"Monthly second tuesday": {
+			rule: "FREQ=MONTHLY;BYDAY=2TU", after: dtstart, n: 2,
+			want: []time.Time{at(2021, 3, 9), at(2021, 4, 13)},
+		},
+		"Yearly": {
+			rule: "FREQ=YEARLY;BYMONTH=1,7", after: dtstart, n: 3,
+			want: []time.Time{at(2021, 7, 3), at(2022, 1, 3), at(2022, 7, 3)},
+		},
+		"Count includes dtstart": {
+			rule: "FREQ=WEEKLY;COUNT=3", after: dtstart, n: 5,
+			want: []time.Time{at(2021, 3, 10), at(2021, 3, 17)},
+		},
+		"Until": {
+			rule: "FREQ=DAILY;UNTIL=20210305", after: dtstart, n: 5,
+			want: []time.Time{at(2021, 3, 4), at(2021, 3, 5)},
+		},
+		"Never occurs": {
+			rule: "FREQ=MONTHLY;BYMONTH=2;BYMONTHDAY=30", after: dtstart, n: 1,
+			want: []time.Time{},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := parseRRule(c.rule)
+			if err != nil {
+				t.Fatal("unexpected error, err =", err)
+			}
+			if diff := cmp.Diff(c.want, r.next(dtstart, c.after, c.n)); diff != "" {
+				t.Errorf("unexpected value (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseRRuleError(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"Empty":             "",
+		"No FREQ":           "INTERVAL=2",
+		"Unsupported FREQ":  "FREQ=HOURLY",
+		"Unsupported part":  "FREQ=DAILY;BYHOUR=9",
+		"Zero interval":     "FREQ=DAILY;INTERVAL=0",
+		"Count and until":   "FREQ=DAILY;COUNT=2;UNTIL=20210101",
+		"Invalid weekday":   "FREQ=WEEKLY;BYDAY=XX",
+		"Weekly position":   "FREQ=WEEKLY;BYDAY=1MO",
+		"Zero month day":    "FREQ=MONTHLY;BYMONTHDAY=0",
+		"Duplicate part":    "FREQ=DAILY;FREQ=WEEKLY",
+		"Yearly by day":     "FREQ=YEARLY;BYDAY=MO",
+		"Unsupported WKST":  "FREQ=WEEKLY;WKST=SU",
+		"Missing separator": "FREQ",
+	}
+
+	for name, rule := range cases {
+		rule := rule
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if r, err := parseRRule(rule); err == nil {
+				t.Errorf("expected error, given = %+v", r)
+			}
+		})
+	}
+}
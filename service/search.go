@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// SearchTODO searches TODOs on DB by subject and description using the FTS5 index.
+// 結果はbm25の順位で並び、prevIDを指定するとその次の順位から返す.
+func (s *TODOService) SearchTODO(ctx context.Context, query string, prevID, size int64) ([]*model.SearchTODOResult, error) {
+	const (
+		exists = `SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'todos_fts')`
+		search = `WITH hits AS (
+  SELECT rowid AS id, bm25(todos_fts) AS rank,
+         highlight(todos_fts, 0, '<mark>', '</mark>') AS subject_highlight,
+         snippet(todos_fts, 1, '<mark>', '</mark>', '…', 16) AS description_snippet
+  FROM todos_fts WHERE todos_fts MATCH ?
+), prev AS (
+  SELECT rank, id FROM hits WHERE id = ?
+)
+SELECT ` + todoColumns + `, h.rank, h.subject_highlight, h.description_snippet
+FROM hits h JOIN todos USING (id)
+WHERE todos.owner_id IS ? AND todos.deleted_at IS NULL AND (? = 0 OR EXISTS(SELECT 1 FROM prev p WHERE h.rank > p.rank OR (h.rank = p.rank AND h.id > p.id)))
+ORDER BY h.rank, h.id LIMIT ?`
+	)
+
+	var found bool
+	if err := s.db.QueryRowContext(ctx, exists).Scan(&found); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, &model.ErrUnavailable{Feature: "full-text search"}
+	}
+
+	rows, err := s.db.QueryContext(ctx, search, ftsQuery(query), prevID, ownerID(ctx), prevID, size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*model.SearchTODOResult, 0)
+	for rows.Next() {
+		var result model.SearchTODOResult
+		todo, err := scanTODO(rows, &result.Rank, &result.SubjectHighlight, &result.DescriptionSnippet)
+		if err != nil {
+			return nil, err
+		}
+		result.TODO = *todo
+		results = append(results, &result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	todos := make([]*model.TODO, len(results))
+	for i, result := range results {
+		todos[i] = &result.TODO
+	}
+	if err := loadTags(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+	if err := loadFields(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ftsQuery converts free text into an FTS5 query that matches all of its terms.
+// 各語をダブルクォートで囲み、FTS5の演算子や列フィルタとして解釈されないようにする.
+func ftsQuery(text string) string {
+	terms := strings.Fields(text)
+	for i, term := range terms {
+		terms[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// tagColumns is the column list shared by every query that scans a Tag with scanTag.
+const tagColumns = `id, name, created_at, updated_at`
+
+// scanTag scans a row selected with tagColumns into a Tag.
+func scanTag(row rowScanner, extra ...interface{}) (*model.Tag, error) {
+	var tag model.Tag
+	dest := []interface{}{&tag.ID, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// A TagService implements CRUD of Tag entities.
+type TagService struct {
+	db *sql.DB
+}
+
+// NewTagService returns new TagService.
+func NewTagService(db *sql.DB) *TagService {
+	return &TagService{
+		db: db,
+	}
+}
+
+// CreateTag creates a Tag on DB. 所有者はcontextのユーザーになる.
+func (s *TagService) CreateTag(ctx context.Context, name string) (*model.Tag, error) {
+	const insert = `INSERT INTO tags(owner_id, name) VALUES(?, ?)`
+
+	// execute insert query
+	result, err := s.db.ExecContext(ctx, insert, ownerID(ctx), name)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTag(ctx, id)
+}
+
+// ReadTag reads the Tags of the user of ctx on DB ordered by name.
+func (s *TagService) ReadTag(ctx context.Context) ([]*model.Tag, error) {
+	const read = `SELECT ` + tagColumns + ` FROM tags WHERE owner_id IS ? ORDER BY name, id`
+
+	rows, err := s.db.QueryContext(ctx, read, ownerID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]*model.Tag, 0)
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// GetTag reads the Tag on DB by id. 他のユーザーのタグは存在しないものとして扱う.
+func (s *TagService) GetTag(ctx context.Context, id int64) (*model.Tag, error) {
+	const read = `SELECT ` + tagColumns + ` FROM tags WHERE id = ? AND owner_id IS ?`
+
+	tag, err := scanTag(s.db.QueryRowContext(ctx, read, id, ownerID(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// UpdateTag renames the Tag on DB.
+func (s *TagService) UpdateTag(ctx context.Context, id int64, name string) (*model.Tag, error) {
+	const update = `UPDATE tags SET name = ? WHERE id = ? AND owner_id IS ?`
+
+	// execute update query
+	row, err := s.db.ExecContext(ctx, update, name, id, ownerID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	// row affected is 0, return ErrNotFound
+	if affected, _ := row.RowsAffected(); affected == 0 {
+		return nil, &model.ErrNotFound{}
+	}
+
+	return s.GetTag(ctx, id)
+}
+
+// DeleteTag deletes the Tag on DB. TODOからは自動的に外れる.
+func (s *TagService) DeleteTag(ctx context.Context, id int64) error {
+	const deleteTag = `DELETE FROM tags WHERE id = ? AND owner_id IS ?`
+
+	// execute delete query
+	rows, err := s.db.ExecContext(ctx, deleteTag, id, ownerID(ctx))
+	if err != nil {
+		return err
+	}
+
+	// rows affected is 0, return ErrNotFound
+	if affected, _ := rows.RowsAffected(); affected == 0 {
+		return &model.ErrNotFound{}
+	}
+
+	return nil
+}
+
+// attachTags attaches the tags of the user of ctx to the TODO. 既に付いているタグは無視する.
+// 存在しないタグと他のユーザーのタグの場合は、外部キー制約の違反と同じくErrInvalidを返す.
+func attachTags(ctx context.Context, q queryer, todoID int64, tagIDs []int64) error {
+	const (
+		countFmt = `SELECT COUNT(*) FROM tags WHERE id IN (%s) AND owner_id IS ?`
+		attach   = `INSERT OR IGNORE INTO todo_tags(todo_id, tag_id) VALUES(?, ?)`
+	)
+
+	tagIDs = uniqueIDs(tagIDs)
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	placeholders, args := inPlaceholders(tagIDs)
+	var found int
+	if err := q.QueryRowContext(ctx, fmt.Sprintf(countFmt, placeholders), append(args, ownerID(ctx))...).Scan(&found); err != nil {
+		return err
+	}
+	if found != len(tagIDs) {
+		return &model.ErrInvalid{Reason: "tag not found"}
+	}
+
+	for _, tagID := range tagIDs {
+		if _, err := q.ExecContext(ctx, attach, todoID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detachTags detaches the tags from the TODO. 付いていないタグは無視する.
+func detachTags(ctx context.Context, q queryer, todoID int64, tagIDs []int64) error {
+	const detachFmt = `DELETE FROM todo_tags WHERE todo_id = ? AND tag_id IN (%s)`
+
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	placeholders, args := inPlaceholders(tagIDs)
+	_, err := q.ExecContext(ctx, fmt.Sprintf(detachFmt, placeholders), append([]interface{}{todoID}, args...)...)
+	return err
+}
+
+// loadTags sets Tags of the todos with a single query.
+func loadTags(ctx context.Context, q queryer, todos []*model.TODO) error {
+	const readFmt = `SELECT ` + tagColumns + `, todo_id FROM tags JOIN todo_tags ON tag_id = id
+WHERE todo_id IN (%s) ORDER BY name, id`
+
+	if len(todos) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*model.TODO, len(todos))
+	ids := make([]int64, len(todos))
+	for i, todo := range todos {
+		todo.Tags = nil
+		byID[todo.ID] = todo
+		ids[i] = todo.ID
+	}
+
+	placeholders, args := inPlaceholders(ids)
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(readFmt, placeholders), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID int64
+		tag, err := scanTag(rows, &todoID)
+		if err != nil {
+			return err
+		}
+		todo := byID[todoID]
+		todo.Tags = append(todo.Tags, *tag)
+	}
+	return rows.Err()
+}
+
+// uniqueIDs returns ids without duplicates, keeping the first occurrence order.
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
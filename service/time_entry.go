@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/common"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// timeEntryColumns is the column list shared by every query that scans a TimeEntry with scanTimeEntry.
+const timeEntryColumns = `id, todo_id, owner, started_at, stopped_at, note, created_at, updated_at`
+
+// scanTimeEntry scans a row selected with timeEntryColumns into a TimeEntry.
+// 計測中のタイマーのDurationは現在までの時間にする.
+func scanTimeEntry(row rowScanner) (*model.TimeEntry, error) {
+	var (
+		e         model.TimeEntry
+		stoppedAt sql.NullTime
+	)
+	if err := row.Scan(&e.ID, &e.TODOID, &e.Owner, &e.StartedAt, &stoppedAt, &e.Note, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		return nil, err
+	}
+	end := time.Now()
+	if stoppedAt.Valid {
+		e.StoppedAt = &stoppedAt.Time
+		end = stoppedAt.Time
+	}
+	e.Duration = int64(end.Sub(e.StartedAt) / time.Second)
+	return &e, nil
+}
+
+// A TimeEntryService implements time tracking on TODOs.
+// ゴミ箱のTODOの作業時間は見つからないものとして扱う.
+type TimeEntryService struct {
+	db *sql.DB
+}
+
+// NewTimeEntryService returns new TimeEntryService.
+func NewTimeEntryService(db *sql.DB) *TimeEntryService {
+	return &TimeEntryService{
+		db: db,
+	}
+}
+
+// StartTimer starts the timer of the user in ctx on the TODO on DB.
+// タイマーはユーザーごとに一つまでで、別のタイマーが計測中の場合はErrConflictを返す.
+func (s *TimeEntryService) StartTimer(ctx context.Context, todoID int64, note string) (*model.TimeEntry, error) {
+	const (
+		running = `SELECT todo_id FROM time_entries WHERE owner_id IS ? AND stopped_at IS NULL`
+		insert  = `INSERT INTO time_entries(todo_id, owner_id, owner, started_at, note) VALUES(?, ?, ?, ?, ?)`
+	)
+
+	var entry *model.TimeEntry
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkTODO(ctx, tx, todoID); err != nil {
+			return err
+		}
+
+		var runningID int64
+		err := tx.QueryRowContext(ctx, running, ownerID(ctx)).Scan(&runningID)
+		if err == nil {
+			return &model.ErrConflict{Reason: fmt.Sprintf("a timer is already running on TODO %d", runningID)}
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		// execute insert query
+		result, err := tx.ExecContext(ctx, insert, todoID, ownerID(ctx), common.GetActor(ctx), sqliteTime(time.Now()), note)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// execute confirm query
+		entry, err = getTimeEntry(ctx, tx, todoID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// StopTimer stops the running timer of the user in ctx on the TODO on DB.
+// 計測中のタイマーがない場合はErrNotFoundを返す.
+func (s *TimeEntryService) StopTimer(ctx context.Context, todoID int64) (*model.TimeEntry, error) {
+	const (
+		running = `SELECT id FROM time_entries WHERE owner_id IS ? AND todo_id = ? AND stopped_at IS NULL`
+		stop    = `UPDATE time_entries SET stopped_at = MAX(?, started_at) WHERE id = ?`
+	)
+
+	var entry *model.TimeEntry
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkTODO(ctx, tx, todoID); err != nil {
+			return err
+		}
+
+		var id int64
+		err := tx.QueryRowContext(ctx, running, ownerID(ctx), todoID).Scan(&id)
+		if err == sql.ErrNoRows {
+			return &model.ErrNotFound{}
+		}
+		if err != nil {
+			return err
+		}
+
+		// execute update query
+		if _, err := tx.ExecContext(ctx, stop, sqliteTime(time.Now()), id); err != nil {
+			return err
+		}
+
+		// execute confirm query
+		entry, err = getTimeEntry(ctx, tx, todoID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// CreateTimeEntry records the time spent on the TODO on DB by the user in ctx.
+// 手動で記録する作業時間にはstoppedAtが必要になる.
+func (s *TimeEntryService) CreateTimeEntry(ctx context.Context, todoID int64, startedAt time.Time, stoppedAt *time.Time, note string) (*model.TimeEntry, error) {
+	const insert = `INSERT INTO time_entries(todo_id, owner_id, owner, started_at, stopped_at, note) VALUES(?, ?, ?, ?, ?, ?)`
+
+	if stoppedAt == nil {
+		return nil, &model.ErrInvalid{Reason: "stopped_at is required"}
+	}
+	if err := checkTimeRange(startedAt, stoppedAt); err != nil {
+		return nil, err
+	}
+
+	var entry *model.TimeEntry
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkTODO(ctx, tx, todoID); err != nil {
+			return err
+		}
+
+		// execute insert query
+		result, err := tx.ExecContext(ctx, insert, todoID, ownerID(ctx), common.GetActor(ctx), sqliteTime(startedAt), sqliteNullTime(stoppedAt), note)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// execute confirm query
+		entry, err = getTimeEntry(ctx, tx, todoID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ReadTimeEntry reads at most size time entries on the TODO on DB before prevID in descending order of id.
+func (s *TimeEntryService) ReadTimeEntry(ctx context.Context, todoID, prevID, size int64) ([]*model.TimeEntry, error) {
+	const read = `SELECT ` + timeEntryColumns + ` FROM time_entries
+		WHERE todo_id = ? AND (? = 0 OR id < ?) ORDER BY id DESC LIMIT ?`
+
+	if err := checkTODO(ctx, s.db, todoID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, read, todoID, prevID, prevID, size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*model.TimeEntry, 0)
+	for rows.Next() {
+		entry, err := scanTimeEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetTimeEntry reads the time entry on the TODO on DB by id.
+func (s *TimeEntryService) GetTimeEntry(ctx context.Context, todoID, id int64) (*model.TimeEntry, error) {
+	return getTimeEntry(ctx, s.db, todoID, id)
+}
+
+// getTimeEntry reads the time entry on the TODO by id using q.
+func getTimeEntry(ctx context.Context, q queryer, todoID, id int64) (*model.TimeEntry, error) {
+	const read = `SELECT ` + timeEntryColumns + ` FROM time_entries
+		WHERE id = ? AND todo_id = ? AND EXISTS(SELECT 1 FROM todos WHERE todos.id = time_entries.todo_id AND owner_id IS ? AND deleted_at IS NULL)`
+
+	entry, err := scanTimeEntry(q.QueryRowContext(ctx, read, id, todoID, ownerID(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	return entry, err
+}
+
+// UpdateTimeEntry edits the time entry on DB.
+// 記録したユーザー以外は編集できない. 計測中のタイマーはstoppedAtを省略すると計測を続ける.
+func (s *TimeEntryService) UpdateTimeEntry(ctx context.Context, todoID, id int64, startedAt time.Time, stoppedAt *time.Time, note string) (*model.TimeEntry, error) {
+	const update = `UPDATE time_entries SET started_at = ?, stopped_at = ?, note = ? WHERE id = ?`
+
+	if err := checkTimeRange(startedAt, stoppedAt); err != nil {
+		return nil, err
+	}
+
+	var entry *model.TimeEntry
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := checkTimeEntryOwner(ctx, tx, todoID, id)
+		if err != nil {
+			return err
+		}
+		// 計測を終えた記録を計測中に戻すことはできない
+		if stoppedAt == nil && before.StoppedAt != nil {
+			return &model.ErrInvalid{Reason: "stopped_at is required"}
+		}
+
+		// execute update query
+		if _, err := tx.ExecContext(ctx, update, sqliteTime(startedAt), sqliteNullTime(stoppedAt), note, id); err != nil {
+			return err
+		}
+
+		// execute confirm query
+		entry, err = getTimeEntry(ctx, tx, todoID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// DeleteTimeEntry deletes the time entry on DB.
+// 記録したユーザー以外は削除できない.
+func (s *TimeEntryService) DeleteTimeEntry(ctx context.Context, todoID, id int64) error {
+	const deleteTimeEntry = `DELETE FROM time_entries WHERE id = ?`
+
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if _, err := checkTimeEntryOwner(ctx, tx, todoID, id); err != nil {
+			return err
+		}
+
+		// execute delete query
+		_, err := tx.ExecContext(ctx, deleteTimeEntry, id)
+		return err
+	})
+}
+
+// checkTimeEntryOwner returns the time entry, ErrNotFound if it does not exist,
+// or ErrForbidden if the user in ctx did not record it.
+func checkTimeEntryOwner(ctx context.Context, q queryer, todoID, id int64) (*model.TimeEntry, error) {
+	const owned = `SELECT owner_id IS ? FROM time_entries WHERE id = ?`
+
+	entry, err := getTimeEntry(ctx, q, todoID, id)
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	if err := q.QueryRowContext(ctx, owned, ownerID(ctx), id).Scan(&ok); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &model.ErrForbidden{}
+	}
+	return entry, nil
+}
+
+// checkTimeRange returns ErrInvalid if startedAt is missing or stoppedAt is before startedAt.
+func checkTimeRange(startedAt time.Time, stoppedAt *time.Time) error {
+	if startedAt.IsZero() {
+		return &model.ErrInvalid{Reason: "started_at is required"}
+	}
+	if stoppedAt != nil && stoppedAt.Before(startedAt) {
+		return &model.ErrInvalid{Reason: "stopped_at must not be before started_at"}
+	}
+	return nil
+}
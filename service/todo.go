@@ -5,12 +5,69 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/TechBowl-japan/go-stations/model"
 	"github.com/mattn/go-sqlite3"
 )
 
+// todoColumns is the column list shared by every query that scans a TODO with scanTODO.
+// blockedは未完了の依存先がある場合に1になる. ゴミ箱の依存先は数えない.
+// チェックリストの進捗もここで数え、TODOごとにクエリを発行しないようにする.
+const todoColumns = `id, owner_id, subject, description, project_id, parent_id, due_at, remind_at, rrule, priority, position, completed, completed_at, deleted_at, created_at, updated_at,
+	EXISTS(SELECT 1 FROM todo_dependencies d JOIN todos blocker ON blocker.id = d.depends_on_id
+		WHERE d.todo_id = todos.id AND NOT blocker.completed AND blocker.deleted_at IS NULL) AS blocked,
+	(SELECT COUNT(*) FROM checklist_items c WHERE c.todo_id = todos.id AND c.done) AS checklist_done,
+	(SELECT COUNT(*) FROM checklist_items c WHERE c.todo_id = todos.id) AS checklist_total`
+
+// A rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTODO scans a row selected with todoColumns into a TODO.
+// todoColumnsの後ろに続く列はextraに読み込む.
+func scanTODO(row rowScanner, extra ...interface{}) (*model.TODO, error) {
+	var (
+		todo        model.TODO
+		owner       sql.NullInt64
+		projectID   int64
+		parentID    sql.NullInt64
+		dueAt       sql.NullTime
+		remindAt    sql.NullTime
+		priority    int
+		completedAt sql.NullTime
+		deletedAt   sql.NullTime
+	)
+	dest := []interface{}{&todo.ID, &owner, &todo.Subject, &todo.Description, &projectID, &parentID, &dueAt, &remindAt, &todo.RRule, &priority, &todo.Position, &todo.Completed, &completedAt, &deletedAt, &todo.CreatedAt, &todo.UpdatedAt, &todo.Blocked, &todo.Checklist.Done, &todo.Checklist.Total}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	todo.OwnerID = owner.Int64
+	todo.Priority = model.TODOPriorityOf(priority)
+	if projectID != model.InboxProjectID {
+		todo.ProjectID = &projectID
+	}
+	if parentID.Valid {
+		todo.ParentID = &parentID.Int64
+	}
+	if dueAt.Valid {
+		todo.DueAt = &dueAt.Time
+	}
+	if remindAt.Valid {
+		todo.RemindAt = &remindAt.Time
+	}
+	if completedAt.Valid {
+		todo.CompletedAt = &completedAt.Time
+	}
+	if deletedAt.Valid {
+		todo.DeletedAt = &deletedAt.Time
+	}
+	return &todo, nil
+}
+
 // A TODOService implements CRUD of TODO entities.
+// TODOはcontextのユーザーが所有するものだけを扱い、他のユーザーのTODOは存在しないものとして扱う.
 type TODOService struct {
 	db *sql.DB
 }
@@ -23,60 +80,243 @@ func NewTODOService(db *sql.DB) *TODOService {
 }
 
 // CreateTODO creates a TODO on DB.
-func (s *TODOService) CreateTODO(ctx context.Context, subject, description string) (*model.TODO, error) {
-	const (
-		insert  = `INSERT INTO todos(subject, description) VALUES(?, ?)`
-		confirm = `SELECT subject, description, created_at, updated_at FROM todos WHERE id = ?`
-	)
+// ProjectIDが0の場合はInboxに作成する. ParentIDが0でない場合はそのTODOのサブタスクにする.
+// RRuleがある場合はDueAtを繰り返しの起点にする.
+// 並び替えていないTODOとしてpositionは0になり、並び替えたTODOより前に作成順で並ぶ.
+// TagIDsのタグとFieldsのカスタムフィールドの値も同じトランザクションで設定する.
+// 所有者はcontextのユーザーになる.
+func (s *TODOService) CreateTODO(ctx context.Context, req *model.CreateTODORequest) (*model.TODO, error) {
+	const insert = `INSERT INTO todos(owner_id, subject, description, project_id, parent_id, due_at, remind_at, rrule, rrule_start, priority)
+		VALUES(?, ?, ?, ?, NULLIF(?, 0), ?, ?, ?, CASE WHEN ? = '' THEN NULL ELSE ? END, ?)`
 
 	// subject is empty, return error
-	if subject == "" {
+	if req.Subject == "" {
 		return nil, sqlite3.Error{Code: sqlite3.ErrConstraint}
 	}
-
-	// execute insert query
-	result, err := s.db.ExecContext(ctx, insert, subject, description)
-	if err != nil {
+	if err := validateRRule(req.RRule); err != nil {
 		return nil, err
 	}
-
-	id, err := result.LastInsertId()
+	priority, err := priorityLevel(req.Priority)
 	if err != nil {
 		return nil, err
 	}
 
-	// execute confirm query
-	var todo model.TODO
-	err = s.db.QueryRowContext(ctx, confirm, id).Scan(&todo.Subject, &todo.Description, &todo.CreatedAt, &todo.UpdatedAt)
+	projectID := req.ProjectID
+	if projectID == 0 {
+		projectID = model.InboxProjectID
+	}
+
+	var todo *model.TODO
+	err = withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkTODOProject(ctx, tx, projectID); err != nil {
+			return err
+		}
+		if req.ParentID != 0 {
+			if err := checkParent(ctx, tx, 0, req.ParentID); err != nil {
+				return err
+			}
+		}
+
+		// execute insert query
+		dueAt := sqliteNullTime(req.DueAt)
+		result, err := tx.ExecContext(ctx, insert, ownerID(ctx), req.Subject, req.Description, projectID, req.ParentID, dueAt, sqliteNullTime(req.RemindAt), req.RRule, req.RRule, dueAt,
+			priority)
+		if err != nil {
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		if err := attachTags(ctx, tx, id, req.TagIDs); err != nil {
+			return err
+		}
+		if err := setFields(ctx, tx, id, req.Fields); err != nil {
+			return err
+		}
+
+		// execute confirm query
+		if todo, err = getTODO(ctx, tx, id); err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, model.TODOEventCreate, id, nil, todo)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return todo, nil
+}
 
-	// todoのidを設定
-	todo.ID = id
+// ReadTODO reads TODOs on DB filtered and ordered by req.
+func (s *TODOService) ReadTODO(ctx context.Context, req *model.ReadTODORequest) ([]*model.TODO, error) {
+	const readFmt = `SELECT ` + todoColumns + ` FROM todos%s ORDER BY %s LIMIT ?`
 
-	return &todo, nil
-}
+	sort := req.Sort
+	if req.Cursor != nil {
+		sort = req.Cursor.Sort
+	}
+	if sort == "" {
+		sort = model.TODOSortIDDesc
+	}
+	// 前のページを読む場合は逆順に読み込んでから並べ直す
+	backward := req.Cursor != nil && req.Cursor.Backward
+	column, op, dir := sort.Column(), ">", "ASC"
+	if sort.Desc() != backward {
+		op, dir = "<", "DESC"
+	}
 
-// ReadTODO reads TODOs on DB.
-func (s *TODOService) ReadTODO(ctx context.Context, prevID, size int64) ([]*model.TODO, error) {
-	const (
-		read       = `SELECT id, subject, description, created_at, updated_at FROM todos ORDER BY id DESC LIMIT ?`
-		readWithID = `SELECT id, subject, description, created_at, updated_at FROM todos WHERE id < ? ORDER BY id DESC LIMIT ?`
-	)
+	// カスタムフィールドで並べる場合は値を読むサブクエリを列として使う. 値がないTODOはNULLになる
+	name, sortByField := sort.Field()
+	if sortByField {
+		field, err := s.requestField(ctx, req.ProjectID, name)
+		if err != nil {
+			return nil, err
+		}
+		column = fmt.Sprintf("(SELECT value FROM todo_field_values WHERE todo_id = todos.id AND field_id = %d)", field.ID)
+	}
 
+	// 他のユーザーのTODOとゴミ箱のTODOは除く
 	var (
-		rows *sql.Rows
-		err  error
+		conds = []string{"owner_id IS ?", "deleted_at IS NULL"}
+		args  = []interface{}{ownerID(ctx)}
 	)
 
-	// prevIDがある場合はreadWithIDを実行し、ない場合はreadを実行
-	if prevID != 0 {
-		rows, err = s.db.QueryContext(ctx, readWithID, prevID, size)
-	} else {
-		rows, err = s.db.QueryContext(ctx, read, size)
+	// prevIDがある場合はそれより小さいidに絞り込む
+	if req.PrevID != 0 {
+		conds = append(conds, "id < ?")
+		args = append(args, req.PrevID)
+	}
+
+	// cursorがある場合はソート順でそのTODOより後ろに絞り込む
+	if c := req.Cursor; c != nil {
+		var key interface{}
+		switch {
+		case c.Key != nil:
+			key = sqliteTime(*c.Key)
+		case c.Num != nil:
+			key = *c.Num
+		case c.Str != nil:
+			key = *c.Str
+		}
+		switch {
+		case sortByField:
+			cond, condArgs := nullableCursorCond(column, op, key, c.ID)
+			conds = append(conds, cond)
+			args = append(args, condArgs...)
+		case column == "id" || key == nil:
+			conds = append(conds, "id "+op+" ?")
+			args = append(args, c.ID)
+		default:
+			conds = append(conds, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, op))
+			args = append(args, key, key, c.ID)
+		}
+	}
+
+	// プロジェクトで絞り込む
+	if req.ProjectID != 0 {
+		conds = append(conds, "project_id = ?")
+		args = append(args, req.ProjectID)
+	}
+
+	// 優先度で絞り込む
+	if req.Priority != "" {
+		priority, err := priorityLevel(req.Priority)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, "priority = ?")
+		args = append(args, priority)
+	}
+
+	// 期限で絞り込む. overdueは期限を過ぎた未完了のTODOに絞り込む
+	if req.DueBefore != nil {
+		conds = append(conds, "due_at < ?")
+		args = append(args, sqliteTime(*req.DueBefore))
+	}
+	if req.Overdue {
+		conds = append(conds, "due_at < DATETIME('now') AND completed = FALSE")
+	}
+
+	// statusに応じて完了状態で絞り込む
+	switch req.Status {
+	case model.TODOStatusOpen:
+		conds = append(conds, "completed = FALSE")
+	case model.TODOStatusCompleted:
+		conds = append(conds, "completed = TRUE")
+	}
+
+	// subjectの前方一致で絞り込む
+	if req.SubjectPrefix != "" {
+		conds = append(conds, `subject LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(req.SubjectPrefix)+"%")
+	}
+
+	// タグで絞り込む. TagsAnyはいずれか、TagsAllはすべてのタグが付いているTODOに絞り込む
+	if len(req.TagsAny) > 0 {
+		in, tagArgs := inPlaceholders(req.TagsAny)
+		conds = append(conds, "id IN (SELECT todo_id FROM todo_tags WHERE tag_id IN ("+in+"))")
+		args = append(args, tagArgs...)
+	}
+	if tagIDs := uniqueIDs(req.TagsAll); len(tagIDs) > 0 {
+		in, tagArgs := inPlaceholders(tagIDs)
+		conds = append(conds, "id IN (SELECT todo_id FROM todo_tags WHERE tag_id IN ("+in+") GROUP BY todo_id HAVING COUNT(*) = ?)")
+		args = append(append(args, tagArgs...), len(tagIDs))
 	}
 
+	// カスタムフィールドの値で絞り込む. 値はフィールドの型に合わせて変換してから比較する
+	for _, f := range req.Fields {
+		field, err := s.requestField(ctx, req.ProjectID, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseFieldValue(field, f.Value)
+		if err != nil {
+			return nil, err
+		}
+		var cmp string
+		switch f.Op {
+		case model.TODOFieldEq, "":
+			cmp = "="
+		case model.TODOFieldMin:
+			cmp = ">="
+		case model.TODOFieldMax:
+			cmp = "<="
+		default:
+			return nil, &model.ErrInvalid{Reason: fmt.Sprintf("invalid operator %q", f.Op)}
+		}
+		if cmp != "=" && field.Type != model.CustomFieldNumber && field.Type != model.CustomFieldDate {
+			return nil, &model.ErrInvalid{Reason: fmt.Sprintf("field %q cannot be compared by range", f.Name)}
+		}
+		conds = append(conds, "id IN (SELECT todo_id FROM todo_field_values WHERE field_id = ? AND value "+cmp+" ?)")
+		args = append(args, field.ID, value)
+	}
+
+	// 作成日時と更新日時の範囲で絞り込む
+	for _, r := range []struct {
+		cond string
+		t    *time.Time
+	}{
+		{"created_at >= ?", req.CreatedAfter},
+		{"created_at < ?", req.CreatedBefore},
+		{"updated_at >= ?", req.UpdatedAfter},
+		{"updated_at < ?", req.UpdatedBefore},
+	} {
+		if r.t != nil {
+			conds = append(conds, r.cond)
+			args = append(args, sqliteTime(*r.t))
+		}
+	}
+
+	where := " WHERE " + strings.Join(conds, " AND ")
+	orderBy := "id " + dir
+	if column != "id" {
+		orderBy = column + " " + dir + ", " + orderBy
+	}
+	args = append(args, req.Size)
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(readFmt, where, orderBy), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -86,94 +326,725 @@ func (s *TODOService) ReadTODO(ctx context.Context, prevID, size int64) ([]*mode
 	// rowsをscanするためのTODOのスライスを作成
 	todos := make([]*model.TODO, 0)
 	for rows.Next() {
-		var todo model.TODO
-		if err := rows.Scan(&todo.ID, &todo.Subject, &todo.Description, &todo.CreatedAt, &todo.UpdatedAt); err != nil {
+		todo, err := scanTODO(rows)
+		if err != nil {
 			return nil, err
 		}
-		todos = append(todos, &todo)
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	// todosが空の場合は空のスライスを返す
-	if len(todos) == 0 {
-		todos = []*model.TODO{}
+	if backward {
+		for i, j := 0, len(todos)-1; i < j; i, j = i+1, j-1 {
+			todos[i], todos[j] = todos[j], todos[i]
+		}
+	}
+
+	if err := loadTags(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+	if err := loadFields(ctx, s.db, todos); err != nil {
+		return nil, err
 	}
 
 	return todos, nil
 }
 
+// requestField reads the custom field used to filter or sort the TODOs of the project.
+// フィールドはプロジェクトごとに定義されるので、プロジェクトの指定が必要になる.
+func (s *TODOService) requestField(ctx context.Context, projectID int64, name string) (*model.CustomField, error) {
+	if projectID == 0 {
+		return nil, &model.ErrInvalid{Reason: "project_id is required to filter or sort by custom fields"}
+	}
+	return getFieldByName(ctx, s.db, projectID, name)
+}
+
+// nullableCursorCond returns the condition that selects the rows after the cursor at (key, id)
+// in the order of expr, which may be NULL, and id. keyがnilの場合はNULLの位置を表す.
+// SQLiteではNULLが最も小さい値として並ぶので、昇順では先頭、降順では末尾になる.
+func nullableCursorCond(expr, op string, key interface{}, id int64) (string, []interface{}) {
+	if key == nil {
+		if op == ">" {
+			return fmt.Sprintf("((%[1]s IS NULL AND id > ?) OR %[1]s IS NOT NULL)", expr), []interface{}{id}
+		}
+		return fmt.Sprintf("(%s IS NULL AND id < ?)", expr), []interface{}{id}
+	}
+	nulls := ""
+	if op == "<" {
+		nulls = " OR " + expr + " IS NULL"
+	}
+	return fmt.Sprintf("(%[1]s %[2]s ?%[3]s OR (%[1]s = ? AND id %[2]s ?))", expr, op, nulls), []interface{}{key, key, id}
+}
+
+// sqliteTime formats t in the same layout as DATETIME('now') so that it can be compared with stored values.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// sqliteNullTime formats t with sqliteTime, or returns nil to store NULL if t is nil.
+func sqliteNullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return sqliteTime(*t)
+}
+
+// priorityLevel returns the level of p stored in DB. 空文字はnoneとして扱う.
+func priorityLevel(p model.TODOPriority) (int, error) {
+	if p == "" {
+		return 0, nil
+	}
+	level, ok := p.Level()
+	if !ok {
+		return 0, &model.ErrInvalid{Reason: fmt.Sprintf("invalid priority %q", p)}
+	}
+	return level, nil
+}
+
+// validateRRule returns ErrInvalid if rule is neither empty nor a supported RRULE.
+func validateRRule(rule string) error {
+	if rule == "" {
+		return nil
+	}
+	if _, err := parseRRule(rule); err != nil {
+		return &model.ErrInvalid{Reason: err.Error()}
+	}
+	return nil
+}
+
+// escapeLike escapes the wildcard characters of LIKE with a backslash.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetTODO reads the TODO on DB by id. ゴミ箱のTODOは見つからないものとして扱う.
+func (s *TODOService) GetTODO(ctx context.Context, id int64) (*model.TODO, error) {
+	return getTODO(ctx, s.db, id)
+}
+
+// GetVersionedTODO reads the TODO on DB by id with its version.
+// バージョンはTODOを変更するたびに増え、ETagとIf-Matchによる条件付きの更新に使う.
+func (s *TODOService) GetVersionedTODO(ctx context.Context, id int64) (*model.TODO, int64, error) {
+	return getVersionedTODO(ctx, s.db, id)
+}
+
+// getTODO reads the TODO with its tags by id using q.
+func getTODO(ctx context.Context, q queryer, id int64) (*model.TODO, error) {
+	todo, _, err := getVersionedTODO(ctx, q, id)
+	return todo, err
+}
+
+// getVersionedTODO reads the TODO with its tags and its version by id using q.
+func getVersionedTODO(ctx context.Context, q queryer, id int64) (*model.TODO, int64, error) {
+	const read = `SELECT ` + todoColumns + `, version FROM todos WHERE id = ? AND owner_id IS ? AND deleted_at IS NULL`
+
+	var version int64
+	todo, err := scanTODO(q.QueryRowContext(ctx, read, id, ownerID(ctx)), &version)
+	if err == sql.ErrNoRows {
+		return nil, 0, &model.ErrNotFound{}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := loadTags(ctx, q, []*model.TODO{todo}); err != nil {
+		return nil, 0, err
+	}
+	if err := loadFields(ctx, q, []*model.TODO{todo}); err != nil {
+		return nil, 0, err
+	}
+	return todo, version, nil
+}
+
+// checkTODO returns ErrNotFound if the TODO does not exist, is owned by another user or is in the trash.
+// TODOに属するリソースを扱う前に、そのTODOが見えるかを確認するために使う.
+func checkTODO(ctx context.Context, q queryer, id int64) error {
+	const exists = `SELECT EXISTS(SELECT 1 FROM todos WHERE id = ? AND owner_id IS ? AND deleted_at IS NULL)`
+
+	var found bool
+	if err := q.QueryRowContext(ctx, exists, id, ownerID(ctx)).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return &model.ErrNotFound{}
+	}
+	return nil
+}
+
 // UpdateTODO updates the TODO on DB.
-func (s *TODOService) UpdateTODO(ctx context.Context, id int64, subject, description string) (*model.TODO, error) {
-	const (
-		update  = `UPDATE todos SET subject = ?, description = ? WHERE id = ?`
-		confirm = `SELECT subject, description, created_at, updated_at FROM todos WHERE id = ?`
-	)
+// req.Versionが0でない場合は、TODOがそのバージョンのままのときだけ更新する.
+// ProjectIDがnilの場合はプロジェクトを変更せず、0の場合はInboxに移動する. DueAt、RemindAt、RRuleとPriorityはnilの場合は変更しない.
+// RRuleを変更した場合はDueAt(nilの場合は現在の期限)を新しい繰り返しの起点にする.
+// AttachTagIDsとDetachTagIDsのタグの付け外しとFieldsのカスタムフィールドの更新も同じトランザクションで行う.
+func (s *TODOService) UpdateTODO(ctx context.Context, req *model.UpdateTODORequest) (*model.TODO, error) {
+	todo, _, err := s.UpdateVersionedTODO(ctx, req)
+	return todo, err
+}
+
+// UpdateVersionedTODO updates the TODO on DB like UpdateTODO and returns it with its version.
+// バージョンは更新と同じトランザクションで読むので、他の更新と入れ違うことはない.
+func (s *TODOService) UpdateVersionedTODO(ctx context.Context, req *model.UpdateTODORequest) (*model.TODO, int64, error) {
+	const update = `UPDATE todos SET subject = ?, description = ?, project_id = COALESCE(?, project_id),
+		due_at = COALESCE(?, due_at), remind_at = COALESCE(?, remind_at), priority = COALESCE(?, priority),
+		rrule_start = CASE WHEN COALESCE(?, rrule) = rrule THEN rrule_start WHEN ? = '' THEN NULL ELSE COALESCE(?, due_at) END, rrule = COALESCE(?, rrule)
+		WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`
 
 	// id is empty, return ErrNotFound
-	if id == 0 {
-		return nil, &model.ErrNotFound{}
+	if req.ID == 0 {
+		return nil, 0, &model.ErrNotFound{}
 	}
 
 	// subject empty, return error
-	if subject == "" {
-		return nil, sqlite3.Error{Code: sqlite3.ErrConstraint}
+	if req.Subject == "" {
+		return nil, 0, sqlite3.Error{Code: sqlite3.ErrConstraint}
+	}
+	if req.RRule != nil {
+		if err := validateRRule(*req.RRule); err != nil {
+			return nil, 0, err
+		}
+	}
+	var priority interface{}
+	if req.Priority != nil {
+		level, err := priorityLevel(*req.Priority)
+		if err != nil {
+			return nil, 0, err
+		}
+		priority = level
+	}
+	var projectID *int64
+	if req.ProjectID != nil {
+		id := *req.ProjectID
+		if id == 0 {
+			id = model.InboxProjectID
+		}
+		projectID = &id
 	}
 
-	// execute update query
-	row, err := s.db.ExecContext(ctx, update, subject, description, id)
+	var (
+		todo    *model.TODO
+		version int64
+	)
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := getTODO(ctx, tx, req.ID)
+		if err != nil {
+			return err
+		}
+		if projectID != nil {
+			if err := checkTODOProject(ctx, tx, *projectID); err != nil {
+				return err
+			}
+		}
+
+		// execute update query
+		dueAt := sqliteNullTime(req.DueAt)
+		row, err := tx.ExecContext(ctx, update, req.Subject, req.Description, projectID, dueAt, sqliteNullTime(req.RemindAt), priority,
+			req.RRule, req.RRule, dueAt, req.RRule, req.ID, req.Version, req.Version)
+		if err != nil {
+			return err
+		}
+
+		// row affected is 0, return ErrNotFound or ErrPreconditionFailed
+		if affected, _ := row.RowsAffected(); affected == 0 {
+			return notUpdated(ctx, tx, req.ID, req.Version)
+		}
+
+		if err := attachTags(ctx, tx, req.ID, req.AttachTagIDs); err != nil {
+			return err
+		}
+		if err := detachTags(ctx, tx, req.ID, req.DetachTagIDs); err != nil {
+			return err
+		}
+
+		// 移動先のプロジェクトにないフィールドの値は削除してから、新しい値を設定する
+		if err := pruneFields(ctx, tx, req.ID); err != nil {
+			return err
+		}
+		if err := setFields(ctx, tx, req.ID, req.Fields); err != nil {
+			return err
+		}
+
+		// execute confirm query
+		if todo, version, err = getVersionedTODO(ctx, tx, req.ID); err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, model.TODOEventUpdate, req.ID, before, todo)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return todo, version, nil
+}
+
+// PatchTODO updates only the given fields of the TODO on DB.
+// nilのフィールドは現在の値のまま残す.
+// subjectの検証はtodosテーブルのCHECK制約に任せる.
+// 親を変更する場合は、自身のサブタスクを親にして循環させることはできない.
+func (s *TODOService) PatchTODO(ctx context.Context, req *model.PatchTODORequest) (*model.TODO, error) {
+	todo, _, err := s.PatchVersionedTODO(ctx, req)
+	return todo, err
+}
+
+// PatchVersionedTODO updates only the given fields of the TODO on DB like PatchTODO and returns it with its version.
+func (s *TODOService) PatchVersionedTODO(ctx context.Context, req *model.PatchTODORequest) (*model.TODO, int64, error) {
+	const update = `UPDATE todos SET subject = COALESCE(?, subject), description = COALESCE(?, description), project_id = COALESCE(?, project_id),
+		parent_id = CASE WHEN ? IS NULL THEN parent_id ELSE NULLIF(?, 0) END,
+		due_at = CASE WHEN ? THEN ? ELSE due_at END, remind_at = CASE WHEN ? THEN ? ELSE remind_at END,
+		rrule_start = CASE WHEN COALESCE(?, rrule) = rrule THEN rrule_start WHEN ? = '' THEN NULL ELSE CASE WHEN ? THEN ? ELSE due_at END END,
+		rrule = COALESCE(?, rrule), priority = COALESCE(?, priority)
+		WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`
+
+	// 更新するフィールドがない場合はupdated_atを変えないように読み込みのみ行う
+	if req.Subject == nil && req.Description == nil && req.ProjectID == nil && req.ParentID == nil && req.DueAt == nil && req.RemindAt == nil && req.RRule == nil && req.Priority == nil && len(req.Fields) == 0 {
+		todo, version, err := s.GetVersionedTODO(ctx, req.ID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if req.Version != 0 && version != req.Version {
+			return nil, 0, &model.ErrPreconditionFailed{}
+		}
+		return todo, version, nil
+	}
+	if req.RRule != nil {
+		if err := validateRRule(*req.RRule); err != nil {
+			return nil, 0, err
+		}
+	}
+	var priority interface{}
+	if req.Priority != nil {
+		level, err := priorityLevel(*req.Priority)
+		if err != nil {
+			return nil, 0, err
+		}
+		priority = level
+	}
+
+	var (
+		todo    *model.TODO
+		version int64
+	)
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := getTODO(ctx, tx, req.ID)
+		if err != nil {
+			return err
+		}
+		if req.ProjectID != nil {
+			if err := checkTODOProject(ctx, tx, *req.ProjectID); err != nil {
+				return err
+			}
+		}
+		if req.ParentID != nil && *req.ParentID != 0 {
+			if err := checkParent(ctx, tx, req.ID, *req.ParentID); err != nil {
+				return err
+			}
+		}
+
+		// execute update query
+		args := []interface{}{req.Subject, req.Description, req.ProjectID, req.ParentID, req.ParentID}
+		args = append(args, patchTime(req.DueAt)...)
+		args = append(args, patchTime(req.RemindAt)...)
+		args = append(args, req.RRule, req.RRule)
+		args = append(args, patchTime(req.DueAt)...)
+		args = append(args, req.RRule, priority, req.ID, req.Version, req.Version)
+		row, err := tx.ExecContext(ctx, update, args...)
+		if err != nil {
+			return err
+		}
+
+		// row affected is 0, return ErrNotFound or ErrPreconditionFailed
+		if affected, _ := row.RowsAffected(); affected == 0 {
+			return notUpdated(ctx, tx, req.ID, req.Version)
+		}
+
+		if req.ProjectID != nil {
+			if err := pruneFields(ctx, tx, req.ID); err != nil {
+				return err
+			}
+		}
+		if err := setFields(ctx, tx, req.ID, req.Fields); err != nil {
+			return err
+		}
+
+		// execute confirm query
+		if todo, version, err = getVersionedTODO(ctx, tx, req.ID); err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, model.TODOEventUpdate, req.ID, before, todo)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return todo, version, nil
+}
+
+// patchTime returns the arguments of a "CASE WHEN ? THEN ? ELSE column END" clause for t.
+// tがnilなら更新せず、ゼロ値を指す場合はNULLにする.
+func patchTime(t *time.Time) []interface{} {
+	if t == nil {
+		return []interface{}{false, nil}
+	}
+	if t.IsZero() {
+		return []interface{}{true, nil}
+	}
+	return []interface{}{true, sqliteTime(*t)}
+}
+
+// checkParent returns ErrInvalid if parentID cannot be the parent of the TODO
+// because it does not exist, is in the trash or would create a cycle.
+// parentIDから祖先をたどり、その中にidがあれば循環になる. 新しく作成するTODOのidには0を渡す.
+func checkParent(ctx context.Context, q queryer, id, parentID int64) error {
+	const (
+		exists = `SELECT EXISTS(SELECT 1 FROM todos WHERE id = ? AND owner_id IS ? AND deleted_at IS NULL)`
+		cycle  = `WITH RECURSIVE ancestors(id) AS (
+			SELECT ?
+			UNION
+			SELECT parent_id FROM todos JOIN ancestors USING(id) WHERE parent_id IS NOT NULL
+		) SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = ?)`
+	)
+
+	var found bool
+	if err := q.QueryRowContext(ctx, exists, parentID, ownerID(ctx)).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return &model.ErrInvalid{Reason: "parent_id not found"}
+	}
+	if err := q.QueryRowContext(ctx, cycle, parentID, id).Scan(&found); err != nil {
+		return err
+	}
+	if found {
+		return &model.ErrInvalid{Reason: "parent_id would create a cycle"}
+	}
+	return nil
+}
+
+// GetTODOTree reads the TODO on DB by id together with all of its descendant subtasks.
+func (s *TODOService) GetTODOTree(ctx context.Context, id int64) (*model.TODOTree, error) {
+	const read = `WITH RECURSIVE subtree(id) AS (
+		SELECT id FROM todos WHERE id = ? AND owner_id IS ? AND deleted_at IS NULL
+		UNION
+		SELECT todos.id FROM todos JOIN subtree ON todos.parent_id = subtree.id WHERE todos.deleted_at IS NULL
+	) SELECT ` + todoColumns + ` FROM todos JOIN subtree USING(id) ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, read, id, ownerID(ctx))
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	// row affected is 0, return ErrNotFound
-	if affected, _ := row.RowsAffected(); affected == 0 {
+	todos := make([]*model.TODO, 0)
+	for rows.Next() {
+		todo, err := scanTODO(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := loadTags(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+	if err := loadFields(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+
+	// idの順に子を親に繋げる
+	nodes := make(map[int64]*model.TODOTree, len(todos))
+	for _, todo := range todos {
+		nodes[todo.ID] = &model.TODOTree{TODO: *todo, Children: []*model.TODOTree{}}
+	}
+	for _, todo := range todos {
+		if todo.ID == id || todo.ParentID == nil {
+			continue
+		}
+		if parent, ok := nodes[*todo.ParentID]; ok {
+			parent.Children = append(parent.Children, nodes[todo.ID])
+		}
+	}
+
+	root, ok := nodes[id]
+	if !ok {
 		return nil, &model.ErrNotFound{}
 	}
+	return root, nil
+}
+
+// notUpdated returns the reason why a versioned write to the TODO affected no rows.
+func notUpdated(ctx context.Context, q queryer, id, version int64) error {
+	const exists = `SELECT EXISTS(SELECT 1 FROM todos WHERE id = ? AND owner_id IS ? AND deleted_at IS NULL)`
+
+	if version == 0 {
+		return &model.ErrNotFound{}
+	}
+
+	var found bool
+	if err := q.QueryRowContext(ctx, exists, id, ownerID(ctx)).Scan(&found); err != nil {
+		return err
+	}
+	// TODOは存在するがバージョンが変わっている場合は更新の競合
+	if found {
+		return &model.ErrPreconditionFailed{}
+	}
+	return &model.ErrNotFound{}
+}
+
+// CompleteTODO marks the TODO as completed on DB.
+// 繰り返しのTODOの場合は次の繰り返しを同じトランザクションで作成してnextとして返す.
+func (s *TODOService) CompleteTODO(ctx context.Context, id int64) (todo, next *model.TODO, err error) {
+	const complete = `UPDATE todos SET completed = TRUE, completed_at = DATETIME('now') WHERE id = ? AND completed = FALSE AND deleted_at IS NULL`
+
+	err = withTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := getTODO(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, complete, id)
+		if err != nil {
+			return err
+		}
+		if todo, err = getTODO(ctx, tx, id); err != nil {
+			return err
+		}
+		// 既に完了している場合は履歴に記録せず、次の繰り返しも作成しない
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return nil
+		}
+		if err := recordEvent(ctx, tx, model.TODOEventComplete, id, before, todo); err != nil {
+			return err
+		}
+		next, err = createNextOccurrence(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return todo, next, nil
+}
+
+// createNextOccurrence creates the next instance of the recurring TODO after its due_at.
+// 繰り返しでない場合、繰り返しが終わった場合や既に作成済みの場合はnilを返す.
+// 繰り返しはtime.Localのタイムゾーンで評価する. 次の繰り返しは完了したTODOと同じ位置に並ぶ.
+func createNextOccurrence(ctx context.Context, tx *sql.Tx, id int64) (*model.TODO, error) {
+	const (
+		read = `SELECT rrule, rrule_start, due_at, remind_at FROM todos
+			WHERE id = ? AND rrule <> '' AND NOT EXISTS(SELECT 1 FROM todos next WHERE next.recurs_from = todos.id)`
+		insert = `INSERT INTO todos(owner_id, subject, description, project_id, parent_id, due_at, remind_at, rrule, rrule_start, recurs_from, priority, position)
+			SELECT owner_id, subject, description, project_id, parent_id, ?, ?, rrule, rrule_start, id, priority, position FROM todos WHERE id = ?`
+		copyTags   = `INSERT INTO todo_tags(todo_id, tag_id) SELECT ?, tag_id FROM todo_tags WHERE todo_id = ?`
+		copyFields = `INSERT INTO todo_field_values(todo_id, field_id, value) SELECT ?, field_id, value FROM todo_field_values WHERE todo_id = ?`
+	)
+
+	var (
+		rule       string
+		start, due time.Time
+		remindAt   sql.NullTime
+	)
+	err := tx.QueryRowContext(ctx, read, id).Scan(&rule, &start, &due, &remindAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	// execute confirm query
-	var todo model.TODO
-	err = s.db.QueryRowContext(ctx, confirm, id).Scan(&todo.Subject, &todo.Description, &todo.CreatedAt, &todo.UpdatedAt)
+	r, err := parseRRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	occurrences := r.next(start.In(time.Local), due, 1)
+	if len(occurrences) == 0 {
+		return nil, nil
+	}
+
+	// リマインダーは期限との間隔を保って次の繰り返しに移す
+	nextDue := occurrences[0]
+	var nextRemindAt interface{}
+	if remindAt.Valid {
+		nextRemindAt = sqliteTime(nextDue.Add(remindAt.Time.Sub(due)))
+	}
 
+	result, err := tx.ExecContext(ctx, insert, sqliteTime(nextDue), nextRemindAt, id)
+	if err != nil {
+		return nil, err
+	}
+	nextID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, copyTags, nextID, id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, copyFields, nextID, id); err != nil {
+		return nil, err
+	}
+	next, err := getTODO(ctx, tx, nextID)
 	if err != nil {
 		return nil, err
 	}
+	if err := recordEvent(ctx, tx, model.TODOEventCreate, nextID, nil, next); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
 
-	// todoのidを設定
-	todo.ID = id
+// PreviewTODOOccurrences returns the next n occurrences of the recurring TODO after its due_at.
+func (s *TODOService) PreviewTODOOccurrences(ctx context.Context, id, n int64) ([]time.Time, error) {
+	const read = `SELECT rrule, rrule_start, due_at FROM todos WHERE id = ? AND owner_id IS ? AND deleted_at IS NULL`
 
-	return &todo, nil
+	var (
+		rule       string
+		start, due sql.NullTime
+	)
+	err := s.db.QueryRowContext(ctx, read, id, ownerID(ctx)).Scan(&rule, &start, &due)
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rule == "" {
+		return nil, &model.ErrInvalid{Reason: "the TODO does not recur"}
+	}
+
+	r, err := parseRRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	return r.next(start.Time.In(time.Local), due.Time, int(n)), nil
 }
 
-// DeleteTODO deletes TODOs on DB by ids.
-func (s *TODOService) DeleteTODO(ctx context.Context, ids []int64) error {
-	const deleteFmt = `DELETE FROM todos WHERE id IN (?%s)`
+// ReopenTODO marks the completed TODO as open again on DB.
+func (s *TODOService) ReopenTODO(ctx context.Context, id int64) (*model.TODO, error) {
+	const reopen = `UPDATE todos SET completed = FALSE, completed_at = NULL WHERE id = ? AND completed = TRUE AND deleted_at IS NULL`
+
+	var todo *model.TODO
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := getTODO(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, reopen, id)
+		if err != nil {
+			return err
+		}
+		if todo, err = getTODO(ctx, tx, id); err != nil {
+			return err
+		}
+		// 既に未完了の場合は更新していないので履歴に記録しない
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return nil
+		}
+		return recordEvent(ctx, tx, model.TODOEventReopen, id, before, todo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// trashSubtreeFmt is the format of the query that selects the TODOs matching the condition
+// together with their subtasks to move them to the trash.
+// 条件の引数の後ろに所有者のIDを渡す.
+const trashSubtreeFmt = `WITH RECURSIVE subtree(id) AS (
+		SELECT id FROM todos WHERE %s AND owner_id IS ? AND deleted_at IS NULL
+		UNION
+		SELECT todos.id FROM todos JOIN subtree ON todos.parent_id = subtree.id WHERE todos.deleted_at IS NULL
+	) SELECT id FROM subtree`
 
+// DeleteTODO moves TODOs on DB by ids to the trash.
+// サブタスクも一緒にゴミ箱に移動する. ゴミ箱から完全に削除するにはTrashServiceを使う.
+func (s *TODOService) DeleteTODO(ctx context.Context, ids []int64) error {
 	// idsが空の場合はnilを返す
 	if len(ids) == 0 {
 		return nil
 	}
 
-	// クエリのプレースホルダーを生成
-	placeholders := strings.Repeat(",?", len(ids)-1)
+	// クエリのプレースホルダーと引数を生成
+	placeholders, args := inPlaceholders(ids)
 
-	// クエリを生成
-	query := fmt.Sprintf(deleteFmt, placeholders)
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		trashed, err := trashTODOs(ctx, tx, "id IN ("+placeholders+")", args...)
+		if err != nil {
+			return err
+		}
 
-	// クエリの引数を生成
-	args := make([]interface{}, len(ids))
-	for i, id := range ids {
-		args[i] = id
-	}
+		// rows affected is 0, return ErrNotFound
+		if trashed == 0 {
+			return &model.ErrNotFound{}
+		}
+		return nil
+	})
+}
+
+// DeleteTODOIfMatch moves the TODO on DB to the trash only if it is still at the given version.
+// DeleteTODOと同じくサブタスクも一緒に移動する.
+func (s *TODOService) DeleteTODOIfMatch(ctx context.Context, id, version int64) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		trashed, err := trashTODOs(ctx, tx, "id = ? AND version = ?", id, version)
+		if err != nil {
+			return err
+		}
+
+		// rows affected is 0, return ErrNotFound or ErrPreconditionFailed
+		if trashed == 0 {
+			return notUpdated(ctx, tx, id, version)
+		}
+		return nil
+	})
+}
+
+// trashTODOs moves the TODOs matching cond to the trash together with their subtasks
+// and returns the number of moved TODOs.
+// サブタスクは親と同じdeleted_atにして、復元時に一緒に戻せるようにする.
+func trashTODOs(ctx context.Context, tx *sql.Tx, cond string, args ...interface{}) (int, error) {
+	const (
+		trashFmt = `UPDATE todos SET deleted_at = DATETIME('now') WHERE id IN (%s)`
+		stopFmt  = `UPDATE time_entries SET stopped_at = MAX(?, started_at) WHERE stopped_at IS NULL AND todo_id IN (%s)`
+	)
 
-	// execute delete query
-	rows, err := s.db.ExecContext(ctx, query, args...)
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(trashSubtreeFmt, cond), append(args, ownerID(ctx))...)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	defer rows.Close()
 
-	// rows affected is 0, return ErrNotFound
-	if affected, _ := rows.RowsAffected(); affected == 0 {
-		return &model.ErrNotFound{}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return 0, nil
 	}
 
-	return nil
+	// 変更前のTODOを読み込んでから、1つの文でまとめて移動する
+	befores := make([]*model.TODO, len(ids))
+	for i, id := range ids {
+		if befores[i], err = getTODO(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+	placeholders, idArgs := inPlaceholders(ids)
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(trashFmt, placeholders), idArgs...); err != nil {
+		return 0, err
+	}
+	// ゴミ箱のTODOのタイマーは見つからなくなるので、計測中のタイマーを止めておく
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(stopFmt, placeholders), append([]interface{}{sqliteTime(time.Now())}, idArgs...)...); err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if err := recordEvent(ctx, tx, model.TODOEventDelete, id, befores[i], nil); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
 }
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/common"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+const (
+	// apiTokenColumns is the column list shared by every query that scans an APIToken with scanAPIToken.
+	apiTokenColumns = `id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at`
+	// apiTokenPrefix is put at the head of every API token so that leaked tokens are easy to find.
+	apiTokenPrefix = "todo_"
+	// apiTokenDisplayLength is the length of the head of the token kept in plain text to tell tokens apart.
+	apiTokenDisplayLength = len(apiTokenPrefix) + 8
+	// apiTokenUsedInterval is how often the last use of a token is recorded.
+	// リクエストごとに書き込まないように、この間隔より前に使われた場合だけ更新する.
+	apiTokenUsedInterval = time.Minute
+)
+
+// scanAPIToken scans a row selected with apiTokenColumns into an APIToken.
+func scanAPIToken(row rowScanner) (*model.APIToken, error) {
+	var (
+		token                            model.APIToken
+		scopes                           string
+		expiresAt, lastUsedAt, revokedAt sql.NullTime
+	)
+	if err := row.Scan(&token.ID, &token.Name, &token.Prefix, &scopes, &expiresAt, &lastUsedAt, &revokedAt, &token.CreatedAt); err != nil {
+		return nil, err
+	}
+	for _, scope := range strings.Fields(scopes) {
+		token.Scopes = append(token.Scopes, model.TokenScope(scope))
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+// A TokenService implements the personal API tokens of users.
+// トークンはcontextのユーザーのものだけを扱う.
+type TokenService struct {
+	db *sql.DB
+}
+
+// NewTokenService returns new TokenService.
+func NewTokenService(db *sql.DB) *TokenService {
+	return &TokenService{
+		db: db,
+	}
+}
+
+// CreateToken creates an API token on DB and returns it together with its secret.
+// secretはハッシュしか保存しないので、後から読み直すことはできない.
+func (s *TokenService) CreateToken(ctx context.Context, name string, scopes []model.TokenScope, expiresAt *time.Time) (*model.APIToken, string, error) {
+	const insert = `INSERT INTO api_tokens(user_id, name, prefix, token_hash, scopes, expires_at) VALUES(?, ?, ?, ?, ?, ?)`
+
+	if len(scopes) == 0 {
+		return nil, "", &model.ErrInvalid{Reason: "scopes is required"}
+	}
+	names := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !scope.Valid() {
+			return nil, "", &model.ErrInvalid{Reason: fmt.Sprintf("invalid scope %q", scope)}
+		}
+		if !containsString(names, string(scope)) {
+			names = append(names, string(scope))
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", &model.ErrInvalid{Reason: "expires_at must be in the future"}
+	}
+
+	secret, err := newSecret(apiTokenPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// execute insert query
+	result, err := s.db.ExecContext(ctx, insert, common.GetUserID(ctx), name, secret[:apiTokenDisplayLength], hashSecret(secret),
+		strings.Join(names, " "), sqliteNullTime(expiresAt))
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := getAPIToken(ctx, s.db, id)
+	if err != nil {
+		return nil, "", err
+	}
+	return token, secret, nil
+}
+
+// ReadToken reads the API tokens of the user on DB in descending order of id.
+// 失効したトークンや期限切れのトークンも含める.
+func (s *TokenService) ReadToken(ctx context.Context) ([]*model.APIToken, error) {
+	const read = `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := s.db.QueryContext(ctx, read, common.GetUserID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]*model.APIToken, 0)
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// getAPIToken reads the API token of the user in ctx by id using q.
+func getAPIToken(ctx context.Context, q queryer, id int64) (*model.APIToken, error) {
+	const read = `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE id = ? AND user_id = ?`
+
+	token, err := scanAPIToken(q.QueryRowContext(ctx, read, id, common.GetUserID(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// RevokeToken revokes the API token on DB so that it can no longer be used.
+// 失効済みのトークンはそのまま返し、失効した日時は変えない.
+func (s *TokenService) RevokeToken(ctx context.Context, id int64) (*model.APIToken, error) {
+	const revoke = `UPDATE api_tokens SET revoked_at = DATETIME('now') WHERE id = ? AND user_id = ? AND revoked_at IS NULL`
+
+	if _, err := s.db.ExecContext(ctx, revoke, id, common.GetUserID(ctx)); err != nil {
+		return nil, err
+	}
+	return getAPIToken(ctx, s.db, id)
+}
+
+// Authenticate returns the owner and the scopes of the API token secret, or ErrUnauthorized
+// if the token does not exist, is revoked or has expired. 最後に使われた日時も記録する.
+func (s *TokenService) Authenticate(ctx context.Context, secret string) (*model.User, []model.TokenScope, error) {
+	const (
+		read = `SELECT t.id, t.scopes, u.id, u.name, u.is_admin, u.created_at, u.updated_at FROM api_tokens t JOIN users u ON u.id = t.user_id
+			WHERE t.token_hash = ? AND t.revoked_at IS NULL AND (t.expires_at IS NULL OR t.expires_at > DATETIME('now'))`
+		used = `UPDATE api_tokens SET last_used_at = DATETIME('now') WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`
+	)
+
+	if !strings.HasPrefix(secret, apiTokenPrefix) {
+		return nil, nil, &model.ErrUnauthorized{}
+	}
+
+	var (
+		id     int64
+		scopes string
+		user   model.User
+	)
+	err := s.db.QueryRowContext(ctx, read, hashSecret(secret)).Scan(&id, &scopes, &user.ID, &user.Name, &user.Admin, &user.CreatedAt, &user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, &model.ErrUnauthorized{}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := s.db.ExecContext(ctx, used, id, sqliteTime(time.Now().Add(-apiTokenUsedInterval))); err != nil {
+		return nil, nil, err
+	}
+
+	var granted []model.TokenScope
+	for _, scope := range strings.Fields(scopes) {
+		granted = append(granted, model.TokenScope(scope))
+	}
+	return &user, granted, nil
+}
+
+// newSecret returns a new random token with the prefix, such as an API token or a refresh token.
+func newSecret(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// hashSecret returns the hash of the token created by newSecret stored on DB.
+// トークンは十分に長いランダムな値なので、パスワードと違いbcryptではなくsha256で照合する.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// trashedAlone is the condition of TODOs that were moved to the trash by themselves,
+// not together with their parent.
+const trashedAlone = `deleted_at IS NOT NULL AND NOT EXISTS(
+		SELECT 1 FROM todos parent WHERE parent.id = todos.parent_id AND parent.deleted_at = todos.deleted_at)`
+
+// A TrashService implements the trash of deleted TODOs.
+// TODOServiceと同じく、contextのユーザーが所有するTODOだけを扱う.
+type TrashService struct {
+	db *sql.DB
+}
+
+// NewTrashService returns new TrashService.
+func NewTrashService(db *sql.DB) *TrashService {
+	return &TrashService{
+		db: db,
+	}
+}
+
+// ReadTrash reads the TODOs in the trash on DB in descending order of id.
+// 親と一緒にゴミ箱に移動したサブタスクは親を復元すると戻るので、一覧には含めない.
+func (s *TrashService) ReadTrash(ctx context.Context, prevID, size int64) ([]*model.TODO, error) {
+	const read = `SELECT ` + todoColumns + ` FROM todos WHERE owner_id IS ? AND ` + trashedAlone + ` AND (? = 0 OR id < ?) ORDER BY id DESC LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, read, ownerID(ctx), prevID, prevID, size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := make([]*model.TODO, 0)
+	for rows.Next() {
+		todo, err := scanTODO(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadTags(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+	if err := loadFields(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// RestoreTODO moves TODOs on DB by ids out of the trash together with the subtasks trashed with them.
+// 親がゴミ箱にあるTODOは、親を先に復元しないと戻せない.
+func (s *TrashService) RestoreTODO(ctx context.Context, ids []int64) ([]*model.TODO, error) {
+	const (
+		read = `SELECT parent.deleted_at IS NOT NULL FROM todos LEFT JOIN todos parent ON parent.id = todos.parent_id
+			WHERE todos.id = ? AND todos.owner_id IS ? AND todos.deleted_at IS NOT NULL`
+		subtree = `WITH RECURSIVE subtree(id, deleted_at) AS (
+			SELECT id, deleted_at FROM todos WHERE id = ?
+			UNION
+			SELECT todos.id, todos.deleted_at FROM todos JOIN subtree ON todos.parent_id = subtree.id AND todos.deleted_at = subtree.deleted_at
+		) SELECT id FROM subtree`
+		restore = `UPDATE todos SET deleted_at = NULL WHERE id = ?`
+	)
+
+	todos := make([]*model.TODO, 0, len(ids))
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, id := range uniqueIDs(ids) {
+			var parentTrashed bool
+			err := tx.QueryRowContext(ctx, read, id, ownerID(ctx)).Scan(&parentTrashed)
+			if err == sql.ErrNoRows {
+				return &model.ErrNotFound{}
+			}
+			if err != nil {
+				return err
+			}
+			if parentTrashed {
+				return &model.ErrInvalid{Reason: fmt.Sprintf("the parent of TODO %d is in the trash", id)}
+			}
+
+			rows, err := tx.QueryContext(ctx, subtree, id)
+			if err != nil {
+				return err
+			}
+			var restored []int64
+			for rows.Next() {
+				var id int64
+				if err := rows.Scan(&id); err != nil {
+					rows.Close()
+					return err
+				}
+				restored = append(restored, id)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			for _, id := range restored {
+				if _, err := tx.ExecContext(ctx, restore, id); err != nil {
+					return err
+				}
+				todo, err := getTODO(ctx, tx, id)
+				if err != nil {
+					return err
+				}
+				if err := recordEvent(ctx, tx, model.TODOEventRestore, id, nil, todo); err != nil {
+					return err
+				}
+			}
+
+			todo, err := getTODO(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			todos = append(todos, todo)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// PurgeTrash permanently deletes TODOs in the trash on DB by ids, or every TODO in the user's trash if ids is empty.
+// サブタスクは外部キーのON DELETE CASCADEで一緒に削除される.
+func (s *TrashService) PurgeTrash(ctx context.Context, ids []int64) error {
+	const purgeFmt = `DELETE FROM todos WHERE owner_id IS ? AND deleted_at IS NOT NULL%s`
+
+	owner := ownerID(ctx)
+	if len(ids) == 0 {
+		_, err := s.db.ExecContext(ctx, fmt.Sprintf(purgeFmt, ""), owner)
+		return err
+	}
+
+	placeholders, args := inPlaceholders(ids)
+	result, err := s.db.ExecContext(ctx, fmt.Sprintf(purgeFmt, " AND id IN ("+placeholders+")"), append([]interface{}{owner}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	// rows affected is 0, return ErrNotFound
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &model.ErrNotFound{}
+	}
+	return nil
+}
+
+// PurgeExpired permanently deletes the TODOs moved to the trash before the given time
+// and returns the number of deleted TODOs. 一緒に削除されたサブタスクは数に含まない場合がある.
+// バックグラウンドで実行するので、すべてのユーザーのTODOが対象になる.
+func (s *TrashService) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	const purge = `DELETE FROM todos WHERE deleted_at < ?`
+
+	result, err := s.db.ExecContext(ctx, purge, sqliteTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Run purges the TODOs kept in the trash longer than retention every interval until ctx is done.
+// 起動時にも一度実行し、停止中に期限を過ぎたTODOを削除する.
+func (s *TrashService) Run(ctx context.Context, interval, retention time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeExpired(ctx, time.Now().Add(-retention))
+		switch {
+		case err != nil && ctx.Err() == nil:
+			// 一時的なエラーでサーバーを止めないように、ログに出して次の実行を待つ
+			log.Println("trash: failed to purge expired TODOs, err =", err)
+		case purged > 0:
+			log.Printf("trash: purged %d TODOs", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
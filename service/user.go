@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userColumns is the column list shared by every query that scans a User with scanUser.
+const userColumns = `id, name, is_admin, created_at, updated_at`
+
+// passwordMaxLength is the largest number of bytes of a password that bcrypt can hash.
+const passwordMaxLength = 72
+
+var (
+	// passwordMu guards passwordCost and dummyHash.
+	passwordMu sync.Mutex
+	// passwordCost is the bcrypt cost of new password hashes.
+	passwordCost = bcrypt.DefaultCost
+	// dummyHash is the hash compared when the user is not found, created with passwordCost on first use.
+	dummyHash []byte
+)
+
+// SetPasswordCost sets the bcrypt cost of the password hashes created after the call.
+// 既存のハッシュはそれぞれのコストで照合する. リクエストを処理する前に呼ぶ.
+func SetPasswordCost(cost int) error {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cost)
+	}
+	passwordMu.Lock()
+	defer passwordMu.Unlock()
+	passwordCost = cost
+	dummyHash = nil
+	return nil
+}
+
+// scanUser scans a row selected with userColumns into a User.
+// userColumnsの後ろに続く列はextraに読み込む.
+func scanUser(row rowScanner, extra ...interface{}) (*model.User, error) {
+	var user model.User
+	dest := []interface{}{&user.ID, &user.Name, &user.Admin, &user.CreatedAt, &user.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// A UserService implements the accounts of users and their authentication.
+type UserService struct {
+	db *sql.DB
+}
+
+// NewUserService returns new UserService.
+func NewUserService(db *sql.DB) *UserService {
+	return &UserService{
+		db: db,
+	}
+}
+
+// CreateUser creates a User on DB with the bcrypt hash of password.
+// 名前が重複する場合はUNIQUE制約の違反になる.
+func (s *UserService) CreateUser(ctx context.Context, name, password string) (*model.User, error) {
+	const insert = `INSERT INTO users(name, password_hash) VALUES(?, ?)`
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	// execute insert query
+	result, err := s.db.ExecContext(ctx, insert, name, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, id)
+}
+
+// EnsureAdmin creates the User on DB unless a User with the name already exists, and makes the User an admin.
+// 既存のユーザーのパスワードは変更しない. 起動時に環境変数のユーザーを管理者として登録するために使う.
+func (s *UserService) EnsureAdmin(ctx context.Context, name, password string) (*model.User, error) {
+	const (
+		read    = `SELECT id FROM users WHERE name = ?`
+		promote = `UPDATE users SET is_admin = TRUE WHERE id = ? AND is_admin = FALSE`
+	)
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, read, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		user, err := s.CreateUser(ctx, name, password)
+		if err != nil {
+			return nil, err
+		}
+		id = user.ID
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.ExecContext(ctx, promote, id); err != nil {
+		return nil, err
+	}
+	return s.GetUser(ctx, id)
+}
+
+// AdoptTODOs makes the User the owner of every TODO without an owner, and returns the number of adopted TODOs.
+// 所有者の列を追加する前のTODOとユーザーのいないcontextで作成したTODOを、起動時に最初のユーザーに引き継ぐために使う.
+// 履歴と、所有者のいないプロジェクト(共有のInboxを除く)、タグとカスタムフィールドも同じトランザクションで引き継ぐ.
+func (s *UserService) AdoptTODOs(ctx context.Context, id int64) (int64, error) {
+	const (
+		adopt         = `UPDATE todos SET owner_id = ? WHERE owner_id IS NULL`
+		adoptEvents   = `UPDATE todo_events SET owner_id = ? WHERE owner_id = 0`
+		adoptProjects = `UPDATE projects SET owner_id = ? WHERE owner_id IS NULL AND id <> ?`
+		adoptTags     = `UPDATE tags SET owner_id = ? WHERE owner_id IS NULL`
+		adoptFields   = `UPDATE custom_fields SET owner_id = ? WHERE owner_id IS NULL`
+	)
+
+	var adopted int64
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, adopt, id)
+		if err != nil {
+			return err
+		}
+		if adopted, err = result.RowsAffected(); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, adoptEvents, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, adoptProjects, id, model.InboxProjectID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, adoptTags, id); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, adoptFields, id)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return adopted, nil
+}
+
+// GetUser reads the User on DB by id.
+func (s *UserService) GetUser(ctx context.Context, id int64) (*model.User, error) {
+	const read = `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+
+	user, err := scanUser(s.db.QueryRowContext(ctx, read, id))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Authenticate returns the User with the name if password matches, or ErrUnauthorized.
+// ユーザーが存在しない場合とパスワードが違う場合は区別しない.
+// 応答時間でユーザーの有無がわからないように、存在しない場合もダミーのハッシュと照合する.
+func (s *UserService) Authenticate(ctx context.Context, name, password string) (*model.User, error) {
+	const read = `SELECT ` + userColumns + `, password_hash FROM users WHERE name = ?`
+
+	var hash string
+	user, err := scanUser(s.db.QueryRowContext(ctx, read, name), &hash)
+	if err == sql.ErrNoRows {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, &model.ErrUnauthorized{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, &model.ErrUnauthorized{}
+	}
+	return user, nil
+}
+
+// UpdatePassword replaces the password of the User on DB after checking the current password.
+// 現在のパスワードが違う場合はErrForbiddenを返す.
+// 漏れたパスワードで発行されたトークンを使えなくするため、リフレッシュトークンとAPIトークンも同じトランザクションで失効させる.
+func (s *UserService) UpdatePassword(ctx context.Context, id int64, current, password string) (*model.User, error) {
+	const (
+		read          = `SELECT password_hash FROM users WHERE id = ?`
+		update        = `UPDATE users SET password_hash = ? WHERE id = ?`
+		revokeRefresh = `UPDATE refresh_tokens SET revoked_at = DATETIME('now') WHERE user_id = ? AND revoked_at IS NULL`
+		revokeTokens  = `UPDATE api_tokens SET revoked_at = DATETIME('now') WHERE user_id = ? AND revoked_at IS NULL`
+	)
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var currentHash string
+		err := tx.QueryRowContext(ctx, read, id).Scan(&currentHash)
+		if err == sql.ErrNoRows {
+			return &model.ErrNotFound{}
+		}
+		if err != nil {
+			return err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(currentHash), []byte(current)) != nil {
+			return &model.ErrForbidden{}
+		}
+
+		if _, err := tx.ExecContext(ctx, update, hash, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, revokeRefresh, id); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, revokeTokens, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetUser(ctx, id)
+}
+
+// hashPassword returns the bcrypt hash of password.
+// bcryptは72バイトより後ろを無視するので、長すぎるパスワードは切り捨てずにErrInvalidにする.
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", &model.ErrInvalid{Reason: "password is required"}
+	}
+	if len(password) > passwordMaxLength {
+		return "", &model.ErrInvalid{Reason: "password must be at most 72 bytes"}
+	}
+	passwordMu.Lock()
+	cost := passwordCost
+	passwordMu.Unlock()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// dummyPasswordHash returns the hash compared when the user is not found.
+func dummyPasswordHash() []byte {
+	passwordMu.Lock()
+	defer passwordMu.Unlock()
+	if dummyHash == nil {
+		// 生成に失敗した場合は照合がすぐに失敗するだけなので、エラーは無視して次回に作り直す
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), passwordCost)
+	}
+	return dummyHash
+}